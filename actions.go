@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// actionsCommands maps canonical severities to the workflow commands that
+// annotate them.
+var actionsCommands = map[string]string{
+	"blocker": "error",
+	"major":   "error",
+	"minor":   "warning",
+	"nit":     "notice",
+}
+
+var (
+	actionsData     = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+	actionsProperty = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C")
+)
+
+// writeAnnotations prints a workflow command per finding with a location,
+// which GitHub shows on the line of the PR's diff.
+func writeAnnotations(w io.Writer, findings []Finding, labels severityLabels) {
+	for _, f := range findings {
+		if f.File == "" {
+			continue
+		}
+		props := "file=" + actionsProperty.Replace(f.File)
+		if f.Line > 0 {
+			props += fmt.Sprintf(",line=%d", f.Line)
+		}
+		props += ",title=" + actionsProperty.Replace("prgpt "+labels.label(f.Severity))
+		fmt.Fprintf(w, "::%s %s::%s\n", actionsCommands[f.Severity], props, actionsData.Replace(f.Message))
+	}
+}
+
+// appendToEnvFile appends to the file named by one of the GITHUB_ variables
+// a runner sets for a step.
+func appendToEnvFile(name, content string) error {
+	path := os.Getenv(name)
+	if path == "" {
+		return fmt.Errorf("%s is not set; not running in GitHub Actions?", name)
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("error opening %s: %v", name, err)
+	}
+	if _, err := file.WriteString(content); err != nil {
+		file.Close()
+		return fmt.Errorf("error writing %s: %v", name, err)
+	}
+	return file.Close()
+}
+
+// reportToActions annotates the findings, adds the review to the job
+// summary and sets the approved, blockers and findings step outputs.
+func reportToActions(review string, findings []Finding, approved bool, labels severityLabels) error {
+	writeAnnotations(os.Stdout, findings, labels)
+
+	if err := appendToEnvFile("GITHUB_STEP_SUMMARY", "## prgpt review\n\n"+strings.TrimSpace(review)+"\n"); err != nil {
+		return err
+	}
+	outputs := fmt.Sprintf("approved=%t\nblockers=%d\nfindings=%d\n", approved, countSeverity(findings, "blocker"), len(findings))
+	return appendToEnvFile("GITHUB_OUTPUT", outputs)
+}
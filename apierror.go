@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/loadfms/prgpt/pkg/prgpt"
+)
+
+// apiErrorHints tell what to do about the errors providers explain, by
+// their code.
+var apiErrorHints = map[string]string{
+	"insufficient_quota":      "the account is out of credit or over its spending limit; check the billing settings of your OpenAI account",
+	"invalid_api_key":         "the API key was rejected; check it with prgpt -explain-config, or run prgpt init",
+	"authentication_error":    "the API key was rejected; check it with prgpt -explain-config, or run prgpt init",
+	"context_length_exceeded": "the prompt is too long for the model; review in chunks with -chunked, set [model] context_window so prgpt splits it, or leave files out with -exclude",
+	"model_not_found":         "the model does not exist or the key cannot use it; check [model] name",
+	"DeploymentNotFound":      "the Azure deployment does not exist; check [azure] endpoint and deployment",
+	"not_found_error":         "the model does not exist or the key cannot use it; check [model] name",
+	"rate_limit_exceeded":     "still rate limited after retrying; try again later, or raise [network] max_attempts",
+	"rate_limit_error":        "still rate limited after retrying; try again later, or raise [network] max_attempts",
+	"overloaded_error":        "the provider is overloaded; try again later",
+}
+
+// apiErrorHint suggests a remedy for a failed API call, or returns "".
+func apiErrorHint(err error) string {
+	var apiErr *prgpt.APIError
+	if !errors.As(err, &apiErr) {
+		return ""
+	}
+	if hint, ok := apiErrorHints[apiErr.Code]; ok {
+		return hint
+	}
+	switch {
+	case strings.HasPrefix(apiErr.Status, "401"):
+		return apiErrorHints["invalid_api_key"]
+	case strings.HasPrefix(apiErr.Status, "429"):
+		return apiErrorHints["rate_limit_exceeded"]
+	}
+	return ""
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+var unsafeFilenameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+func sanitizeFilename(s string) string {
+	s = unsafeFilenameChars.ReplaceAllString(s, "_")
+	if s == "" || s == "." || s == ".." {
+		return "_"
+	}
+	return s
+}
+
+// artifactName names the artifact directory of a review: <owner>_<repo>_<number>
+// for PRs, merge_<sha> for merge commits and local for local changes.
+func artifactName(prURL, mergeCommit string) string {
+	if mergeCommit != "" {
+		return "merge_" + mergeCommit
+	}
+	if prURL == "" {
+		return "local"
+	}
+	org, repo, prNumber, err := parsePRURL(prURL)
+	if err != nil {
+		return prURL
+	}
+	return org + "_" + repo + "_" + prNumber
+}
+
+// writeArtifacts saves the review, its findings and the raw model response
+// under <dir>/<name>/ and returns that directory.
+func writeArtifacts(dir, name, review, raw string, findings []Finding) (string, error) {
+	prDir := filepath.Join(dir, sanitizeFilename(name))
+	if err := os.MkdirAll(prDir, 0o755); err != nil {
+		return "", fmt.Errorf("error creating output directory: %v", err)
+	}
+
+	if findings == nil {
+		findings = []Finding{}
+	}
+	findingsJSON, err := json.MarshalIndent(findings, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("error marshaling findings: %v", err)
+	}
+
+	files := map[string][]byte{
+		"review.md":     []byte(review + "\n"),
+		"findings.json": append(findingsJSON, '\n'),
+		"raw.txt":       []byte(raw),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(prDir, name), data, 0o644); err != nil {
+			return "", fmt.Errorf("error writing %s: %v", name, err)
+		}
+	}
+
+	return prDir, nil
+}
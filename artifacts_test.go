@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestArtifactName(t *testing.T) {
+	tests := []struct {
+		prURL, mergeCommit string
+		want               string
+	}{
+		{"https://github.com/loadfms/prgpt/pull/42", "", "loadfms_prgpt_42"},
+		{"", "abc1234", "merge_abc1234"},
+		{"", "", "local"},
+	}
+	for _, tt := range tests {
+		if got := artifactName(tt.prURL, tt.mergeCommit); got != tt.want {
+			t.Errorf("artifactName(%q, %q) = %q, want %q", tt.prURL, tt.mergeCommit, got, tt.want)
+		}
+	}
+}
+
+func TestOutputDir(t *testing.T) {
+	const text = "## Final consideration\n- [major] a.go:3 - nil map write\n- [nit] a.go:9 - typo"
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"markdown output", nil},
+		{"filtered output", []string{"-grep-findings", "typo"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useConfig(t, "provider = \"mock\"\n")
+			t.Setenv("PRGPT_MOCK_TEXT", text)
+			dir := t.TempDir()
+			_, code := runPrgpt(t, append([]string{"-diff", "testdata/replay/change.patch", "-output-dir", dir}, tt.args...)...)
+			if code != exitRejected {
+				t.Fatalf("exit code = %d, want %d", code, exitRejected)
+			}
+
+			read := func(name string) string {
+				data, err := os.ReadFile(filepath.Join(dir, "local", name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				return string(data)
+			}
+			if raw := read("raw.txt"); raw != text+"\n\nApproved: false" {
+				t.Errorf("raw.txt = %q, want the model response", raw)
+			}
+			// The artifacts hold the whole review, whatever is displayed.
+			if review := read("review.md"); !strings.Contains(review, "nil map write") || !strings.Contains(review, "typo") {
+				t.Errorf("review.md lacks a finding:\n%s", review)
+			}
+			var findings []Finding
+			if err := json.Unmarshal([]byte(read("findings.json")), &findings); err != nil {
+				t.Fatalf("findings.json: %v", err)
+			}
+			if len(findings) != 2 || findings[0].Severity != "major" || findings[0].File != "a.go" || findings[0].Line != 3 {
+				t.Errorf("findings.json = %+v", findings)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// askQuestion takes the question of "prgpt ask" from the arguments fs left
+// unparsed, parsing the flags that follow it, so it can come before or
+// after them: prgpt ask -pr <url> "<question>".
+func askQuestion(fs *flag.FlagSet) (string, error) {
+	var words []string
+	for fs.NArg() > 0 {
+		words = append(words, fs.Arg(0))
+		if err := fs.Parse(fs.Args()[1:]); err != nil {
+			return "", err
+		}
+	}
+	question := strings.TrimSpace(strings.Join(words, " "))
+	if question == "" {
+		return "", fmt.Errorf("prgpt ask needs a question, e.g. prgpt ask -pr <url> \"does this change the public API?\"")
+	}
+	return question, nil
+}
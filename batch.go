@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/loadfms/prgpt/pkg/prgpt"
+)
+
+const defaultWorkers = 4
+
+type batchResult struct {
+	// name is the PR URL, or the commit of prgpt range.
+	name   string
+	output string
+	stderr string
+	code   int
+}
+
+// readPRList reads PR URLs, one per line, skipping blank lines and
+// # comments, so that e.g. `gh pr list --json url -q '.[].url'` can be
+// piped in.
+func readPRList(r io.Reader) ([]string, error) {
+	var urls []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		urls = append(urls, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading PR list: %v", err)
+	}
+	return urls, nil
+}
+
+// batchArgs are the flags given to this invocation, except -pr and the
+// batch flags themselves, to run each review with.
+func batchArgs(fs *flag.FlagSet) []string {
+	var args []string
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "pr", "workers", "summary":
+			return
+		}
+		if list, ok := f.Value.(*stringList); ok {
+			for _, v := range *list {
+				args = append(args, "-"+f.Name+"="+v)
+			}
+			return
+		}
+		args = append(args, "-"+f.Name+"="+f.Value.String())
+	})
+	return args
+}
+
+// runBatch reviews every PR in its own prgpt process, at most workers at a
+// time, and prints the reviews in the order the PRs were given, or a table
+// of the verdicts with summary, or with asJSON one JSON array of them. The
+// exit code is the worst of the reviews'.
+func runBatch(ctx context.Context, urls []string, args []string, workers int, summary, asJSON bool) int {
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Println("Error locating prgpt executable:", err)
+		return exitError
+	}
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+	dir, err := os.MkdirTemp("", "prgpt-batch-")
+	if err != nil {
+		fmt.Println("Error creating temporary directory:", err)
+		return exitError
+	}
+	defer os.RemoveAll(dir)
+	paceFile := filepath.Join(dir, "pace")
+
+	results := make([]batchResult, len(urls))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(workers, len(urls)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = reviewInProcess(ctx, exe, urls[i], args, paceFile)
+			}
+		}()
+	}
+	for i := range urls {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	if asJSON {
+		return printBatchJSON(results)
+	}
+	return printBatch(results, summary, "PR")
+}
+
+// printBatch prints the reviews of a batch, or with summary a table of their
+// verdicts with a column of what was reviewed under heading, and returns the
+// worst of their exit codes.
+func printBatch(results []batchResult, summary bool, heading string) int {
+	code := relayBatchStderr(results)
+	if summary {
+		fmt.Print(formatBatchSummary(results, heading))
+		return code
+	}
+	for _, res := range results {
+		fmt.Printf("## %s\n\n%s\n\n", res.name, strings.TrimSpace(res.output))
+	}
+	return code
+}
+
+// relayBatchStderr prints what the reviews of a batch printed to stderr,
+// prefixed with what they reviewed, and returns the worst of their exit
+// codes.
+func relayBatchStderr(results []batchResult) int {
+	code := exitApproved
+	for _, res := range results {
+		code = max(code, res.code)
+		for _, line := range strings.Split(strings.TrimSpace(res.stderr), "\n") {
+			if line != "" {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", res.name, line)
+			}
+		}
+	}
+	return code
+}
+
+// batchReview is a review of a batch in the JSON array -output json prints:
+// the JSON review of the PR, or the error that prevented it.
+type batchReview struct {
+	PR       string          `json:"pr"`
+	ExitCode int             `json:"exit_code"`
+	Review   json.RawMessage `json:"review,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// printBatchJSON prints the JSON reviews of a batch as one JSON array and
+// returns the worst of their exit codes.
+func printBatchJSON(results []batchResult) int {
+	code := relayBatchStderr(results)
+	reviews := make([]batchReview, len(results))
+	for i, res := range results {
+		reviews[i] = batchReview{PR: res.name, ExitCode: res.code}
+		if output := strings.TrimSpace(res.output); res.code != exitError && json.Valid([]byte(output)) {
+			reviews[i].Review = json.RawMessage(output)
+		} else {
+			reviews[i].Error = output
+		}
+	}
+	data, err := json.MarshalIndent(reviews, "", "  ")
+	if err != nil {
+		fmt.Println("Error encoding reviews:", err)
+		return exitError
+	}
+	fmt.Println(string(data))
+	return code
+}
+
+func reviewInProcess(ctx context.Context, exe, prURL string, args []string, paceFile string) batchResult {
+	return runReviewProcess(ctx, exe, prURL, append(append([]string{}, args...), "-pr="+prURL), paceFile)
+}
+
+// runReviewProcess runs prgpt with args, the review of what name names,
+// pacing its API calls with those of the other reviews given the same
+// paceFile, if any.
+func runReviewProcess(ctx context.Context, exe, name string, args []string, paceFile string) batchResult {
+	var stdout, stderr bytes.Buffer
+	cmd := prgpt.Command(ctx, exe, args...)
+	if paceFile != "" {
+		cmd.Env = append(os.Environ(), paceFileEnv+"="+paceFile)
+	}
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	res := batchResult{name: name, code: exitApproved}
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == exitRejected {
+			res.code = exitRejected
+		} else {
+			res.code = exitError
+			if !errors.As(err, &exitErr) {
+				fmt.Fprintf(&stdout, "Error running review: %v\n", err)
+			}
+		}
+	}
+	res.output, res.stderr = stdout.String(), stderr.String()
+	return res
+}
+
+func formatBatchSummary(results []batchResult, heading string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "| %s | Verdict |\n|---|---|\n", heading)
+	for _, res := range results {
+		verdict := "approved"
+		switch res.code {
+		case exitRejected:
+			verdict = "not approved"
+		case exitError:
+			// Errors are printed on a single line before exiting.
+			lines := strings.Split(strings.TrimSpace(res.output), "\n")
+			verdict = "error: " + lines[len(lines)-1]
+		}
+		fmt.Fprintf(&b, "| %s | %s |\n", strings.ReplaceAll(res.name, "|", "\\|"), strings.ReplaceAll(verdict, "|", "\\|"))
+	}
+	return b.String()
+}
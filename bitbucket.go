@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	bitbucketAPIBase  = "https://api.bitbucket.org/2.0"
+	bitbucketPRMarker = "/pull-requests/"
+)
+
+// isBitbucketURL reports whether prURL is on Bitbucket Cloud; other forges,
+// such as Bitbucket Server, also use /pull-requests/ paths.
+func isBitbucketURL(prURL string) bool {
+	u, err := url.Parse(prURL)
+	return err == nil && u.Hostname() == "bitbucket.org"
+}
+
+// parseBitbucketURL splits a Bitbucket Cloud pull request URL such as
+// https://bitbucket.org/workspace/repo/pull-requests/12.
+func parseBitbucketURL(prURL string) (workspace, repo, prNumber string, err error) {
+	u, err := url.Parse(prURL)
+	if err != nil {
+		return "", "", "", fmt.Errorf("invalid Bitbucket PR URL: %v", err)
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 4 || parts[2] != strings.Trim(bitbucketPRMarker, "/") {
+		return "", "", "", fmt.Errorf("invalid Bitbucket PR URL %q, expected https://bitbucket.org/WORKSPACE/REPO/pull-requests/NUMBER", prURL)
+	}
+	return parts[0], parts[1], parts[3], nil
+}
+
+// errBitbucketNotFound is returned for a 404, such as for a file the
+// repository does not have.
+var errBitbucketNotFound = errors.New("Bitbucket API returned 404 Not Found")
+
+// bitbucketForge calls the Bitbucket Cloud REST API, authenticating with a
+// username and an app password.
+type bitbucketForge struct {
+	client      *http.Client
+	username    string
+	appPassword string
+}
+
+// do calls the endpoint of the PR's repository at path, e.g.
+// "pullrequests/1", or the repository itself for an empty path.
+func (f bitbucketForge) do(ctx context.Context, method, prURL, path string, payload any) ([]byte, error) {
+	workspace, repo, _, err := parseBitbucketURL(prURL)
+	if err != nil {
+		return nil, err
+	}
+	if f.username == "" || f.appPassword == "" {
+		return nil, fmt.Errorf("Bitbucket PRs need [bitbucket] username and app_password")
+	}
+
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("error marshaling Bitbucket request: %v", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	endpoint := bitbucketAPIBase + "/repositories/" + url.PathEscape(workspace) + "/" + url.PathEscape(repo)
+	if path != "" {
+		endpoint += "/" + path
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request to Bitbucket API: %v", err)
+	}
+	req.SetBasicAuth(f.username, f.appPassword)
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making request to Bitbucket API: %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response from Bitbucket API: %v", err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errBitbucketNotFound
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("Bitbucket API returned %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
+
+type bitbucketPull struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Source      struct {
+		Commit struct {
+			Hash string `json:"hash"`
+		} `json:"commit"`
+	} `json:"source"`
+}
+
+func (f bitbucketForge) pull(ctx context.Context, prURL string) (bitbucketPull, error) {
+	var pull bitbucketPull
+	_, _, prNumber, err := parseBitbucketURL(prURL)
+	if err != nil {
+		return pull, err
+	}
+
+	data, err := f.do(ctx, "GET", prURL, "pullrequests/"+prNumber, nil)
+	if err != nil {
+		return pull, err
+	}
+	if err := json.Unmarshal(data, &pull); err != nil {
+		return pull, fmt.Errorf("error parsing Bitbucket pull request: %v", err)
+	}
+	return pull, nil
+}
+
+func (f bitbucketForge) Diff(ctx context.Context, prURL string) (string, error) {
+	_, _, prNumber, err := parseBitbucketURL(prURL)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := f.do(ctx, "GET", prURL, "pullrequests/"+prNumber+"/diff", nil)
+	return string(data), err
+}
+
+// DiffSince uses Bitbucket's "new..old" spec, which diffs the new head
+// against its merge base with the old one.
+func (f bitbucketForge) DiffSince(ctx context.Context, prURL, oldSHA, newSHA string) (string, error) {
+	data, err := f.do(ctx, "GET", prURL, "diff/"+newSHA+".."+oldSHA, nil)
+	return string(data), err
+}
+
+// Info has no labels, which Bitbucket pull requests lack.
+func (f bitbucketForge) Info(ctx context.Context, prURL string) (prInfo, error) {
+	pull, err := f.pull(ctx, prURL)
+	return prInfo{Title: pull.Title, Body: pull.Description}, err
+}
+
+func (f bitbucketForge) Issue(ctx context.Context, prURL, number string) (prInfo, error) {
+	data, err := f.do(ctx, "GET", prURL, "issues/"+number, nil)
+	if err != nil {
+		return prInfo{}, err
+	}
+
+	var issue struct {
+		Title   string `json:"title"`
+		Content struct {
+			Raw string `json:"raw"`
+		} `json:"content"`
+	}
+	if err := json.Unmarshal(data, &issue); err != nil {
+		return prInfo{}, fmt.Errorf("error parsing Bitbucket issue: %v", err)
+	}
+	return prInfo{Title: issue.Title, Body: issue.Content.Raw}, nil
+}
+
+// HeadSHA is abbreviated, as the pull request API only has short hashes.
+func (f bitbucketForge) HeadSHA(ctx context.Context, prURL string) (string, error) {
+	pull, err := f.pull(ctx, prURL)
+	return pull.Source.Commit.Hash, err
+}
+
+// IsFastForward checks that the old head is the merge base of both heads.
+func (f bitbucketForge) IsFastForward(ctx context.Context, prURL, oldSHA, newSHA string) bool {
+	data, err := f.do(ctx, "GET", prURL, "merge-base/"+oldSHA+".."+newSHA, nil)
+	if err != nil {
+		return false
+	}
+
+	var base struct {
+		Hash string `json:"hash"`
+	}
+	return json.Unmarshal(data, &base) == nil && base.Hash != "" && strings.HasPrefix(base.Hash, oldSHA)
+}
+
+func (f bitbucketForge) PostComment(ctx context.Context, prURL, body string) error {
+	_, _, prNumber, err := parseBitbucketURL(prURL)
+	if err != nil {
+		return err
+	}
+
+	payload := map[string]any{"content": map[string]string{"raw": body}}
+	if _, err := f.do(ctx, "POST", prURL, "pullrequests/"+prNumber+"/comments", payload); err != nil {
+		return fmt.Errorf("error posting PR comment: %v", err)
+	}
+	return nil
+}
+
+// RepoFile reads the file from the repository's main branch.
+func (f bitbucketForge) RepoFile(ctx context.Context, prURL, path string) ([]byte, error) {
+	data, err := f.do(ctx, "GET", prURL, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	var repo struct {
+		MainBranch struct {
+			Name string `json:"name"`
+		} `json:"mainbranch"`
+	}
+	if err := json.Unmarshal(data, &repo); err != nil {
+		return nil, fmt.Errorf("error parsing Bitbucket repository: %v", err)
+	}
+
+	data, err = f.do(ctx, "GET", prURL, "src/"+url.PathEscape(repo.MainBranch.Name)+"/"+path, nil)
+	if errors.Is(err, errBitbucketNotFound) {
+		return nil, nil
+	}
+	return data, err
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// reviewCache stores generated reviews on disk, keyed by a hash of
+// everything that went into producing them.
+type reviewCache struct {
+	dir string
+}
+
+func openReviewCache(kind string) (*reviewCache, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return nil, fmt.Errorf("error locating cache directory: %v", err)
+	}
+
+	dir := filepath.Join(base, "prgpt", kind)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("error creating cache directory: %v", err)
+	}
+
+	return &reviewCache{dir: dir}, nil
+}
+
+func cacheKey(parts ...string) string {
+	h := sha256.New()
+	for _, p := range parts {
+		fmt.Fprintf(h, "%d:%s", len(p), p)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *reviewCache) get(key string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	data, err := os.ReadFile(filepath.Join(c.dir, key))
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+func (c *reviewCache) put(key, review string) error {
+	if c == nil {
+		return nil
+	}
+	return os.WriteFile(filepath.Join(c.dir, key), []byte(review), 0o600)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestChunkCache(t *testing.T) {
+	const fileA = "diff --git a/a.go b/a.go\n--- a/a.go\n+++ b/a.go\n@@ -1 +1 @@\n-package a\n+package a // A\n"
+	fileB := func(comment string) string {
+		return "diff --git a/b.go b/b.go\n--- a/b.go\n+++ b/b.go\n@@ -1 +1 @@\n-package b\n+package b // " + comment + "\n"
+	}
+
+	var mu sync.Mutex
+	var prompts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		prompts = append(prompts, req.Messages[len(req.Messages)-1].Content)
+		mu.Unlock()
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"Looks fine.\n\nApproved: true"}}],"usage":{"prompt_tokens":10,"completion_tokens":5}}`)
+	}))
+	defer server.Close()
+	useConfig(t, fmt.Sprintf("provider = \"openai\"\n[openai]\nbase_url = %q\n[azure]\nendpoint = %q\nkey = \"k\"\n", server.URL+"/v1", server.URL))
+
+	tests := []struct {
+		name    string
+		diff    string
+		args    []string
+		changed []string
+	}{
+		{"first review", fileA + fileB("B"), nil, []string{"a.go", "b.go"}},
+		{"b.go changed", fileA + fileB("B, again"), nil, []string{"b.go"}},
+		{"first diff again", fileA + fileB("B"), nil, nil},
+		{"another temperature", fileA + fileB("B"), []string{"-temperature", "0.9"}, []string{"a.go", "b.go"}},
+		{"another provider", fileA + fileB("B"), []string{"-backend", "azure"}, []string{"a.go", "b.go"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prompts = nil
+			patch := filepath.Join(t.TempDir(), "change.patch")
+			if err := os.WriteFile(patch, []byte(tt.diff), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			output, code := runPrgpt(t, append([]string{"-diff", patch, "-chunked"}, tt.args...)...)
+			if code != exitApproved {
+				t.Fatalf("exit code = %d; output:\n%s", code, output)
+			}
+
+			var reviewed []string
+			for _, p := range prompts {
+				for _, path := range []string{"a.go", "b.go"} {
+					if strings.Count(p, "diff --git") == 1 && strings.Contains(p, "diff --git a/"+path) {
+						reviewed = append(reviewed, path)
+					}
+				}
+			}
+			sort.Strings(reviewed)
+			if !reflect.DeepEqual(reviewed, tt.changed) {
+				t.Errorf("reviewed %q on their own, want %q", reviewed, tt.changed)
+			}
+		})
+	}
+}
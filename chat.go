@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/loadfms/prgpt/pkg/prgpt"
+)
+
+// chat answers follow-up questions read from in, one per line, keeping the
+// whole conversation in the history so each question can build on the
+// review and the previous answers. It ends on EOF, "exit" or "quit", and
+// returns the conversation.
+func (r *reviewer) chat(in io.Reader, out io.Writer, history []prgpt.Message) ([]prgpt.Message, error) {
+	// Reading blocks, so lines are handed over from a goroutine and the
+	// loop can stop when the run is interrupted.
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	fmt.Fprintln(out, "\nAsk follow-up questions about the PR; \"exit\" or Ctrl-D to finish.")
+	for {
+		fmt.Fprint(out, "> ")
+
+		var question string
+		select {
+		case <-r.ctx.Done():
+			return history, r.ctx.Err()
+		case line, ok := <-lines:
+			if !ok {
+				fmt.Fprintln(out)
+				return history, nil
+			}
+			question = strings.TrimSpace(line)
+		}
+		switch question {
+		case "":
+			continue
+		case "exit", "quit":
+			return history, nil
+		}
+
+		r.history = history
+		answer, err := r.complete(question, nil)
+		if err != nil {
+			return history, err
+		}
+		if r.stream != nil {
+			// The answer was printed as it arrived.
+			fmt.Fprintln(out)
+		} else {
+			fmt.Fprintln(out, strings.TrimSpace(answer))
+		}
+
+		history = append(history,
+			prgpt.Message{Role: "user", Content: question},
+			prgpt.Message{Role: "assistant", Content: answer})
+	}
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// checklistInstruction asks for a pass/fail table of the checklist items.
+func checklistInstruction(items []string) string {
+	var b strings.Builder
+	b.WriteString("Also check the PR against this checklist. Under a '## Checklist' heading, add a Markdown table with the columns Item, Result and Notes and one row per item, in this order, where Result is pass, fail or n/a:")
+	for i, item := range items {
+		fmt.Fprintf(&b, "\n%d. %s", i+1, item)
+	}
+	return b.String()
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// defaultContextWindow is assumed for models missing from contextWindows.
+const defaultContextWindow = 16385
+
+// contextWindows maps model name prefixes to their context window in
+// tokens. The longest matching prefix wins.
+var contextWindows = map[string]int{
+	"gpt-3.5-turbo": 16385,
+	"gpt-4":         8192,
+	"gpt-4-turbo":   128000,
+	"gpt-4o":        128000,
+	"gpt-4.1":       1000000,
+	"o1":            200000,
+	"o3":            200000,
+	"claude":        200000,
+	"llama3":        8192,
+	"codellama":     16384,
+}
+
+func contextWindowFor(model string, configured int) int {
+	if configured > 0 {
+		return configured
+	}
+	window, matched := defaultContextWindow, ""
+	for prefix, w := range contextWindows {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(matched) {
+			window, matched = w, prefix
+		}
+	}
+	return window
+}
+
+// promptBudget is how many tokens of a context window a prompt may use,
+// leaving room for the response.
+func promptBudget(window int) int {
+	return window - min(window/4, 4096)
+}
+
+// splitHunks splits a file diff into the file header and its hunks.
+func splitHunks(f fileDiff) (header string, hunks []string) {
+	var current strings.Builder
+	inHunks := false
+	for _, line := range strings.SplitAfter(f.Text, "\n") {
+		if strings.HasPrefix(line, "@@") {
+			if inHunks {
+				hunks = append(hunks, current.String())
+			} else {
+				header = current.String()
+				inHunks = true
+			}
+			current.Reset()
+		}
+		current.WriteString(line)
+	}
+	if !inHunks {
+		return current.String(), nil
+	}
+	return header, append(hunks, current.String())
+}
+
+// chunkDiff splits every file diff that would not fit in maxTokens into
+// parts of whole hunks that do. A single hunk too large on its own is
+// truncated.
+func chunkDiff(files []fileDiff, maxTokens int) []fileDiff {
+	var chunks []fileDiff
+	for _, f := range files {
+		if estimateTokens(f.Text) <= maxTokens {
+			chunks = append(chunks, f)
+			continue
+		}
+
+		header, hunks := splitHunks(f)
+		var parts []string
+		part := header
+		for _, hunk := range hunks {
+			if part != header && estimateTokens(part+hunk) > maxTokens {
+				parts = append(parts, part)
+				part = header
+			}
+			part += hunk
+		}
+		parts = append(parts, part)
+
+		for i, p := range parts {
+			if text, truncated := truncateToTokens(p, maxTokens); truncated {
+				p = text + "\n[hunk truncated]\n"
+			}
+			path := f.Path
+			if len(parts) > 1 {
+				path = fmt.Sprintf("%s (part %d/%d)", f.Path, i+1, len(parts))
+			}
+			chunks = append(chunks, fileDiff{Path: path, Text: p})
+		}
+	}
+	return chunks
+}
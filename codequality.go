@@ -0,0 +1,87 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// codeQualitySeverity maps canonical severities to GitLab's.
+var codeQualitySeverity = map[string]string{
+	"nit":     "info",
+	"minor":   "minor",
+	"major":   "major",
+	"blocker": "blocker",
+}
+
+var codeQualitySeverities = map[string]bool{"info": true, "minor": true, "major": true, "critical": true, "blocker": true}
+
+// codeQualityIssue is one entry of a GitLab Code Quality report.
+type codeQualityIssue struct {
+	Description string              `json:"description"`
+	CheckName   string              `json:"check_name"`
+	Fingerprint string              `json:"fingerprint"`
+	Severity    string              `json:"severity"`
+	Location    codeQualityLocation `json:"location"`
+}
+
+type codeQualityLocation struct {
+	Path  string `json:"path"`
+	Lines struct {
+		Begin int `json:"begin"`
+	} `json:"lines"`
+}
+
+// findingFingerprint identifies a finding across pipelines by its location,
+// severity and message, so GitLab can tell new issues from resolved ones and
+// two findings of the same message in a file apart.
+func findingFingerprint(f Finding) string {
+	sum := md5.Sum([]byte(f.File + "\x00" + strconv.Itoa(f.Line) + "\x00" + f.Severity + "\x00" + f.Message))
+	return hex.EncodeToString(sum[:])
+}
+
+// codeQualityReport builds the report of the findings, leaving out with a
+// warning those GitLab would reject, so that one of them does not cost the
+// whole report.
+func codeQualityReport(findings []Finding) ([]byte, error) {
+	issues := []codeQualityIssue{}
+	for _, f := range findings {
+		issue := codeQualityIssue{
+			Description: f.Message,
+			CheckName:   "prgpt",
+			Fingerprint: findingFingerprint(f),
+			Severity:    codeQualitySeverity[f.Severity],
+		}
+		issue.Location.Path = f.File
+		if issue.Location.Path == "" {
+			issue.Location.Path = "."
+		}
+		issue.Location.Lines.Begin = max(f.Line, 1)
+
+		if err := validateCodeQualityIssue(issue); err != nil {
+			fmt.Fprintln(os.Stderr, "Warning: leaving out of the code quality report:", err)
+			continue
+		}
+		issues = append(issues, issue)
+	}
+
+	return json.MarshalIndent(issues, "", "  ")
+}
+
+// validateCodeQualityIssue checks the fields GitLab requires.
+func validateCodeQualityIssue(issue codeQualityIssue) error {
+	switch {
+	case issue.Description == "":
+		return fmt.Errorf("code quality issue without description")
+	case issue.Fingerprint == "":
+		return fmt.Errorf("code quality issue %q without fingerprint", issue.Description)
+	case !codeQualitySeverities[issue.Severity]:
+		return fmt.Errorf("code quality issue %q has invalid severity %q", issue.Description, issue.Severity)
+	case issue.Location.Path == "" || issue.Location.Lines.Begin < 1:
+		return fmt.Errorf("code quality issue %q has an invalid location", issue.Description)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestFindingFingerprint(t *testing.T) {
+	base := Finding{Severity: "major", File: "store.go", Line: 12, Message: "nil map write"}
+	tests := []struct {
+		name  string
+		other Finding
+		same  bool
+	}{
+		{"same finding", Finding{Severity: "major", File: "store.go", Line: 12, Message: "nil map write", raw: "- [P1] store.go:12 - nil map write"}, true},
+		{"another line", Finding{Severity: "major", File: "store.go", Line: 40, Message: "nil map write"}, false},
+		{"another severity", Finding{Severity: "blocker", File: "store.go", Line: 12, Message: "nil map write"}, false},
+		{"another file", Finding{Severity: "major", File: "cache.go", Line: 12, Message: "nil map write"}, false},
+		{"another message", Finding{Severity: "major", File: "store.go", Line: 12, Message: "goroutine leak"}, false},
+		{"file and message boundary", Finding{Severity: "major", File: "store.gonil", Line: 12, Message: " map write"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := findingFingerprint(base), findingFingerprint(tt.other)
+			if len(a) != 32 {
+				t.Errorf("fingerprint %q is not an MD5 hex digest", a)
+			}
+			if (a == b) != tt.same {
+				t.Errorf("fingerprints %s and %s, want equal: %t", a, b, tt.same)
+			}
+		})
+	}
+}
+
+func TestCodeQualityReport(t *testing.T) {
+	tests := []struct {
+		name     string
+		finding  Finding
+		severity string
+		path     string
+		line     int
+	}{
+		{"located", Finding{Severity: "major", File: "store.go", Line: 12, Message: "nil map write"}, "major", "store.go", 12},
+		{"nit", Finding{Severity: "nit", File: "store.go", Line: 3, Message: "typo"}, "info", "store.go", 3},
+		{"without a line", Finding{Severity: "blocker", File: "store.go", Message: "data race"}, "blocker", "store.go", 1},
+		{"without a location", Finding{Severity: "minor", Message: "missing tests"}, "minor", ".", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := codeQualityReport([]Finding{tt.finding})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var issues []codeQualityIssue
+			if err := json.Unmarshal(data, &issues); err != nil {
+				t.Fatal(err)
+			}
+			if len(issues) != 1 {
+				t.Fatalf("got %d issues, want 1", len(issues))
+			}
+			issue := issues[0]
+			if issue.Severity != tt.severity || issue.Location.Path != tt.path || issue.Location.Lines.Begin != tt.line {
+				t.Errorf("issue = %+v", issue)
+			}
+			if issue.Description != tt.finding.Message || issue.CheckName != "prgpt" || issue.Fingerprint != findingFingerprint(tt.finding) {
+				t.Errorf("issue = %+v", issue)
+			}
+		})
+	}
+
+	data, err := codeQualityReport(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "[]" {
+		t.Errorf("report without findings = %s, want []", data)
+	}
+
+	invalid := []Finding{
+		{Severity: "major", File: "store.go", Line: 12},
+		{Severity: "critical", File: "store.go", Line: 20, Message: "data race"},
+		{Severity: "minor", File: "store.go", Line: 30, Message: "unchecked error"},
+	}
+	var issues []codeQualityIssue
+	stderr := stderrOf(t, func() {
+		data, err = codeQualityReport(invalid)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &issues); err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 1 || issues[0].Description != "unchecked error" {
+		t.Errorf("issues = %+v, want only the valid one", issues)
+	}
+	for _, want := range []string{"without description", `"data race" has invalid severity`} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("stderr lacks %q:\n%s", want, stderr)
+		}
+	}
+}
+
+func TestCodeQualityOutput(t *testing.T) {
+	report := func(t *testing.T, text string) map[string]string {
+		t.Helper()
+		useConfig(t, "provider = \"mock\"\n")
+		t.Setenv("PRGPT_MOCK_TEXT", text)
+		output, code := runPrgpt(t, "-diff", "testdata/replay/change.patch", "-output", "gitlab-codequality")
+		if code != exitRejected {
+			t.Fatalf("exit code = %d; output:\n%s", code, output)
+		}
+		var issues []codeQualityIssue
+		if err := json.Unmarshal([]byte(output), &issues); err != nil {
+			t.Fatalf("output is not a report: %v\n%s", err, output)
+		}
+		fingerprints := map[string]string{}
+		for _, issue := range issues {
+			fingerprints[issue.Description] = issue.Fingerprint
+		}
+		return fingerprints
+	}
+
+	// The same findings reported again in another order, one of them moved,
+	// as after a later push.
+	first := report(t, "- [major] store.go:12 - nil map write\n- [minor] store.go:30 - unchecked error")
+	second := report(t, "- [minor] store.go:30 - unchecked error\n- [major] store.go:17 - nil map write\n- [nit] store.go:2 - typo")
+	if first["unchecked error"] != second["unchecked error"] {
+		t.Errorf("fingerprint of an unchanged finding changed from %s to %s", first["unchecked error"], second["unchecked error"])
+	}
+	if first["nil map write"] == second["nil map write"] {
+		t.Errorf("a moved finding kept the fingerprint %s", first["nil map write"])
+	}
+	if first["nil map write"] == first["unchecked error"] {
+		t.Errorf("distinct findings share the fingerprint %s", first["nil map write"])
+	}
+	if len(second) != 3 {
+		t.Errorf("got %d issues, want 3", len(second))
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// commands are the subcommands of prgpt, in the order "prgpt help" lists
+// them. review is the default: its flags may be given without it.
+var commands = []struct {
+	name, args, summary string
+}{
+	{"review", "[flags]", "Review a PR, the local changes or a patch"},
+	{"ask", "[flags] <question>", "Answer a question about a PR"},
+	{"describe", "[flags]", "Write a PR title, summary and changelog"},
+	{"commit", "[flags]", "Write a commit message for the staged changes"},
+	{"range", "[flags] <from>..<to> [-- <review flags>]", "Review every commit of a range on its own"},
+	{"release", "[flags] <from-tag>..<to-tag>", "Write release notes from the PRs merged between two tags"},
+	{"init", "", "Create the config file interactively"},
+	{"history", "[flags]", "List past reviews, newest first"},
+	{"show", "<id>", "Print a past review again"},
+	{"usage", "[flags]", "Report the tokens used and their cost per model"},
+	{"watch", "-pr <url> [flags] [-- <review flags>]", "Review the new commits of a PR whenever it changes"},
+	{"serve", "[flags] [-- <review flags>]", "Review PRs from GitHub webhooks"},
+	{"update", "[flags]", "Install the latest release of prgpt"},
+	{"version", "", "Print the version of prgpt"},
+	{"help", "[command]", "Print the help of a command"},
+}
+
+// printCommands lists the subcommands.
+func printCommands(w io.Writer) {
+	fmt.Fprintln(w, "Usage: prgpt <command> [flags]")
+	fmt.Fprintln(w, "\nCommands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(w, "\nThe flags of review can be given without it, e.g. prgpt -pr <url>. Run \"prgpt help <command>\" for the flags of a command.")
+}
+
+// setCommandUsage makes the -h of fs print the usage of the named command
+// and its flags.
+func setCommandUsage(fs *flag.FlagSet, name string) {
+	fs.Usage = func() {
+		w := fs.Output()
+		for _, c := range commands {
+			if c.name == name {
+				fmt.Fprintf(w, "Usage: %s\n\n%s.\n", strings.TrimSpace("prgpt "+c.name+" "+c.args), c.summary)
+			}
+		}
+		hasFlags := false
+		fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+		if hasFlags {
+			fmt.Fprintln(w, "\nFlags:")
+			fs.PrintDefaults()
+		}
+	}
+}
+
+// newCommandFlagSet returns the flag set of a subcommand, whose -h prints
+// its usage.
+func newCommandFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	setCommandUsage(fs, name)
+	return fs
+}
+
+// parseExit is the exit code for the error of parsing a subcommand's flags:
+// asking for its help with -h is no error.
+func parseExit(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return exitApproved
+	}
+	return exitError
+}
+
+// runHelp implements "prgpt help [command]".
+func runHelp(args []string) int {
+	fs := newCommandFlagSet("help")
+	if err := fs.Parse(args); err != nil {
+		return parseExit(err)
+	}
+	if fs.NArg() == 0 {
+		printCommands(os.Stdout)
+		return exitApproved
+	}
+	args = fs.Args()
+	for _, c := range commands {
+		if c.name == args[0] {
+			return run([]string{c.name, "-h"})
+		}
+	}
+	fmt.Printf("Unknown command %q\n\n", args[0])
+	printCommands(os.Stdout)
+	return exitError
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/loadfms/prgpt/pkg/prgpt"
+)
+
+const commitPrompt = `Write a commit message for the staged changes below in the Conventional Commits style: a subject line "type(scope): summary" of at most 72 characters, where type is one of feat, fix, docs, style, refactor, perf, test, build, ci or chore and the scope is optional, followed by a blank line and a short body explaining what changed and why, wrapped at 72 characters. Leave out the body for trivial changes. Reply with the commit message only, not in a code block.
+
+Diff:
+%s`
+
+// cleanCommitMessage strips the code fence models wrap replies in despite
+// being told not to.
+func cleanCommitMessage(reply string) string {
+	msg := strings.TrimSpace(reply)
+	if strings.HasPrefix(msg, "```") {
+		if _, rest, ok := strings.Cut(msg, "\n"); ok {
+			msg = rest
+		}
+		msg = strings.TrimSuffix(strings.TrimSpace(msg), "```")
+	}
+	return strings.TrimSpace(msg)
+}
+
+// runCommit implements "prgpt commit": a commit message for the staged
+// changes, committed with it after confirmation when asked to.
+func runCommit(args []string) int {
+	fs := newCommandFlagSet("commit")
+	profile := fs.String("profile", "", "Use the settings of [profile.NAME] in config.toml over the others; PRGPT_PROFILE sets the default")
+	backend := fs.String("backend", "", "Provider to use (openai, azure, anthropic, ollama)")
+	model := fs.String("model", "", "Model to use")
+	lang := fs.String("lang", "", "Language to write the message in")
+	doCommit := fs.Bool("commit", false, "Run git commit with the message after confirmation")
+	yes := fs.Bool("yes", false, "With -commit, commit without asking")
+	if err := fs.Parse(args); err != nil {
+		return parseExit(err)
+	}
+
+	cfg, err := loadCommandConfig(*profile, *backend, *model, *lang)
+	if err != nil {
+		fmt.Println(err)
+		return exitError
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	diff, err := prgpt.LocalDiff{Staged: true}.Diff(ctx)
+	if err != nil {
+		fmt.Println("Error fetching diff:", err)
+		return exitError
+	}
+	if len(cfg.Filters.Exclude) > 0 {
+		files, _ := excludeFiles(splitDiffFiles(diff), cfg.Filters.Exclude)
+		diff = joinDiffFiles(files)
+	}
+	if diff, err = redactDiff(cfg, diff); err != nil {
+		fmt.Println(err)
+		return exitError
+	}
+	if strings.TrimSpace(diff) == "" {
+		fmt.Println("Nothing is staged; stage changes with git add first.")
+		return exitError
+	}
+	budget := promptBudget(contextWindowFor(cfg.Model.Name, cfg.Model.ContextWindow))
+	if estimateTokens(diff) > budget {
+		fmt.Fprintln(os.Stderr, "Warning: the diff exceeds the context window of", cfg.Model.Name+"; using its beginning only")
+		diff, _ = truncateToTokens(diff, budget-estimateTokens(commitPrompt))
+	}
+
+	client, err := newHTTPClient(cfg)
+	if err != nil {
+		fmt.Println("Error configuring HTTP client:", err)
+		return exitError
+	}
+	reply, err := completeOnce(ctx, cfg, client, "commit", fmt.Sprintf(commitPrompt, diff))
+	if err != nil {
+		fmt.Println("Error generating commit message:", err)
+		if hint := apiErrorHint(err); hint != "" {
+			fmt.Println("Hint:", hint)
+		}
+		return exitError
+	}
+	msg := cleanCommitMessage(reply)
+	fmt.Println(msg)
+
+	if !*doCommit {
+		return exitApproved
+	}
+	if !*yes {
+		fmt.Print("\nCommit with this message? (y/N): ")
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if !strings.HasPrefix(strings.ToLower(strings.TrimSpace(answer)), "y") {
+			fmt.Println("Not committed.")
+			return exitError
+		}
+	}
+	cmd := prgpt.Command(ctx, "git", "commit", "-m", msg)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		fmt.Println("Error running git commit:", err)
+		return exitError
+	}
+	return exitApproved
+}
@@ -0,0 +1,572 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/user"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+const (
+	CONFIG_FOLDER = "/.config/openai/"
+	FILENAME      = "config.toml"
+)
+
+// Origins of a resolved setting, from the most to the least specific.
+const (
+	originFlag     = "flag"
+	originEnv      = "env"
+	originRepoFile = "repo file"
+	originProfile  = "profile"
+	originHomeFile = "home file"
+	originDefault  = "default"
+)
+
+type FileConfig struct {
+	Provider string `toml:"provider"`
+	// Language is what the review is written in, e.g. "pt-BR".
+	Language string `toml:"language"`
+	ApiKey   struct {
+		Key string `toml:"key" secret:"true"`
+	} `toml:"apikey"`
+	OpenAI struct {
+		// BaseURL points the openai provider at an OpenAI-compatible
+		// server instead, e.g. http://localhost:8000/v1, which is sent Key
+		// rather than [apikey] key.
+		BaseURL string            `toml:"base_url"`
+		Key     string            `toml:"key" secret:"true"`
+		Headers map[string]string `toml:"headers" secret:"true"`
+	} `toml:"openai"`
+	Anthropic struct {
+		Key string `toml:"key" secret:"true"`
+	} `toml:"anthropic"`
+	Azure struct {
+		Endpoint   string `toml:"endpoint"`
+		Deployment string `toml:"deployment"`
+		APIVersion string `toml:"api_version"`
+		Key        string `toml:"key" secret:"true"`
+	} `toml:"azure"`
+	Model struct {
+		Name          string  `toml:"name"`
+		ContextWindow int     `toml:"context_window"`
+		Temperature   float64 `toml:"temperature"`
+		MaxTokens     int     `toml:"max_tokens"`
+		TopP          float64 `toml:"top_p"`
+	} `toml:"model"`
+	Prompt struct {
+		System    string                    `toml:"system"`
+		Custom    string                    `toml:"custom"`
+		Templates map[string]PromptTemplate `toml:"templates"`
+	} `toml:"prompt"`
+	Temperature TemperatureCurve `toml:"temperature"`
+	Context     struct {
+		MaxTokens int `toml:"max_tokens"`
+	} `toml:"context"`
+	Coverage struct {
+		MinRatio float64 `toml:"min_ratio"`
+	} `toml:"coverage"`
+	History struct {
+		Disabled bool `toml:"disabled"`
+	} `toml:"history"`
+	Thread struct {
+		MaxMessages int `toml:"max_messages"`
+	} `toml:"thread"`
+	Template struct {
+		Required []string `toml:"required"`
+	} `toml:"template"`
+	Security struct {
+		// Model reviews with -mode security unless -model is given.
+		Model string `toml:"model"`
+	} `toml:"security"`
+	Jury struct {
+		Models []JuryModel `toml:"models"`
+	} `toml:"jury"`
+	Consensus struct {
+		Members []ConsensusMember `toml:"members"`
+	} `toml:"consensus"`
+	Severity struct {
+		Mapping map[string]string `toml:"mapping"`
+	} `toml:"severity"`
+	Verdict struct {
+		FailOn string `toml:"fail_on"`
+	} `toml:"verdict"`
+	Suppress struct {
+		Rules []SuppressRule `toml:"rules"`
+		// MinConfidence drops the findings the model is less sure of.
+		MinConfidence float64 `toml:"min_confidence"`
+	} `toml:"suppress"`
+	Ollama struct {
+		BaseURL string `toml:"base_url"`
+	} `toml:"ollama"`
+	GitHub struct {
+		// Token is used when GITHUB_TOKEN is unset.
+		Token string `toml:"token" secret:"true"`
+		// Host completes -pr references given without a host, such as
+		// org/repo#1; github.com by default.
+		Host string `toml:"host"`
+		// DiffTimeout is how many seconds gh pr diff may take, and
+		// MaxDiffBytes how large a diff may be before the review is
+		// refused.
+		DiffTimeout  int `toml:"diff_timeout"`
+		MaxDiffBytes int `toml:"max_diff_bytes"`
+	} `toml:"github"`
+	Bitbucket struct {
+		Username    string `toml:"username"`
+		AppPassword string `toml:"app_password" secret:"true"`
+	} `toml:"bitbucket"`
+	Gerrit struct {
+		// Username and HTTPPassword are only needed to post reviews, or
+		// for servers that do not allow anonymous reads.
+		Username     string `toml:"username"`
+		HTTPPassword string `toml:"http_password" secret:"true"`
+		// NoVote posts reviews without a Code-Review vote.
+		NoVote bool `toml:"no_vote"`
+	} `toml:"gerrit"`
+	Checklist struct {
+		Items []string `toml:"items"`
+	} `toml:"checklist"`
+	Output struct {
+		// Sections are the headings every review must have; a review
+		// lacking any is asked for once more.
+		Sections []string `toml:"sections"`
+	} `toml:"output"`
+	Webhook struct {
+		Secret string `toml:"secret" secret:"true"`
+	} `toml:"webhook"`
+	Notify struct {
+		// The incoming webhooks of the channels told about every PR review.
+		SlackWebhook string `toml:"slack_webhook" secret:"true"`
+		TeamsWebhook string `toml:"teams_webhook" secret:"true"`
+		OnlyRejected bool   `toml:"only_rejected"`
+	} `toml:"notify"`
+	Budget struct {
+		WarnUSD          float64 `toml:"warn_usd"`
+		AbortUSD         float64 `toml:"abort_usd"`
+		MonthlyUSD       float64 `toml:"monthly_usd"`
+		InputPerMillion  float64 `toml:"input_per_million"`
+		OutputPerMillion float64 `toml:"output_per_million"`
+	} `toml:"budget"`
+	Filters struct {
+		Exclude []string `toml:"exclude"`
+	} `toml:"filters"`
+	Redact struct {
+		// Disabled sends diffs without redacting the secrets found in
+		// them.
+		Disabled bool `toml:"disabled"`
+		// Patterns are more regular expressions of secrets; a pattern
+		// with a group redacts only the group.
+		Patterns []string `toml:"patterns"`
+	} `toml:"redact"`
+	Normalize struct {
+		// Enabled strips noise from the diff before it is sent; see
+		// normalizeDiff.
+		Enabled           bool `toml:"enabled"`
+		MaxLineLength     int  `toml:"max_line_length"`
+		MaxGeneratedLines int  `toml:"max_generated_lines"`
+	} `toml:"normalize"`
+	Mock struct {
+		Approve bool   `toml:"approve"`
+		Text    string `toml:"text"`
+	} `toml:"mock"`
+	Network networkConfig `toml:"network"`
+	// RateLimit caps the calls to each provider, keyed by its name.
+	RateLimit map[string]RateLimit `toml:"rate_limit"`
+}
+
+type networkConfig struct {
+	// ProxyURL is the http, https or socks5 proxy of the API calls,
+	// instead of HTTPS_PROXY.
+	ProxyURL   string `toml:"proxy_url" secret:"true"`
+	CACert     string `toml:"ca_cert"`
+	ClientCert string `toml:"client_cert"`
+	ClientKey  string `toml:"client_key"`
+	// MaxAttempts bounds the tries of an API call that keeps being
+	// rate limited or failing with a server error.
+	MaxAttempts int `toml:"max_attempts"`
+}
+
+// configOrigins records which source each setting was resolved from, keyed
+// by its dotted TOML name. Settings missing from it have their default.
+type configOrigins map[string]string
+
+func (o configOrigins) of(key string) string {
+	if origin, ok := o[key]; ok {
+		return origin
+	}
+	return originDefault
+}
+
+var errNoConfigFile = errors.New("no config file")
+
+func configPath() (string, error) {
+	// $HOME comes first, so the config can be pointed elsewhere, e.g. by
+	// tests.
+	if home, err := os.UserHomeDir(); err == nil {
+		return home + CONFIG_FOLDER + FILENAME, nil
+	}
+	currentUser, err := user.Current()
+	if err != nil {
+		return "", fmt.Errorf("Error getting current user")
+	}
+	return currentUser.HomeDir + CONFIG_FOLDER + FILENAME, nil
+}
+
+// configFile is the layout of the config file: the settings, and named
+// profiles of settings overriding them.
+type configFile struct {
+	FileConfig
+	Profile map[string]FileConfig `toml:"profile"`
+}
+
+// loadConfig loads the config file with the profile named by PRGPT_PROFILE,
+// if any.
+func loadConfig() (FileConfig, configOrigins, error) {
+	return loadConfigProfile(profileName(""))
+}
+
+// profileName is the profile given by -profile, or else by PRGPT_PROFILE.
+func profileName(flag string) string {
+	if flag != "" {
+		return flag
+	}
+	return os.Getenv("PRGPT_PROFILE")
+}
+
+// loadConfigProfile loads the config file, with the settings of the named
+// [profile.NAME] table, e.g. another provider and key for work, overriding
+// the top-level ones.
+func loadConfigProfile(profile string) (result FileConfig, origins configOrigins, err error) {
+	origins = configOrigins{}
+
+	path, err := configPath()
+	if err != nil {
+		return result, origins, err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return result, origins, fmt.Errorf("%w at %s; run \"prgpt init\" to create one", errNoConfigFile, path)
+	}
+	if err != nil {
+		return result, origins, fmt.Errorf("Error opening TOML file: %v", err)
+	}
+
+	// The config holds API keys, so it should only be readable by its owner.
+	if info, err := os.Stat(path); err == nil && runtime.GOOS != "windows" && info.Mode().Perm()&0o077 != 0 {
+		fmt.Fprintf(os.Stderr, "Warning: %s is accessible by other users (mode %04o); run \"chmod 600 %s\"\n", path, info.Mode().Perm(), path)
+	}
+
+	// Unmarshal the TOML content into a struct, rejecting misspelled or
+	// misplaced settings rather than silently ignoring them.
+	var file configFile
+	decoder := toml.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&file); err != nil {
+		return result, origins, configError(path, data, err)
+	}
+	result = file.FileConfig
+
+	var raw map[string]any
+	if err := toml.Unmarshal(data, &raw); err != nil {
+		return result, origins, nil
+	}
+	if err := checkJuryWeights(path, data, "", raw); err != nil {
+		return result, origins, err
+	}
+	profiles, _ := raw["profile"].(map[string]any)
+	delete(raw, "profile")
+	markOrigins(origins, raw, "", originHomeFile)
+
+	if profile == "" {
+		return result, origins, nil
+	}
+	settings, ok := profiles[profile].(map[string]any)
+	if !ok {
+		return result, origins, fmt.Errorf("no [profile.%s] in %s", profile, path)
+	}
+	if err := checkJuryWeights(path, data, "profile."+profile+".", settings); err != nil {
+		return result, origins, err
+	}
+	// Decoding only the profile's own settings over the others leaves the
+	// settings it does not mention as they are.
+	overrides, err := toml.Marshal(settings)
+	if err == nil {
+		err = toml.Unmarshal(overrides, &result)
+	}
+	if err != nil {
+		return result, origins, fmt.Errorf("error applying [profile.%s]: %v", profile, err)
+	}
+	markOrigins(origins, settings, "", originProfile)
+
+	return result, origins, nil
+}
+
+// configError points a decoding error at the offending line of the config
+// file.
+func configError(path string, data []byte, err error) error {
+	var missing *toml.StrictMissingError
+	if errors.As(err, &missing) {
+		var keys []string
+		for _, e := range missing.Errors {
+			row, _ := e.Position()
+			keys = append(keys, fmt.Sprintf("%s (line %d)", strings.Join(e.Key(), "."), row))
+		}
+		return fmt.Errorf("unknown setting(s) in %s: %s; run \"prgpt -explain-config\" to list the valid ones", path, strings.Join(keys, ", "))
+	}
+
+	var decodeErr *toml.DecodeError
+	if errors.As(err, &decodeErr) {
+		row, column := decodeErr.Position()
+		if strings.Contains(err.Error(), "cannot decode") {
+			key := settingAt(data, row)
+			if expected := expectedType(key); expected != "" {
+				return fmt.Errorf("error in %s at line %d: %s must be %s", path, row, key, expected)
+			}
+			return fmt.Errorf("error in %s at line %d: %s has the wrong type", path, row, key)
+		}
+		return fmt.Errorf("error parsing %s at line %d, column %d: %v\n%s", path, row, column, err, decodeErr.String())
+	}
+	return fmt.Errorf("error parsing %s: %v", path, err)
+}
+
+// settingAt returns the dotted name of the setting on the given line of a
+// TOML document.
+func settingAt(data []byte, row int) string {
+	var table string
+	lines := strings.Split(string(data), "\n")
+	for i := 0; i < row && i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(line, "[") {
+			table = strings.Trim(line, "[] ")
+		}
+	}
+	if row < 1 || row > len(lines) {
+		return table
+	}
+	name, _, ok := strings.Cut(lines[row-1], "=")
+	if !ok {
+		return table
+	}
+	name = strings.Trim(strings.TrimSpace(name), `"`)
+	if table == "" {
+		return name
+	}
+	return table + "." + name
+}
+
+// settingLines returns the lines of a TOML document that set the setting
+// with the given dotted name, in order.
+func settingLines(data []byte, key string) []int {
+	var rows []int
+	for row := 1; row <= strings.Count(string(data), "\n")+1; row++ {
+		if settingAt(data, row) == key {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// expectedType describes the type the named setting must have, or returns
+// "" when it does not know the setting.
+func expectedType(key string) string {
+	var description string
+	walkConfig(reflect.ValueOf(FileConfig{}), "", func(k string, field reflect.StructField, _ reflect.Value) {
+		if strings.HasPrefix(k, key+".") {
+			description = "a [" + key + "] table"
+		}
+		if k != key {
+			return
+		}
+		switch field.Type.Kind() {
+		case reflect.String:
+			description = "a string"
+		case reflect.Int:
+			description = "an integer"
+		case reflect.Float64:
+			description = "a number"
+		case reflect.Bool:
+			description = "true or false"
+		case reflect.Slice:
+			description = "a list"
+		case reflect.Map:
+			description = "a table"
+		}
+	})
+	return description
+}
+
+func markOrigins(origins configOrigins, raw map[string]any, prefix, origin string) {
+	for k, v := range raw {
+		if sub, ok := v.(map[string]any); ok {
+			markOrigins(origins, sub, prefix+k+".", origin)
+			continue
+		}
+		origins[prefix+k] = origin
+	}
+}
+
+type configSetting struct {
+	key    string
+	value  any
+	secret bool
+}
+
+// walkConfig calls fn with every leaf field of the config struct v and its
+// dotted TOML name.
+func walkConfig(v reflect.Value, prefix string, fn func(key string, field reflect.StructField, value reflect.Value)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("toml"), ",")[0]
+		if name == "" {
+			continue
+		}
+		if field.Type.Kind() == reflect.Struct {
+			walkConfig(v.Field(i), prefix+name+".", fn)
+			continue
+		}
+		fn(prefix+name, field, v.Field(i))
+	}
+}
+
+// configSettings lists every leaf setting of cfg with its dotted TOML name.
+func configSettings(cfg FileConfig) []configSetting {
+	var settings []configSetting
+	walkConfig(reflect.ValueOf(cfg), "", func(key string, field reflect.StructField, value reflect.Value) {
+		settings = append(settings, configSetting{
+			key:    key,
+			value:  value.Interface(),
+			secret: field.Tag.Get("secret") == "true",
+		})
+	})
+	return settings
+}
+
+// envAliases are the conventional variables of the providers' own tools,
+// read before the PRGPT_ ones.
+var envAliases = []struct{ env, key string }{
+	{"OPENAI_API_KEY", "apikey.key"},
+	{"ANTHROPIC_API_KEY", "anthropic.key"},
+	{"AZURE_OPENAI_API_KEY", "azure.key"},
+}
+
+// envName is the variable overriding a setting: PRGPT_ and its upper-cased
+// dotted name with underscores, e.g. PRGPT_MODEL_NAME for model.name.
+func envName(key string) string {
+	return "PRGPT_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// applyEnv overrides settings of cfg from the environment. Only string,
+// number and bool settings can be set this way. It returns how many
+// settings it changed.
+func applyEnv(cfg *FileConfig, origins configOrigins) (int, error) {
+	values := map[string]string{}
+	for _, alias := range envAliases {
+		if v, ok := os.LookupEnv(alias.env); ok {
+			values[alias.key] = v
+		}
+	}
+	// PRGPT_MODEL is short for PRGPT_MODEL_NAME.
+	if v, ok := os.LookupEnv("PRGPT_MODEL"); ok {
+		values["model.name"] = v
+	}
+
+	var err error
+	n := 0
+	walkConfig(reflect.ValueOf(cfg).Elem(), "", func(key string, field reflect.StructField, value reflect.Value) {
+		raw, ok := os.LookupEnv(envName(key))
+		if !ok {
+			raw, ok = values[key]
+		}
+		if !ok || err != nil {
+			return
+		}
+
+		switch value.Kind() {
+		case reflect.String:
+			value.SetString(raw)
+		case reflect.Int:
+			var i int64
+			i, err = strconv.ParseInt(raw, 10, 64)
+			value.SetInt(i)
+		case reflect.Float64:
+			var f float64
+			f, err = strconv.ParseFloat(raw, 64)
+			value.SetFloat(f)
+		case reflect.Bool:
+			var b bool
+			b, err = strconv.ParseBool(raw)
+			value.SetBool(b)
+		default:
+			return
+		}
+		if err != nil {
+			err = fmt.Errorf("invalid %s: %v", envName(key), err)
+			return
+		}
+		origins[key] = originEnv
+		n++
+	})
+	return n, err
+}
+
+// maskSetting masks the value of a secret setting; headers keep their names.
+func maskSetting(value any) string {
+	headers, ok := value.(map[string]string)
+	if !ok {
+		return maskSecret(fmt.Sprintf("%v", value))
+	}
+	masked := map[string]string{}
+	for name, v := range headers {
+		masked[name] = maskSecret(v)
+	}
+	return fmt.Sprintf("%v", masked)
+}
+
+func maskSecret(s string) string {
+	if s == "" {
+		return ""
+	}
+	if len(s) <= 8 {
+		return "****"
+	}
+	return "****" + s[len(s)-4:]
+}
+
+// explainConfig prints every config setting and flag with its resolved
+// value and the source it came from.
+func explainConfig(cfg FileConfig, origins configOrigins, fs *flag.FlagSet) {
+	fmt.Println("Config settings:")
+	for _, s := range configSettings(cfg) {
+		value := fmt.Sprintf("%v", s.value)
+		if s.secret {
+			value = maskSetting(s.value)
+		}
+		fmt.Printf("  %-24s %-40s (%s)\n", s.key, value, origins.of(s.key))
+	}
+
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	var names []string
+	fs.VisitAll(func(f *flag.Flag) { names = append(names, f.Name) })
+	sort.Strings(names)
+
+	fmt.Println("Flags:")
+	for _, name := range names {
+		origin := originDefault
+		if set[name] {
+			origin = originFlag
+		}
+		fmt.Printf("  -%-23s %-40s (%s)\n", name, fs.Lookup(name).Value.String(), origin)
+	}
+}
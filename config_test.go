@@ -0,0 +1,103 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestExplainConfigOrigins(t *testing.T) {
+	patch, err := filepath.Abs("testdata/replay/change.patch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const config = "provider = \"mock\"\n[model]\nname = \"home-model\"\n[apikey]\nkey = \"sk-home-secret-1234\"\n[profile.work.model]\nname = \"work-model\"\n"
+	tests := []struct {
+		name     string
+		env      map[string]string
+		repoFile string
+		args     []string
+		key      string
+		value    string
+		origin   string
+	}{
+		{"flag", nil, "", []string{"-model", "flag-model"}, "model.name", "flag-model", "flag"},
+		{"env", map[string]string{"PRGPT_MODEL_NAME": "env-model"}, "", nil, "model.name", "env-model", "env"},
+		{"flag over env", map[string]string{"PRGPT_MODEL_NAME": "env-model"}, "", []string{"-model", "flag-model"}, "model.name", "flag-model", "flag"},
+		{"profile", nil, "", []string{"-profile", "work"}, "model.name", "work-model", "profile"},
+		{"home file", nil, "", nil, "model.name", "home-model", "home file"},
+		{"repo file", nil, "[prompt]\ncustom = \"Check the migrations.\"\n", nil, "prompt.custom", "Check the migrations.", "repo file"},
+		{"default", nil, "", nil, "model.max_tokens", "0", "default"},
+		{"secret masked", nil, "", nil, "apikey.key", "****1234", "home file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useConfig(t, config)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			dir := t.TempDir()
+			if tt.repoFile != "" {
+				if err := os.WriteFile(filepath.Join(dir, repoConfigName), []byte(tt.repoFile), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			chdir(t, dir)
+
+			output, code := runPrgpt(t, append([]string{"-diff", patch, "-explain-config"}, tt.args...)...)
+			if code != exitApproved {
+				t.Fatalf("exit code = %d; output:\n%s", code, output)
+			}
+			line := regexp.MustCompile(`(?m)^  ` + regexp.QuoteMeta(tt.key) + ` +(.*?) +\((.*)\)$`).FindStringSubmatch(output)
+			if line == nil {
+				t.Fatalf("no %s in:\n%s", tt.key, output)
+			}
+			if line[1] != tt.value || line[2] != tt.origin {
+				t.Errorf("%s = %q (%s), want %q (%s)", tt.key, line[1], line[2], tt.value, tt.origin)
+			}
+		})
+	}
+}
+
+// chdir changes the working directory for the rest of the test.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func TestConfigErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		args   []string
+		want   string
+	}{
+		{"unknown key", "provider = \"mock\"\nbogus_key = 1\n", nil, "bogus_key"},
+		{"syntax error", "provider = \"mock\"\n[model\n", nil, "Error in config"},
+		{"missing profile", "provider = \"mock\"\n", []string{"-profile", "nope"}, "nope"},
+		{"zero jury weight", "provider = \"mock\"\n[jury]\nmodels = [{ name = \"a\" }, { name = \"b\", weight = 0 }]\n", nil, `at line 3: the weight of [jury] model "b" must be more than 0`},
+		{"negative jury weight", "provider = \"mock\"\n[[jury.models]]\nname = \"a\"\nweight = 1\n[[jury.models]]\nname = \"b\"\nweight = -0.5\n", nil, `at line 7: the weight of [jury] model "b"`},
+		{"jury weight of a profile", "provider = \"mock\"\n[profile.work.jury]\nmodels = [{ name = \"a\", weight = 0 }]\n", []string{"-profile", "work"}, `at line 3: the weight of [jury] model "a"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useConfig(t, tt.config)
+			output, code := runPrgpt(t, append([]string{"-diff", "testdata/replay/change.patch"}, tt.args...)...)
+			if code != exitError {
+				t.Errorf("exit code = %d, want %d", code, exitError)
+			}
+			if !strings.Contains(output, "Error in config") || !strings.Contains(output, tt.want) {
+				t.Errorf("output does not report the config error %q:\n%s", tt.want, output)
+			}
+		})
+	}
+}
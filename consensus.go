@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/loadfms/prgpt/pkg/prgpt"
+)
+
+// consensusLineSlack is how many lines apart two findings about the same
+// file may be and still count as the same finding.
+const consensusLineSlack = 3
+
+// ConsensusMember is one of the models -consensus asks; the provider
+// defaults to the configured one, and the model to the provider's default.
+type ConsensusMember struct {
+	Provider string `toml:"provider"`
+	Model    string `toml:"model"`
+}
+
+func (m ConsensusMember) String() string {
+	return m.Provider + "/" + m.Model
+}
+
+// consensusMembers resolves the [consensus] members and creates their
+// providers with newProvider.
+func consensusMembers(cfg FileConfig, newProvider func(name string) (prgpt.Provider, error)) ([]ConsensusMember, []prgpt.Provider, error) {
+	if len(cfg.Consensus.Members) < 2 {
+		return nil, nil, fmt.Errorf("-consensus needs at least two members in [consensus] members")
+	}
+	var members []ConsensusMember
+	var providers []prgpt.Provider
+	for _, m := range cfg.Consensus.Members {
+		if m.Provider == "" {
+			m.Provider = cfg.Provider
+		}
+		if m.Model == "" && m.Provider == cfg.Provider {
+			m.Model = cfg.Model.Name
+		}
+		if m.Model == "" {
+			m.Model = defaultModels[m.Provider]
+		}
+		p, err := newProvider(m.Provider)
+		if err != nil {
+			return nil, nil, fmt.Errorf("[consensus] member %s: %v", m, err)
+		}
+		members = append(members, m)
+		providers = append(providers, p)
+	}
+	return members, providers, nil
+}
+
+type consensusOpinion struct {
+	member   ConsensusMember
+	approved bool
+	findings []Finding
+	tokens   int
+	usage    map[string]tokenUsage
+	timings  *timings
+}
+
+// reviewConsensus sends the prompt to every member at the same time. The
+// first failure cancels the others.
+func (r *reviewer) reviewConsensus(members []ConsensusMember, providers []prgpt.Provider, prompt string, labels severityLabels) ([]consensusOpinion, error) {
+	if r.maxCalls > 0 && len(members) > r.maxCalls {
+		return nil, fmt.Errorf("-consensus needs %d API calls, more than -max-api-calls %d", len(members), r.maxCalls)
+	}
+
+	ctx, cancel := context.WithCancel(r.ctx)
+	defer cancel()
+
+	opinions := make([]consensusOpinion, len(members))
+	var mu sync.Mutex
+	var firstErr error
+	var wg sync.WaitGroup
+	for i, m := range members {
+		wg.Add(1)
+		go func(i int, m ConsensusMember) {
+			defer wg.Done()
+			member := &reviewer{ctx: ctx, provider: providers[i], model: m.Model, temperature: r.temperature, maxTokens: r.maxTokens, topP: r.topP, system: r.system, timings: &timings{}, pacer: r.pacer}
+			content, err := member.complete(prompt, nil)
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = fmt.Errorf("error reviewing with %s: %w", m, err)
+					cancel()
+				}
+				mu.Unlock()
+				return
+			}
+			approved, _ := prgpt.ParseVerdict(content)
+			opinions[i] = consensusOpinion{member: m, approved: approved, findings: parseFindings(content, labels), tokens: member.tokens, usage: member.usage, timings: member.timings}
+		}(i, m)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	// The members kept their own timings, as timings are not safe to track
+	// concurrently.
+	for _, o := range opinions {
+		r.calls++
+		r.tokens += o.tokens
+		for _, p := range o.timings.phases {
+			p.name = "api call " + o.member.String()
+			r.timings.phases = append(r.timings.phases, p)
+		}
+	}
+	return opinions, nil
+}
+
+// consensusFinding is a finding with the members that reported it.
+type consensusFinding struct {
+	Finding
+	by []string
+}
+
+// sameFinding tells whether two members' findings are about the same issue:
+// the same file and about the same line, or the same message when they
+// have no location.
+func sameFinding(a, b Finding) bool {
+	if a.File != b.File {
+		return false
+	}
+	if a.File == "" {
+		return strings.EqualFold(a.Message, b.Message)
+	}
+	if a.Line == 0 || b.Line == 0 {
+		return a.Line == b.Line
+	}
+	return max(a.Line-b.Line, b.Line-a.Line) <= consensusLineSlack
+}
+
+// mergeOpinions groups the members' findings by the issue they are about,
+// keeping the most severe and the message of the first member to report
+// it, and orders them from the most to the least severe.
+func mergeOpinions(opinions []consensusOpinion) []consensusFinding {
+	var merged []consensusFinding
+	for _, o := range opinions {
+		for _, f := range o.findings {
+			i := 0
+			for ; i < len(merged); i++ {
+				if sameFinding(merged[i].Finding, f) && !contains(merged[i].by, o.member.String()) {
+					break
+				}
+			}
+			if i == len(merged) {
+				merged = append(merged, consensusFinding{Finding: f})
+			} else if severityRank[f.Severity] > severityRank[merged[i].Severity] {
+				merged[i].Severity = f.Severity
+			}
+			merged[i].by = append(merged[i].by, o.member.String())
+		}
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		return severityRank[merged[i].Severity] > severityRank[merged[j].Severity]
+	})
+	return merged
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// formatConsensus writes the merged review: the findings every member
+// reported, those only some did, and the members' verdicts. The PR is
+// approved only when every member approves it.
+func formatConsensus(opinions []consensusOpinion, labels severityLabels) string {
+	var b strings.Builder
+	b.WriteString("## Consensus\n\n| Provider/model | Approved | Findings |\n|---|---|---|\n")
+	approved := true
+	for _, o := range opinions {
+		fmt.Fprintf(&b, "| %s | %t | %d |\n", o.member, o.approved, len(o.findings))
+		approved = approved && o.approved
+	}
+	for _, o := range opinions {
+		if o.approved != opinions[0].approved {
+			b.WriteString("\n**The models disagree on the verdict.** Check the disputed findings before acting on it.\n")
+			break
+		}
+	}
+
+	var agreed, disputed []consensusFinding
+	for _, f := range mergeOpinions(opinions) {
+		if len(f.by) == len(opinions) {
+			agreed = append(agreed, f)
+		} else {
+			disputed = append(disputed, f)
+		}
+	}
+	writeFindings := func(title string, findings []consensusFinding) {
+		fmt.Fprintf(&b, "\n## %s\n", title)
+		if len(findings) == 0 {
+			b.WriteString("None.\n")
+		}
+		for _, f := range findings {
+			location := f.File
+			if f.Line > 0 {
+				location = fmt.Sprintf("%s:%d", f.File, f.Line)
+			}
+			if location != "" {
+				location += " - "
+			}
+			source := ""
+			if len(f.by) < len(opinions) {
+				source = " (only " + strings.Join(f.by, ", ") + ")"
+			}
+			fmt.Fprintf(&b, "- [%s] %s%s%s\n", labels.label(f.Severity), location, f.Message, source)
+		}
+	}
+	writeFindings("Findings all models agree on", agreed)
+	writeFindings("Findings only some models reported", disputed)
+
+	fmt.Fprintf(&b, "\nApproved: %t\n", approved)
+	return b.String()
+}
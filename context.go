@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	repoContextPath          = ".prgpt/context.md"
+	defaultContextTokenLimit = 2000
+)
+
+// fetchRepoContext fetches .prgpt/context.md from the PR's repository, or
+// for reviews of no PR the one found from the current directory. A
+// repository without the file yields an empty context.
+func fetchRepoContext(ctx context.Context, fg forge, prURL string) (string, error) {
+	if prURL == "" {
+		path, ok := findRepoFile(repoContextPath)
+		if !ok {
+			return "", nil
+		}
+		output, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("error opening %s: %v", path, err)
+		}
+		return string(output), nil
+	}
+
+	output, err := fg.RepoFile(ctx, prURL, repoContextPath)
+	if err != nil {
+		return "", fmt.Errorf("error fetching %s: %v", repoContextPath, err)
+	}
+	return string(output), nil
+}
+
+// truncateToTokens cuts s at a line boundary so it fits in roughly maxTokens,
+// estimating the tokens of one line at a time. A first line too long on its
+// own is cut at the usual four bytes per token instead.
+func truncateToTokens(s string, maxTokens int) (string, bool) {
+	tokens, end := 0, 0
+	for end < len(s) {
+		next := len(s)
+		if i := strings.IndexByte(s[end:], '\n'); i >= 0 {
+			next = end + i + 1
+		}
+		tokens += estimateTokens(s[end:next])
+		if tokens > maxTokens {
+			break
+		}
+		end = next
+	}
+	if end == len(s) {
+		return s, false
+	}
+	if end == 0 {
+		end = min(len(s), max(maxTokens, 0)*4)
+		for end > 0 && end < len(s) && !utf8.RuneStart(s[end]) {
+			end--
+		}
+	}
+	return strings.TrimSuffix(s[:end], "\n"), true
+}
+
+func loadRepoContext(ctx context.Context, fg forge, prURL string, maxTokens int) string {
+	if maxTokens <= 0 {
+		maxTokens = defaultContextTokenLimit
+	}
+
+	repoContext, err := fetchRepoContext(ctx, fg, prURL)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Warning: ignoring repository context:", err)
+		return ""
+	}
+
+	repoContext, truncated := truncateToTokens(strings.TrimSpace(repoContext), maxTokens)
+	if truncated {
+		fmt.Fprintf(os.Stderr, "Warning: %s exceeds %d tokens and was truncated\n", repoContextPath, maxTokens)
+	}
+
+	if repoContext == "" {
+		return ""
+	}
+	return "Architecture, conventions and gotchas described by the maintainers:\n" + repoContext
+}
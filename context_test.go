@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTruncateToTokens(t *testing.T) {
+	lines := strings.Repeat("func main() { fmt.Println(\"hello\") }\n", 100)
+	tests := []struct {
+		name      string
+		s         string
+		maxTokens int
+		truncated bool
+	}{
+		{"fits", "one line\ntwo lines\n", 100, false},
+		{"empty", "", 10, false},
+		{"many lines", lines, 50, true},
+		{"one long line", strings.Repeat("abcdefgh", 1000), 100, true},
+		{"multi-byte line", strings.Repeat("日本語のテキスト", 500), 100, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, truncated := truncateToTokens(tt.s, tt.maxTokens)
+			if truncated != tt.truncated {
+				t.Fatalf("truncated = %t, want %t", truncated, tt.truncated)
+			}
+			if !truncated {
+				if got != tt.s {
+					t.Errorf("got %q, want it unchanged", got)
+				}
+				return
+			}
+			if got == "" || !strings.HasPrefix(tt.s, got) {
+				t.Errorf("got %q, want a non-empty prefix of the input", got)
+			}
+			if n := estimateTokens(got); n > tt.maxTokens*11/10 {
+				t.Errorf("got ~%d tokens, want about %d", n, tt.maxTokens)
+			}
+			if strings.Contains(tt.s, "\n") && !strings.HasPrefix(tt.s[len(got):], "\n") {
+				t.Errorf("cut mid-line: %q", got[max(len(got)-20, 0):])
+			}
+		})
+	}
+}
+
+func TestLoadRepoContext(t *testing.T) {
+	tests := []struct {
+		name  string
+		file  string
+		found bool
+		want  string
+	}{
+		{"present", "Handlers never log secrets.\n", true, "Architecture, conventions and gotchas described by the maintainers:\nHandlers never log secrets."},
+		{"missing", "", false, ""},
+		{"blank", "  \n", true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/v3/repos/o/r/contents/"+repoContextPath || !tt.found {
+					http.NotFound(w, r)
+					return
+				}
+				w.Write([]byte(tt.file))
+			}))
+			defer server.Close()
+
+			github := githubForge{api: &githubAPI{client: server.Client(), token: "t"}}
+			got := loadRepoContext(context.Background(), github, server.URL+"/o/r/pull/1", 0)
+			if got != tt.want {
+				t.Errorf("loadRepoContext() = %q, want %q", got, tt.want)
+			}
+			if prompt := buildPrompt("diff --git a/x b/x\n", got, "", "Review it."); !strings.Contains(prompt, tt.want) {
+				t.Errorf("prompt lacks the context:\n%s", prompt)
+			}
+		})
+	}
+}
+
+func TestLoadRepoContextLocal(t *testing.T) {
+	const want = "Architecture, conventions and gotchas described by the maintainers:\nHandlers never log secrets."
+	tests := []struct {
+		name    string
+		context string // where .prgpt/context.md is, relative to the root
+		cwd     string
+		want    string
+	}{
+		{"at the root", ".", ".", want},
+		{"from a subdirectory", ".", "cmd/server", want},
+		{"above the repository", "..", "cmd", ""},
+		{"missing", "", ".", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := filepath.Join(t.TempDir(), "repo")
+			for _, dir := range []string{".git", tt.cwd} {
+				if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.context != "" {
+				path := filepath.Join(root, tt.context, filepath.FromSlash(repoContextPath))
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte("Handlers never log secrets.\n"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			chdir(t, filepath.Join(root, tt.cwd))
+
+			if got := loadRepoContext(context.Background(), githubForge{}, "", 0); got != tt.want {
+				t.Errorf("loadRepoContext() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGerritRepoFile(t *testing.T) {
+	tests := []struct {
+		name  string
+		found bool
+		want  string
+	}{
+		{"present", true, "Handlers never log secrets.\n"},
+		{"missing", false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.URL.Path == "/changes/proj~12":
+					w.Write([]byte(gerritXSSIPrefix + `{"project":"proj","branch":"main"}`))
+				case r.URL.EscapedPath() == "/projects/proj/branches/main/files/.prgpt%2Fcontext.md/content" && tt.found:
+					w.Write([]byte(base64.StdEncoding.EncodeToString([]byte(tt.want))))
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer server.Close()
+
+			got, err := gerritForge{client: server.Client()}.RepoFile(context.Background(), server.URL+"/c/proj/+/12", repoContextPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want || (got == nil) == tt.found {
+				t.Errorf("RepoFile() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// defaultReplyTokens is the reply length assumed when [model] max_tokens
+// does not bound it.
+const defaultReplyTokens = 1000
+
+// modelPrice is what a model costs in US dollars per million tokens.
+type modelPrice struct {
+	Input  float64 `toml:"input_per_million"`
+	Output float64 `toml:"output_per_million"`
+}
+
+// modelPrices maps model name prefixes to list prices. The longest
+// matching prefix wins.
+var modelPrices = map[string]modelPrice{
+	"gpt-3.5-turbo":     {Input: 1, Output: 2},
+	"gpt-4":             {Input: 30, Output: 60},
+	"gpt-4-turbo":       {Input: 10, Output: 30},
+	"gpt-4o":            {Input: 2.5, Output: 10},
+	"gpt-4o-mini":       {Input: 0.15, Output: 0.6},
+	"gpt-4.1":           {Input: 2, Output: 8},
+	"gpt-4.1-mini":      {Input: 0.4, Output: 1.6},
+	"o1":                {Input: 15, Output: 60},
+	"o3-mini":           {Input: 1.1, Output: 4.4},
+	"claude-3-haiku":    {Input: 0.25, Output: 1.25},
+	"claude-3-5-haiku":  {Input: 0.8, Output: 4},
+	"claude-3-5-sonnet": {Input: 3, Output: 15},
+	"claude-3-opus":     {Input: 15, Output: 75},
+}
+
+// priceFor returns the configured price, or the list price of the model.
+func priceFor(model string, configured modelPrice) (modelPrice, bool) {
+	if configured.Input > 0 || configured.Output > 0 {
+		return configured, true
+	}
+	price, matched := modelPrice{}, ""
+	for prefix, p := range modelPrices {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(matched) {
+			price, matched = p, prefix
+		}
+	}
+	return price, matched != ""
+}
+
+// costEstimate is the expected usage of a run before any call is made.
+type costEstimate struct {
+	Calls        int
+	PromptTokens int
+	ReplyTokens  int
+	Cost         float64
+	Priced       bool
+}
+
+func estimateCost(model string, configured modelPrice, promptTokens, calls, replyTokens int) costEstimate {
+	if replyTokens <= 0 {
+		replyTokens = defaultReplyTokens
+	}
+	e := costEstimate{Calls: calls, PromptTokens: promptTokens, ReplyTokens: calls * replyTokens}
+	price, ok := priceFor(model, configured)
+	if ok {
+		e.Priced = true
+		e.Cost = (float64(e.PromptTokens)*price.Input + float64(e.ReplyTokens)*price.Output) / 1e6
+	}
+	return e
+}
+
+// times is the estimate of running the same review n times.
+func (e costEstimate) times(n int) costEstimate {
+	e.Calls *= n
+	e.PromptTokens *= n
+	e.ReplyTokens *= n
+	e.Cost *= float64(n)
+	return e
+}
+
+func (e costEstimate) String() string {
+	s := fmt.Sprintf("%d API call(s), ~%d prompt tokens, up to ~%d reply tokens", e.Calls, e.PromptTokens, e.ReplyTokens)
+	if !e.Priced {
+		return s + ", cost unknown (set [budget] input_per_million and output_per_million)"
+	}
+	return s + fmt.Sprintf(", ~$%.4f", e.Cost)
+}
+
+// estimate predicts the calls and tokens of the review about to run, once
+// per model of a -jury or -consensus review.
+func (r *reviewer) estimate(prompt, prDiff, repoContext string, chunked bool, models []string, cfg FileConfig) (costEstimate, error) {
+	promptTokens, calls := estimateTokens(prompt), 1
+	if chunked {
+		files, prompts, err := r.chunkPrompts(splitDiffFiles(prDiff), repoContext)
+		if err != nil {
+			return costEstimate{}, err
+		}
+		promptTokens = 0
+		for _, p := range prompts {
+			promptTokens += estimateTokens(p)
+		}
+		// The summary call reads every per-file review.
+		replyTokens := r.maxTokens
+		if replyTokens <= 0 {
+			replyTokens = defaultReplyTokens
+		}
+		promptTokens += len(files) * replyTokens
+		calls = len(files) + 1
+	}
+
+	price := modelPrice{Input: cfg.Budget.InputPerMillion, Output: cfg.Budget.OutputPerMillion}
+	if len(models) == 0 {
+		return estimateCost(r.model, price, promptTokens, calls, r.maxTokens), nil
+	}
+
+	total := costEstimate{Priced: true}
+	for _, model := range models {
+		e := estimateCost(model, price, promptTokens, calls, r.maxTokens)
+		total.Calls += e.Calls
+		total.PromptTokens += e.PromptTokens
+		total.ReplyTokens += e.ReplyTokens
+		total.Cost += e.Cost
+		total.Priced = total.Priced && e.Priced
+	}
+	return total, nil
+}
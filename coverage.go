@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+const defaultMinTestRatio = 0.2
+
+var sourceExtensions = map[string]bool{
+	".go": true, ".py": true, ".js": true, ".jsx": true, ".ts": true, ".tsx": true,
+	".java": true, ".kt": true, ".rb": true, ".rs": true, ".c": true, ".cc": true,
+	".cpp": true, ".h": true, ".hpp": true, ".cs": true, ".php": true, ".swift": true,
+	".scala": true,
+}
+
+func isTestFile(p string) bool {
+	base := path.Base(p)
+	switch {
+	case strings.HasSuffix(base, "_test.go"),
+		strings.HasPrefix(base, "test_") && strings.HasSuffix(base, ".py"),
+		strings.HasSuffix(base, "_test.py"),
+		strings.Contains(base, ".test."),
+		strings.Contains(base, ".spec."),
+		strings.HasSuffix(strings.TrimSuffix(base, path.Ext(base)), "Test"):
+		return true
+	}
+	for _, dir := range strings.Split(path.Dir(p), "/") {
+		if dir == "test" || dir == "tests" || dir == "__tests__" || dir == "testdata" {
+			return true
+		}
+	}
+	return false
+}
+
+type coverageHint struct {
+	testLines   int
+	sourceLines int
+	minRatio    float64
+}
+
+func computeCoverageHint(files []fileDiff, minRatio float64) coverageHint {
+	if minRatio <= 0 {
+		minRatio = defaultMinTestRatio
+	}
+
+	hint := coverageHint{minRatio: minRatio}
+	for _, f := range files {
+		switch {
+		case isTestFile(f.Path):
+			hint.testLines += countChangedLines(f.Text)
+		case sourceExtensions[path.Ext(f.Path)]:
+			hint.sourceLines += countChangedLines(f.Text)
+		}
+	}
+	return hint
+}
+
+func (h coverageHint) ratio() float64 {
+	if h.sourceLines == 0 {
+		return 0
+	}
+	return float64(h.testLines) / float64(h.sourceLines)
+}
+
+func (h coverageHint) low() bool {
+	return h.sourceLines > 0 && h.ratio() < h.minRatio
+}
+
+func (h coverageHint) String() string {
+	if h.sourceLines == 0 {
+		return fmt.Sprintf("test-to-code change ratio: n/a (no source changes, %d test lines changed)", h.testLines)
+	}
+
+	s := fmt.Sprintf("test-to-code change ratio: %.2f (%d test lines, %d source lines changed)", h.ratio(), h.testLines, h.sourceLines)
+	if h.low() {
+		s += fmt.Sprintf(", below the expected %.2f", h.minRatio)
+	}
+	return s
+}
+
+func (h coverageHint) prompt() string {
+	s := "Deterministic signal: " + h.String() + "."
+	if h.low() {
+		s += " Consider whether the changes are adequately tested."
+	}
+	return s
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+// changedFile is the diff of a file with n added lines.
+func changedFile(path string, n int) fileDiff {
+	text := fmt.Sprintf("diff --git a/%s b/%s\n--- a/%s\n+++ b/%s\n@@ -0,0 +1,%d @@\n", path, path, path, path, n)
+	text += strings.Repeat("+x\n", n)
+	return fileDiff{Path: path, Text: text}
+}
+
+func TestIsTestFile(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"pkg/prgpt/review_test.go", true},
+		{"test_api.py", true},
+		{"api_test.py", true},
+		{"web/app.test.ts", true},
+		{"web/app.spec.js", true},
+		{"src/main/java/FooTest.java", true},
+		{"tests/helpers.rb", true},
+		{"testdata/replay/change.patch", true},
+		{"pkg/prgpt/review.go", false},
+		{"latest/api.py", false},
+		{"contest.go", false},
+	}
+	for _, tt := range tests {
+		if got := isTestFile(tt.path); got != tt.want {
+			t.Errorf("isTestFile(%q) = %t, want %t", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestCoverageHint(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    []fileDiff
+		minRatio float64
+		ratio    float64
+		low      bool
+		want     string
+	}{
+		{
+			name:  "well tested",
+			files: []fileDiff{changedFile("a.go", 10), changedFile("a_test.go", 5)},
+			ratio: 0.5,
+			want:  "test-to-code change ratio: 0.50 (5 test lines, 10 source lines changed)",
+		},
+		{
+			name:  "below the default ratio",
+			files: []fileDiff{changedFile("a.go", 20), changedFile("a_test.go", 2)},
+			ratio: 0.1,
+			low:   true,
+			want:  "test-to-code change ratio: 0.10 (2 test lines, 20 source lines changed), below the expected 0.20",
+		},
+		{
+			name:     "below a configured ratio",
+			files:    []fileDiff{changedFile("a.go", 10), changedFile("a_test.go", 5)},
+			minRatio: 0.8,
+			ratio:    0.5,
+			low:      true,
+			want:     "test-to-code change ratio: 0.50 (5 test lines, 10 source lines changed), below the expected 0.80",
+		},
+		{
+			name:  "other files are not counted",
+			files: []fileDiff{changedFile("a.go", 4), changedFile("a_test.go", 4), changedFile("README.md", 100), changedFile("go.sum", 30)},
+			ratio: 1,
+			want:  "test-to-code change ratio: 1.00 (4 test lines, 4 source lines changed)",
+		},
+		{
+			name:  "no source changes",
+			files: []fileDiff{changedFile("README.md", 3), changedFile("a_test.go", 7)},
+			want:  "test-to-code change ratio: n/a (no source changes, 7 test lines changed)",
+		},
+		{
+			name:  "untested change",
+			files: []fileDiff{changedFile("a.go", 3)},
+			low:   true,
+			want:  "test-to-code change ratio: 0.00 (0 test lines, 3 source lines changed), below the expected 0.20",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hint := computeCoverageHint(tt.files, tt.minRatio)
+			if math.Abs(hint.ratio()-tt.ratio) > 1e-9 || hint.low() != tt.low {
+				t.Errorf("ratio = %g, low = %t, want %g, %t", hint.ratio(), hint.low(), tt.ratio, tt.low)
+			}
+			if got := hint.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+			if strings.Contains(hint.prompt(), "adequately tested") != tt.low {
+				t.Errorf("prompt() = %q", hint.prompt())
+			}
+		})
+	}
+}
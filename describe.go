@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/loadfms/prgpt/pkg/prgpt"
+)
+
+const describePrompt = `Write a description for the pull request with the diff below. Reply in exactly this format and nothing else:
+
+Title: <a concise title in the imperative mood, under 72 characters>
+
+## Summary
+<one short paragraph on what the change does and why>
+
+## Changes
+- <one changelog-style bullet per notable change>
+
+Diff:
+%s`
+
+// parseDescription splits the model's reply into the PR title and the body.
+func parseDescription(reply string) (title, body string) {
+	reply = strings.TrimSpace(reply)
+	first, rest, _ := strings.Cut(reply, "\n")
+	if t, ok := strings.CutPrefix(strings.TrimSpace(first), "Title:"); ok {
+		return strings.TrimSpace(t), strings.TrimSpace(rest)
+	}
+	return "", reply
+}
+
+// updatePRBody replaces the description of a GitHub PR.
+func updatePRBody(ctx context.Context, prURL, body string) error {
+	cmd := prgpt.Command(ctx, "gh", "pr", "edit", prURL, "--body-file", "-")
+	cmd.Stdin = strings.NewReader(body)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("error running gh pr edit: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// runDescribe implements "prgpt describe": a title, summary and changelog
+// for a PR, written from the same diff a review would see.
+func runDescribe(args []string) int {
+	fs := newCommandFlagSet("describe")
+	prURL := fs.String("pr", "", "URL of the PR to describe")
+	local := fs.Bool("local", false, "Describe the changes of the local working tree")
+	staged := fs.Bool("staged", false, "With -local, only describe the staged changes")
+	base := fs.String("base", "", "With -local, describe the changes since this ref")
+	diffFile := fs.String("diff", "", "Describe the patch in this file, or on stdin for -")
+	profile := fs.String("profile", "", "Use the settings of [profile.NAME] in config.toml over the others; PRGPT_PROFILE sets the default")
+	backend := fs.String("backend", "", "Provider to use (openai, azure, anthropic, ollama)")
+	model := fs.String("model", "", "Model to use")
+	lang := fs.String("lang", "", "Language to write the description in")
+	update := fs.Bool("update", false, "Replace the body of the GitHub PR with the description")
+	if err := fs.Parse(args); err != nil {
+		return parseExit(err)
+	}
+
+	sources := 0
+	for _, set := range []bool{*prURL != "", *local, *diffFile != ""} {
+		if set {
+			sources++
+		}
+	}
+	if sources != 1 {
+		fmt.Println("prgpt describe needs exactly one of -pr, -local and -diff")
+		return exitError
+	}
+	if *staged && *base != "" {
+		fmt.Println("-staged cannot be combined with -base")
+		return exitError
+	}
+
+	cfg, err := loadCommandConfig(*profile, *backend, *model, *lang)
+	if err != nil {
+		fmt.Println(err)
+		return exitError
+	}
+
+	if *prURL != "" {
+		*prURL = normalizePRURL(*prURL, cfg.GitHub.Host)
+	}
+	if *update && (*prURL == "" || !isGitHubURL(*prURL)) {
+		fmt.Println("-update is only supported for GitHub PRs")
+		return exitError
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	client, err := newHTTPClient(cfg)
+	if err != nil {
+		fmt.Println("Error configuring HTTP client:", err)
+		return exitError
+	}
+
+	var prDiff string
+	switch {
+	case *diffFile != "":
+		prDiff, err = prgpt.PatchFile{Path: *diffFile}.Diff(ctx)
+	case *local:
+		prDiff, err = prgpt.LocalDiff{Staged: *staged, Base: *base}.Diff(ctx)
+	default:
+		prDiff, err = forgeFor(*prURL, cfg, client).Diff(ctx, *prURL)
+	}
+	if err != nil {
+		fmt.Println("Error fetching diff:", err)
+		return exitError
+	}
+
+	if len(cfg.Filters.Exclude) > 0 {
+		files, _ := excludeFiles(splitDiffFiles(prDiff), cfg.Filters.Exclude)
+		prDiff = joinDiffFiles(files)
+	}
+	if prDiff, err = redactDiff(cfg, prDiff); err != nil {
+		fmt.Println(err)
+		return exitError
+	}
+	if strings.TrimSpace(prDiff) == "" {
+		fmt.Println("The diff is empty; nothing to describe.")
+		return exitError
+	}
+	// A description needs the gist of the change rather than every line.
+	budget := promptBudget(contextWindowFor(cfg.Model.Name, cfg.Model.ContextWindow))
+	if estimateTokens(prDiff) > budget {
+		fmt.Fprintln(os.Stderr, "Warning: the diff exceeds the context window of", cfg.Model.Name+"; describing its beginning only")
+		prDiff, _ = truncateToTokens(prDiff, budget-estimateTokens(describePrompt))
+	}
+
+	reply, err := completeOnce(ctx, cfg, client, *prURL, fmt.Sprintf(describePrompt, prDiff))
+	if err != nil {
+		fmt.Println("Error generating description:", err)
+		if hint := apiErrorHint(err); hint != "" {
+			fmt.Println("Hint:", hint)
+		}
+		return exitError
+	}
+
+	title, body := parseDescription(reply)
+	if title != "" {
+		fmt.Printf("# %s\n\n", title)
+	}
+	fmt.Println(body)
+
+	if *update {
+		if err := updatePRBody(ctx, *prURL, body); err != nil {
+			fmt.Println("Error updating PR:", err)
+			return exitError
+		}
+		fmt.Fprintln(os.Stderr, "Updated the description of", *prURL)
+	}
+	return exitApproved
+}
+
+// loadCommandConfig loads the config for a subcommand such as describe,
+// with its -profile, -backend, -model and -lang flags applied.
+func loadCommandConfig(profile, backend, model, lang string) (FileConfig, error) {
+	cfg, origins, err := loadConfigProfile(profileName(profile))
+	if _, envErr := applyEnv(&cfg, origins); envErr != nil {
+		return cfg, fmt.Errorf("error in environment: %v", envErr)
+	}
+	if err != nil && !errors.Is(err, errNoConfigFile) {
+		return cfg, fmt.Errorf("error in config: %v", err)
+	}
+	if backend != "" {
+		cfg.Provider = backend
+	}
+	if cfg.Provider == "" {
+		cfg.Provider = defaultProvider
+	}
+	if model != "" {
+		cfg.Model.Name = model
+	}
+	if cfg.Model.Name == "" {
+		cfg.Model.Name = defaultModels[cfg.Provider]
+	}
+	if lang != "" {
+		cfg.Language = lang
+	}
+	if origins.of("model.temperature") == originDefault {
+		cfg.Model.Temperature = prgpt.DefaultTemperature
+	}
+	return cfg, nil
+}
+
+// completeOnce sends a single prompt with the configured provider and model,
+// recording the tokens it used against pr.
+func completeOnce(ctx context.Context, cfg FileConfig, client *http.Client, pr, prompt string) (string, error) {
+	provider, err := newProvider(cfg.Provider, cfg, client)
+	if err != nil {
+		return "", fmt.Errorf("error configuring provider: %v", err)
+	}
+	r := &reviewer{ctx: ctx, provider: provider, model: cfg.Model.Name, temperature: cfg.Model.Temperature, maxTokens: cfg.Model.MaxTokens, topP: cfg.Model.TopP, system: withLanguage("", cfg.Language), timings: &timings{}}
+	reply, err := r.complete(prompt, nil)
+	if cfg.Provider != "mock" {
+		price := modelPrice{Input: cfg.Budget.InputPerMillion, Output: cfg.Budget.OutputPerMillion}
+		if err := recordUsage(pr, cfg.Provider, r.usage, price); err != nil {
+			fmt.Fprintln(os.Stderr, "Warning: could not record usage:", err)
+		}
+	}
+	return reply, err
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"strings"
+)
+
+type fileDiff struct {
+	Path string
+	Text string
+}
+
+var fileHeaderPrefixes = []string{"diff --git ", "diff --cc ", "diff --combined "}
+
+func isFileHeader(line string) bool {
+	for _, prefix := range fileHeaderPrefixes {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitDiffFiles splits a unified or combined diff into one entry per file.
+// Anything before the first file header is dropped.
+func splitDiffFiles(diff string) []fileDiff {
+	var files []fileDiff
+	// Each file's text is a slice of the diff rather than a copy.
+	start := 0
+	offset := 0
+	for offset < len(diff) {
+		next := len(diff)
+		if i := strings.IndexByte(diff[offset:], '\n'); i >= 0 {
+			next = offset + i + 1
+		}
+		line := diff[offset:next]
+		if isFileHeader(line) {
+			if len(files) > 0 {
+				files[len(files)-1].Text = diff[start:offset]
+			}
+			files = append(files, fileDiff{Path: diffHeaderPath(line)})
+			start = offset
+		}
+		if len(files) > 0 && strings.HasPrefix(line, "+++ b/") {
+			files[len(files)-1].Path = strings.TrimSpace(strings.TrimPrefix(line, "+++ b/"))
+		}
+		offset = next
+	}
+	if len(files) > 0 {
+		files[len(files)-1].Text = diff[start:]
+	}
+
+	return files
+}
+
+func diffHeaderPath(header string) string {
+	header = strings.TrimSpace(header)
+	if i := strings.LastIndex(header, " b/"); i >= 0 {
+		return header[i+3:]
+	}
+	for _, prefix := range fileHeaderPrefixes {
+		header = strings.TrimPrefix(header, prefix)
+	}
+	return header
+}
+
+func joinDiffFiles(files []fileDiff) string {
+	var b strings.Builder
+	for _, f := range files {
+		b.WriteString(f.Text)
+	}
+	return b.String()
+}
+
+// truncateFileDiffs cuts every file diff longer than maxLines, marking the
+// cut, so one huge file cannot crowd the others out of the prompt. It
+// returns the paths of the files it truncated.
+func truncateFileDiffs(files []fileDiff, maxLines int) (truncated []string) {
+	for i, f := range files {
+		lines := strings.SplitAfter(f.Text, "\n")
+		if lines[len(lines)-1] == "" {
+			lines = lines[:len(lines)-1]
+		}
+		if len(lines) <= maxLines {
+			continue
+		}
+
+		files[i].Text = strings.Join(lines[:maxLines], "") + "[file diff truncated]\n"
+		truncated = append(truncated, f.Path)
+	}
+	return truncated
+}
+
+// countChangedLines counts added and removed lines, ignoring file headers.
+func countChangedLines(diff string) int {
+	n := 0
+	for _, line := range strings.Split(diff, "\n") {
+		if strings.HasPrefix(line, "+++") || strings.HasPrefix(line, "---") {
+			continue
+		}
+		if strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-") {
+			n++
+		}
+	}
+	return n
+}
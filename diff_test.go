@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTruncateFileDiffs(t *testing.T) {
+	fileDiffText := func(path string, added int) string {
+		var b strings.Builder
+		fmt.Fprintf(&b, "diff --git a/%s b/%s\n--- a/%s\n+++ b/%s\n@@ -0,0 +1,%d @@\n", path, path, path, path, added)
+		for i := 0; i < added; i++ {
+			fmt.Fprintf(&b, "+line %d\n", i)
+		}
+		return b.String()
+	}
+	small, large := fileDiffText("main.go", 3), fileDiffText("package-lock.json", 500)
+
+	tests := []struct {
+		name      string
+		diff      string
+		maxLines  int
+		truncated []string
+	}{
+		{"one oversized file", small + large + small, 20, []string{"package-lock.json"}},
+		{"nothing oversized", small + small, 20, nil},
+		{"exactly at the limit", fileDiffText("a.go", 15), 20, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := splitDiffFiles(tt.diff)
+			before := splitDiffFiles(tt.diff)
+			truncated := truncateFileDiffs(files, tt.maxLines)
+			if !reflect.DeepEqual(truncated, tt.truncated) {
+				t.Errorf("truncated %q, want %q", truncated, tt.truncated)
+			}
+			for i, f := range files {
+				if len(tt.truncated) > 0 && f.Path == tt.truncated[0] {
+					if !strings.HasSuffix(f.Text, "\n[file diff truncated]\n") || strings.Count(f.Text, "\n") != tt.maxLines+1 {
+						t.Errorf("%s was not cut at %d lines:\n%s", f.Path, tt.maxLines, f.Text)
+					}
+					continue
+				}
+				if f.Text != before[i].Text {
+					t.Errorf("%s changed:\n%s", f.Path, f.Text)
+				}
+			}
+		})
+	}
+}
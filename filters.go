@@ -0,0 +1,61 @@
+package main
+
+import "strings"
+
+// stringList is a flag that can be given several times.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// excludeFiles drops the file diffs whose path matches any of the glob
+// patterns and returns the paths it dropped.
+func excludeFiles(files []fileDiff, patterns []string) (kept []fileDiff, excluded []string) {
+	for _, f := range files {
+		if matchesAny(patterns, f.Path) {
+			excluded = append(excluded, f.Path)
+			continue
+		}
+		kept = append(kept, f)
+	}
+	return kept, excluded
+}
+
+// includeFiles keeps only the file diffs whose path matches any of the glob
+// patterns and returns the paths it dropped.
+func includeFiles(files []fileDiff, patterns []string) (kept []fileDiff, excluded []string) {
+	for _, f := range files {
+		if !matchesAny(patterns, f.Path) {
+			excluded = append(excluded, f.Path)
+			continue
+		}
+		kept = append(kept, f)
+	}
+	return kept, excluded
+}
+
+// splitPatterns splits a comma-separated list of glob patterns.
+func splitPatterns(list string) []string {
+	var patterns []string
+	for _, p := range strings.Split(list, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+func matchesAny(patterns []string, path string) bool {
+	for _, pattern := range patterns {
+		if globMatch(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
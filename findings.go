@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	findingLine     = regexp.MustCompile(`^\s*[-*]\s+\*{0,2}\[([^\]]+)\]\*{0,2}\s*(.*)$`)
+	findingLocation = regexp.MustCompile("^`?([\\w.\\-/]*[./][\\w.\\-/]*)(?::(\\d+)(?:-(\\d+))?)?`?\\s*(?:-|–|—|:)\\s+(.*)$")
+	// findingConfidence matches the confidence ending a finding, e.g.
+	// "(confidence 0.8)".
+	findingConfidence = regexp.MustCompile(`\s*\((?i:confidence):?\s*([01](?:\.\d+)?)\)\.?$`)
+)
+
+const confidenceInstruction = "End every finding with how confident you are that it is a real issue rather than a guess, from 0 to 1, e.g. \"(confidence 0.8)\"."
+
+// Finding is a single issue reported by the model.
+type Finding struct {
+	Severity string `json:"severity"`
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	// EndLine is the last line of a finding about a range of lines.
+	EndLine int    `json:"end_line,omitempty"`
+	Message string `json:"message"`
+	// Suggestion is the replacement the model proposed for the lines, from
+	// a suggestion block below the finding.
+	Suggestion string `json:"suggestion,omitempty"`
+	// CWE identifies the weakness of a security finding, e.g. "CWE-89".
+	CWE string `json:"cwe,omitempty"`
+	// Confidence is how sure the model is that the issue is real, from 0
+	// to 1, or nil when it did not say.
+	Confidence *float64 `json:"confidence,omitempty"`
+
+	// raw is the review line the finding was parsed from.
+	raw string
+}
+
+// parseFindings extracts the findings listed in a review, mapping the
+// severity labels back to canonical severities.
+func parseFindings(review string, labels severityLabels) []Finding {
+	var findings []Finding
+	lines := strings.Split(review, "\n")
+	for i, line := range lines {
+		m := findingLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		severity, ok := labels.canonical(m[1])
+		if !ok {
+			continue
+		}
+
+		f := Finding{Severity: severity, Message: strings.TrimSpace(m[2]), raw: line}
+		if loc := findingLocation.FindStringSubmatch(f.Message); loc != nil {
+			f.File = loc[1]
+			f.Line, _ = strconv.Atoi(loc[2])
+			f.EndLine, _ = strconv.Atoi(loc[3])
+			f.Message = strings.TrimSpace(loc[4])
+		}
+		if c := findingConfidence.FindStringSubmatchIndex(f.Message); c != nil {
+			if confidence, err := strconv.ParseFloat(f.Message[c[2]:c[3]], 64); err == nil && confidence <= 1 {
+				f.Confidence = &confidence
+				f.Message = f.Message[:c[0]]
+			}
+		}
+		f.CWE = cweID.FindString(f.Message)
+		if suggestion, n := suggestionBlock(lines[i+1:]); n > 0 {
+			f.Suggestion = stripLineNumbers(suggestion)
+		}
+		findings = append(findings, f)
+	}
+	return findings
+}
+
+// suggestionBlock reads a ```suggestion block at the start of lines,
+// possibly after a blank line, returning its content and how many lines it
+// spans, or 0 when there is none.
+func suggestionBlock(lines []string) (string, int) {
+	start := 0
+	if start < len(lines) && strings.TrimSpace(lines[start]) == "" {
+		start++
+	}
+	if start >= len(lines) || !strings.HasPrefix(strings.TrimSpace(lines[start]), "```suggestion") {
+		return "", 0
+	}
+	indent := lines[start][:len(lines[start])-len(strings.TrimLeft(lines[start], " \t"))]
+
+	var content []string
+	for i := start + 1; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "```" {
+			return strings.Join(content, "\n"), i + 1
+		}
+		content = append(content, strings.TrimPrefix(lines[i], indent))
+	}
+	return "", 0
+}
+
+// SuppressRule drops findings the team has accepted. A rule matches when
+// every pattern it sets matches: Message is a regular expression, Path a
+// glob against the finding's file.
+type SuppressRule struct {
+	Message string `toml:"message"`
+	Path    string `toml:"path"`
+}
+
+type suppressor struct {
+	rules    []SuppressRule
+	messages []*regexp.Regexp
+}
+
+func newSuppressor(rules []SuppressRule) (*suppressor, error) {
+	s := &suppressor{rules: rules, messages: make([]*regexp.Regexp, len(rules))}
+	for i, rule := range rules {
+		if rule.Message == "" && rule.Path == "" {
+			return nil, fmt.Errorf("suppress rule %d sets neither message nor path", i+1)
+		}
+		if rule.Message != "" {
+			re, err := regexp.Compile(rule.Message)
+			if err != nil {
+				return nil, fmt.Errorf("invalid message pattern in suppress rule %d: %v", i+1, err)
+			}
+			s.messages[i] = re
+		}
+	}
+	return s, nil
+}
+
+func (s *suppressor) matches(f Finding) bool {
+	for i, rule := range s.rules {
+		if s.messages[i] != nil && !s.messages[i].MatchString(f.Message) {
+			continue
+		}
+		if rule.Path != "" && !globMatch(rule.Path, f.File) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// apply removes suppressed findings from the review and notes how many
+// were dropped. It returns the remaining findings.
+func (s *suppressor) apply(review string, findings []Finding) (string, []Finding) {
+	var kept, dropped []Finding
+	for _, f := range findings {
+		if s.matches(f) {
+			dropped = append(dropped, f)
+			continue
+		}
+		kept = append(kept, f)
+	}
+
+	if len(dropped) == 0 {
+		return review, findings
+	}
+
+	note := fmt.Sprintf("_%d finding(s) were suppressed by [suppress] rules._", len(dropped))
+	return removeFindings(review, dropped) + "\n\n" + note, kept
+}
+
+// dropUnconfident removes the findings the model is less confident about
+// than minConfidence from the review, noting how many were dropped. Findings
+// without a confidence are kept. It returns the remaining findings.
+func dropUnconfident(review string, findings []Finding, minConfidence float64) (string, []Finding) {
+	var kept, dropped []Finding
+	for _, f := range findings {
+		if f.Confidence != nil && *f.Confidence < minConfidence {
+			dropped = append(dropped, f)
+			continue
+		}
+		kept = append(kept, f)
+	}
+
+	if len(dropped) == 0 {
+		return review, findings
+	}
+
+	note := fmt.Sprintf("_%d finding(s) with a confidence below %g were left out._", len(dropped), minConfidence)
+	return removeFindings(review, dropped) + "\n\n" + note, kept
+}
+
+// removeFindings deletes the lines the given findings were parsed from,
+// with their suggestion blocks.
+func removeFindings(review string, findings []Finding) string {
+	drop := map[string]bool{}
+	for _, f := range findings {
+		drop[f.raw] = true
+	}
+
+	var kept []string
+	lines := strings.Split(review, "\n")
+	for i := 0; i < len(lines); i++ {
+		if !drop[lines[i]] {
+			kept = append(kept, lines[i])
+			continue
+		}
+		_, n := suggestionBlock(lines[i+1:])
+		i += n
+	}
+	return strings.Join(kept, "\n")
+}
+
+// grepFindings keeps only the findings whose text matches re in the
+// rendered review, noting how many of them are shown.
+func grepFindings(review string, findings []Finding, re *regexp.Regexp) string {
+	var dropped []Finding
+	for _, f := range findings {
+		if !re.MatchString(f.raw) {
+			dropped = append(dropped, f)
+		}
+	}
+
+	note := fmt.Sprintf("_Showing %d of %d finding(s) matching /%s/._", len(findings)-len(dropped), len(findings), re)
+	return removeFindings(review, dropped) + "\n\n" + note
+}
+
+func countSeverity(findings []Finding, severity string) int {
+	n := 0
+	for _, f := range findings {
+		if f.Severity == severity {
+			n++
+		}
+	}
+	return n
+}
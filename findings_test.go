@@ -0,0 +1,90 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSuppressRules(t *testing.T) {
+	const review = "## Final consideration\n- [blocker] db/query.go:12 - SQL injection through the name parameter\n- [minor] gen/api.go:3 - exported function without a comment"
+	tests := []struct {
+		name  string
+		rules string
+		gone  []string
+		note  string
+		code  int
+	}{
+		{"no rules", "", nil, "", exitRejected},
+		{"by path", `[[suppress.rules]]` + "\npath = \"gen/**\"\n", []string{"exported function"}, "_1 finding(s) were suppressed by [suppress] rules._", exitRejected},
+		{"by message", `[[suppress.rules]]` + "\nmessage = \"(?i)sql injection\"\n", []string{"SQL injection"}, "_1 finding(s) were suppressed by [suppress] rules._", exitApproved},
+		{"message and path must both match", `[[suppress.rules]]` + "\nmessage = \"SQL\"\npath = \"gen/**\"\n", nil, "", exitRejected},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useConfig(t, "provider = \"mock\"\n"+tt.rules)
+			t.Setenv("PRGPT_MOCK_TEXT", review)
+			output, code := runPrgpt(t, "-diff", "testdata/replay/change.patch")
+			if code != tt.code {
+				t.Errorf("exit code = %d, want %d; output:\n%s", code, tt.code, output)
+			}
+			for _, s := range tt.gone {
+				if strings.Contains(output, s) {
+					t.Errorf("suppressed finding %q is still in the output:\n%s", s, output)
+				}
+			}
+			if tt.note != "" && !strings.Contains(output, tt.note) {
+				t.Errorf("output lacks the note %q:\n%s", tt.note, output)
+			}
+			if tt.note == "" && strings.Contains(output, "suppressed") {
+				t.Errorf("output notes suppressed findings:\n%s", output)
+			}
+		})
+	}
+}
+
+func TestGrepFindings(t *testing.T) {
+	const review = "## Final consideration\n- [blocker] db/query.go:12 - SQL injection through the name parameter\n- [minor] gen/api.go:3 - exported function without a comment"
+	tests := []struct {
+		name    string
+		pattern string
+		shown   []string
+		hidden  []string
+		note    string
+	}{
+		{"matching the minor finding", "exported", []string{"exported function"}, []string{"SQL injection"}, "_Showing 1 of 2 finding(s) matching /exported/._"},
+		{"matching by path", `db/query\.go`, []string{"SQL injection"}, []string{"exported function"}, "_Showing 1 of 2 finding(s) matching /db/query\\.go/._"},
+		{"matching nothing", "race", nil, []string{"SQL injection", "exported function"}, "_Showing 0 of 2 finding(s) matching /race/._"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useConfig(t, "provider = \"mock\"\n")
+			t.Setenv("PRGPT_MOCK_TEXT", review)
+			output, code := runPrgpt(t, "-diff", "testdata/replay/change.patch", "-grep-findings", tt.pattern)
+			if code != exitRejected {
+				t.Errorf("exit code = %d, want %d: filtering must not change the verdict", code, exitRejected)
+			}
+			for _, s := range tt.shown {
+				if !strings.Contains(output, s) {
+					t.Errorf("matching finding %q is missing:\n%s", s, output)
+				}
+			}
+			for _, s := range tt.hidden {
+				if strings.Contains(output, s) {
+					t.Errorf("finding %q does not match but is shown:\n%s", s, output)
+				}
+			}
+			if !strings.Contains(output, tt.note) {
+				t.Errorf("output lacks the note %q:\n%s", tt.note, output)
+			}
+		})
+	}
+
+	t.Run("invalid pattern", func(t *testing.T) {
+		useConfig(t, "provider = \"mock\"\n")
+		t.Setenv("PRGPT_MOCK_TEXT", review)
+		output, code := runPrgpt(t, "-diff", "testdata/replay/change.patch", "-grep-findings", "(")
+		if code != exitError || !strings.Contains(output, "Invalid -grep-findings pattern") {
+			t.Errorf("exit code = %d, output:\n%s", code, output)
+		}
+	})
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/loadfms/prgpt/pkg/prgpt"
+)
+
+// fixtureRequest is what identifies a recorded call: everything sent to the
+// provider.
+type fixtureRequest struct {
+	Model          string                `json:"model"`
+	System         string                `json:"system,omitempty"`
+	History        []prgpt.Message       `json:"history,omitempty"`
+	Prompt         string                `json:"prompt"`
+	Temperature    float64               `json:"temperature"`
+	MaxTokens      int                   `json:"max_tokens,omitempty"`
+	TopP           float64               `json:"top_p,omitempty"`
+	ResponseFormat *prgpt.ResponseFormat `json:"response_format,omitempty"`
+}
+
+type fixtureResponse struct {
+	Content      string `json:"content"`
+	InputTokens  int    `json:"input_tokens"`
+	OutputTokens int    `json:"output_tokens"`
+}
+
+// fixture is a recorded call, saved as <dir>/<key>.json.
+type fixture struct {
+	Request  fixtureRequest  `json:"request"`
+	Response fixtureResponse `json:"response"`
+}
+
+// fixtureProvider records the calls made through provider to dir with
+// -record, or answers them from the recordings in dir with -replay, so a
+// review can run again without calling the provider or needing its key.
+type fixtureProvider struct {
+	provider prgpt.Provider
+	dir      string
+	replay   bool
+}
+
+func (p fixtureProvider) path(req prgpt.CompletionRequest) (fixtureRequest, string, error) {
+	fr := fixtureRequest{Model: req.Model, System: req.System, History: req.History, Prompt: req.Prompt, Temperature: req.Temperature, MaxTokens: req.MaxTokens, TopP: req.TopP, ResponseFormat: req.ResponseFormat}
+	data, err := json.Marshal(fr)
+	if err != nil {
+		return fr, "", fmt.Errorf("error marshaling request: %v", err)
+	}
+	return fr, filepath.Join(p.dir, cacheKey(string(data))[:16]+".json"), nil
+}
+
+func (p fixtureProvider) Complete(ctx context.Context, req prgpt.CompletionRequest) (prgpt.Completion, error) {
+	fr, path, err := p.path(req)
+	if err != nil {
+		return prgpt.Completion{}, err
+	}
+	if p.replay {
+		return replayFixture(path, req)
+	}
+
+	resp, err := p.provider.Complete(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+	f := fixture{Request: fr, Response: fixtureResponse{Content: resp.Content, InputTokens: resp.InputTokens, OutputTokens: resp.OutputTokens}}
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return resp, fmt.Errorf("error marshaling fixture: %v", err)
+	}
+	if err := os.MkdirAll(p.dir, 0o755); err != nil {
+		return resp, fmt.Errorf("error creating fixture directory: %v", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return resp, fmt.Errorf("error writing fixture: %v", err)
+	}
+	return resp, nil
+}
+
+func replayFixture(path string, req prgpt.CompletionRequest) (prgpt.Completion, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return prgpt.Completion{}, fmt.Errorf("no recorded response to this request in %s (%s); record it with -record", filepath.Dir(path), filepath.Base(path))
+	}
+	if err != nil {
+		return prgpt.Completion{}, fmt.Errorf("error reading fixture: %v", err)
+	}
+	var f fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		return prgpt.Completion{}, fmt.Errorf("error parsing fixture %s: %v", path, err)
+	}
+	if req.OnDelta != nil {
+		req.OnDelta(f.Response.Content)
+	}
+	r := f.Response
+	return prgpt.Completion{Content: r.Content, Tokens: r.InputTokens + r.OutputTokens, InputTokens: r.InputTokens, OutputTokens: r.OutputTokens}, nil
+}
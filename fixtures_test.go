@@ -0,0 +1,32 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReplayFixture(t *testing.T) {
+	// No API key: a replayed review never calls the provider.
+	useConfig(t, "[model]\nname = \"gpt-4o\"\n")
+
+	tests := []struct {
+		name   string
+		replay string
+		want   string
+		code   int
+	}{
+		{"recorded", "testdata/replay", "Get returns a nil item and no error for an unknown id", exitRejected},
+		{"not recorded", t.TempDir(), "no recorded response to this request", exitError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, code := runPrgpt(t, "-diff", "testdata/replay/change.patch", "-replay", tt.replay, "-plain")
+			if code != tt.code {
+				t.Errorf("exit code = %d, want %d; output:\n%s", code, tt.code, output)
+			}
+			if !strings.Contains(output, tt.want) {
+				t.Errorf("output does not contain %q:\n%s", tt.want, output)
+			}
+		})
+	}
+}
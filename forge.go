@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/loadfms/prgpt/pkg/prgpt"
+)
+
+// forge is the code host a PR lives on.
+type forge interface {
+	Diff(ctx context.Context, prURL string) (string, error)
+	// DiffSince is the diff of the commits between two heads of the PR.
+	DiffSince(ctx context.Context, prURL, oldSHA, newSHA string) (string, error)
+	Info(ctx context.Context, prURL string) (prInfo, error)
+	// Issue describes an issue of the PR's repository.
+	Issue(ctx context.Context, prURL, number string) (prInfo, error)
+	HeadSHA(ctx context.Context, prURL string) (string, error)
+	// IsFastForward reports whether newSHA only adds commits on top of oldSHA.
+	IsFastForward(ctx context.Context, prURL, oldSHA, newSHA string) bool
+	PostComment(ctx context.Context, prURL, body string) error
+	// RepoFile reads a file of the PR's repository from its default or
+	// target branch rather than from the PR, so a PR cannot change it; a
+	// missing file yields nil.
+	RepoFile(ctx context.Context, prURL, path string) ([]byte, error)
+}
+
+// verdictPoster is a forge that records the verdict along with the review,
+// such as Gerrit with its Code-Review votes.
+type verdictPoster interface {
+	PostReview(ctx context.Context, prURL, body string, approved bool) error
+}
+
+// forgeFor picks the forge from the PR URL: GitLab for merge request URLs
+// or hosts named gitlab, Bitbucket for bitbucket.org, Gerrit for change URLs
+// or hosts named gerrit, GitHub otherwise.
+// GitHub is called through its API when a token is available, through gh
+// otherwise.
+func forgeFor(prURL string, cfg FileConfig, client *http.Client) forge {
+	if isGitLabURL(prURL) {
+		return gitlabForge{}
+	}
+	if isBitbucketURL(prURL) {
+		return bitbucketForge{client: client, username: cfg.Bitbucket.Username, appPassword: cfg.Bitbucket.AppPassword}
+	}
+	if isGerritURL(prURL) {
+		return gerritForge{client: client, username: cfg.Gerrit.Username, password: cfg.Gerrit.HTTPPassword, noVote: cfg.Gerrit.NoVote}
+	}
+	return newGitHubForge(cfg, client)
+}
+
+const (
+	defaultDiffTimeout  = 2 * time.Minute
+	defaultMaxDiffBytes = 20 << 20
+)
+
+func newGitHubForge(cfg FileConfig, client *http.Client) githubForge {
+	f := githubForge{api: newGitHubAPI(cfg, client), diffTimeout: defaultDiffTimeout, maxDiffBytes: defaultMaxDiffBytes}
+	if cfg.GitHub.DiffTimeout > 0 {
+		f.diffTimeout = time.Duration(cfg.GitHub.DiffTimeout) * time.Second
+	}
+	if cfg.GitHub.MaxDiffBytes > 0 {
+		f.maxDiffBytes = int64(cfg.GitHub.MaxDiffBytes)
+	}
+	return f
+}
+
+func isGitHubURL(prURL string) bool {
+	return !isGitLabURL(prURL) && !isBitbucketURL(prURL) && !isGerritURL(prURL)
+}
+
+func isGitLabURL(prURL string) bool {
+	if strings.Contains(prURL, gitlabMRMarker) {
+		return true
+	}
+	u, err := url.Parse(prURL)
+	return err == nil && strings.Contains(u.Hostname(), "gitlab")
+}
+
+type githubForge struct {
+	api          *githubAPI
+	diffTimeout  time.Duration
+	maxDiffBytes int64
+}
+
+func (f githubForge) Diff(ctx context.Context, prURL string) (string, error) {
+	if f.api != nil {
+		diff, err := f.api.diff(ctx, prURL)
+		if err == nil && f.maxDiffBytes > 0 && int64(len(diff)) > f.maxDiffBytes {
+			return "", fmt.Errorf("the diff of %s is larger than %d bytes: %w", prURL, f.maxDiffBytes, prgpt.ErrDiffTooLarge)
+		}
+		return diff, err
+	}
+	return prgpt.GitHubPR{URL: prURL, Timeout: f.diffTimeout, MaxBytes: f.maxDiffBytes}.Diff(ctx)
+}
+
+func (f githubForge) DiffSince(ctx context.Context, prURL, oldSHA, newSHA string) (string, error) {
+	if f.api != nil {
+		return f.api.compareDiff(ctx, prURL, oldSHA, newSHA)
+	}
+	return getCompareDiff(ctx, prURL, oldSHA, newSHA)
+}
+
+func (f githubForge) Info(ctx context.Context, prURL string) (prInfo, error) {
+	if f.api != nil {
+		pull, err := f.api.pull(ctx, prURL)
+		return pull.info(), err
+	}
+	return getPRInfo(ctx, prURL)
+}
+
+func (f githubForge) Issue(ctx context.Context, prURL, number string) (prInfo, error) {
+	if f.api != nil {
+		return f.api.issue(ctx, prURL, number)
+	}
+	return ghView(ctx, "issue", prURL, number)
+}
+
+func (f githubForge) HeadSHA(ctx context.Context, prURL string) (string, error) {
+	if f.api != nil {
+		pull, err := f.api.pull(ctx, prURL)
+		return pull.Head.SHA, err
+	}
+	return getPRHeadSHA(ctx, prURL)
+}
+
+func (f githubForge) PostComment(ctx context.Context, prURL, body string) error {
+	if f.api != nil {
+		return f.api.postComment(ctx, prURL, body)
+	}
+	return postPRComment(ctx, prURL, body)
+}
+
+func (f githubForge) IsFastForward(ctx context.Context, prURL, oldSHA, newSHA string) bool {
+	if f.api != nil {
+		return f.api.isFastForward(ctx, prURL, oldSHA, newSHA)
+	}
+	return isFastForward(ctx, prURL, oldSHA, newSHA)
+}
+
+func (f githubForge) RepoFile(ctx context.Context, prURL, path string) ([]byte, error) {
+	if f.api != nil {
+		return f.api.contents(ctx, prURL, path)
+	}
+	return ghContents(ctx, prURL, path)
+}
+
+// The methods below are GitHub-only.
+
+func (f githubForge) SetCommitStatus(ctx context.Context, prURL, sha string, status commitStatus) error {
+	if f.api != nil {
+		return f.api.setStatus(ctx, prURL, sha, status)
+	}
+	return setCommitStatus(ctx, prURL, sha, status)
+}
+
+// SubmitReview submits a pull request review commenting on the given
+// commit, with the body as its summary.
+func (f githubForge) SubmitReview(ctx context.Context, prURL, commitSHA, body string, comments []reviewComment) error {
+	if comments == nil {
+		comments = []reviewComment{}
+	}
+	review := map[string]any{
+		"commit_id": commitSHA,
+		"body":      body,
+		"event":     "COMMENT",
+		"comments":  comments,
+	}
+	if f.api != nil {
+		return f.api.submitReview(ctx, prURL, review)
+	}
+	return submitPRReview(ctx, prURL, review)
+}
+
+// RecentMergedPRs lists the most recently merged PRs of the repository.
+func (f githubForge) RecentMergedPRs(ctx context.Context, prURL string) ([]relatedPR, error) {
+	if f.api != nil {
+		return f.api.mergedPRs(ctx, prURL, relatedPRCandidates)
+	}
+	return fetchRecentMergedPRs(ctx, prURL)
+}
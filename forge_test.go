@@ -0,0 +1,19 @@
+package main
+
+import "testing"
+
+func TestIsBitbucketURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://bitbucket.org/workspace/repo/pull-requests/12", true},
+		{"https://git.example.com/projects/P/repos/r/pull-requests/3", false},
+		{"https://github.com/o/r/pull/1", false},
+	}
+	for _, tt := range tests {
+		if got := isBitbucketURL(tt.url); got != tt.want {
+			t.Errorf("isBitbucketURL(%q) = %t, want %t", tt.url, got, tt.want)
+		}
+	}
+}
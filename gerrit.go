@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	gerritChangeMarker = "/+/"
+	// gerritXSSIPrefix starts every JSON response of Gerrit.
+	gerritXSSIPrefix = ")]}'"
+)
+
+// isGerritURL recognizes the change URLs of Gerrit, such as
+// https://review.example.com/c/project/+/12345, and anything on a host
+// named gerrit.
+func isGerritURL(prURL string) bool {
+	u, err := url.Parse(prURL)
+	if err != nil {
+		return false
+	}
+	return strings.Contains(u.Hostname(), "gerrit") || strings.Contains(u.Path, "/c/") && strings.Contains(u.Path, gerritChangeMarker)
+}
+
+// parseGerritURL splits a change URL into the URL of the Gerrit server,
+// which may be served under a path, the project and the change number.
+func parseGerritURL(prURL string) (base, project, change string, err error) {
+	u, err := url.Parse(prURL)
+	if err != nil {
+		return "", "", "", fmt.Errorf("invalid Gerrit change URL: %v", err)
+	}
+	prefix, rest, ok := strings.Cut(u.Path, "/c/")
+	if ok {
+		project, rest, ok = strings.Cut(rest, gerritChangeMarker)
+	}
+	if !ok || project == "" {
+		return "", "", "", fmt.Errorf("invalid Gerrit change URL %q, expected https://HOST/c/PROJECT/+/NUMBER", prURL)
+	}
+	change, _, _ = strings.Cut(rest, "/")
+	if change == "" {
+		return "", "", "", fmt.Errorf("invalid Gerrit change URL %q, expected https://HOST/c/PROJECT/+/NUMBER", prURL)
+	}
+	return u.Scheme + "://" + u.Host + prefix, project, change, nil
+}
+
+// errGerritNotFound is returned for a 404, such as for a file the branch
+// does not have.
+var errGerritNotFound = errors.New("Gerrit API returned 404 Not Found")
+
+// gerritForge calls the Gerrit REST API, authenticating with a username and
+// an HTTP password when they are configured.
+type gerritForge struct {
+	client   *http.Client
+	username string
+	password string
+	noVote   bool
+}
+
+// do calls the endpoint of the change at path, e.g. "revisions/current/patch",
+// or the change itself for a path of only a query.
+func (f gerritForge) do(ctx context.Context, method, prURL, path string, payload any) ([]byte, error) {
+	_, project, change, err := parseGerritURL(prURL)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := "/changes/" + url.PathEscape(project+"~"+change)
+	if path != "" && !strings.HasPrefix(path, "?") {
+		endpoint += "/"
+	}
+	return f.call(ctx, method, prURL, endpoint+path, payload)
+}
+
+// call calls the endpoint of the Gerrit server of the change, e.g.
+// "/projects/NAME".
+func (f gerritForge) call(ctx context.Context, method, prURL, endpoint string, payload any) ([]byte, error) {
+	base, _, _, err := parseGerritURL(prURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("error marshaling Gerrit request: %v", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	// Authenticated calls go to the /a/ prefix.
+	if f.username != "" {
+		base += "/a"
+	}
+	req, err := http.NewRequestWithContext(ctx, method, base+endpoint, body)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request to Gerrit API: %v", err)
+	}
+	if f.username != "" {
+		req.SetBasicAuth(f.username, f.password)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making request to Gerrit API: %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response from Gerrit API: %v", err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errGerritNotFound
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("Gerrit API returned %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	return bytes.TrimPrefix(data, []byte(gerritXSSIPrefix)), nil
+}
+
+type gerritChange struct {
+	Project         string   `json:"project"`
+	Branch          string   `json:"branch"`
+	Subject         string   `json:"subject"`
+	Hashtags        []string `json:"hashtags"`
+	CurrentRevision string   `json:"current_revision"`
+	Revisions       map[string]struct {
+		Commit struct {
+			Message string `json:"message"`
+		} `json:"commit"`
+	} `json:"revisions"`
+}
+
+func (f gerritForge) change(ctx context.Context, prURL string) (gerritChange, error) {
+	var change gerritChange
+	data, err := f.do(ctx, "GET", prURL, "?o=CURRENT_REVISION&o=CURRENT_COMMIT", nil)
+	if err != nil {
+		return change, err
+	}
+	if err := json.Unmarshal(data, &change); err != nil {
+		return change, fmt.Errorf("error parsing Gerrit change: %v", err)
+	}
+	return change, nil
+}
+
+// Diff is the patch of the change's current patch set, which Gerrit sends
+// base64-encoded.
+func (f gerritForge) Diff(ctx context.Context, prURL string) (string, error) {
+	data, err := f.do(ctx, "GET", prURL, "revisions/current/patch", nil)
+	if err != nil {
+		return "", err
+	}
+	patch, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return "", fmt.Errorf("error decoding Gerrit patch: %v", err)
+	}
+	return string(patch), nil
+}
+
+// DiffSince is unsupported: patch sets amend the change rather than adding
+// commits to it, which IsFastForward reports.
+func (f gerritForge) DiffSince(ctx context.Context, prURL, oldSHA, newSHA string) (string, error) {
+	return "", fmt.Errorf("diffs between patch sets are not supported for Gerrit changes")
+}
+
+// Info describes the change by its commit message, with its hashtags as
+// the labels.
+func (f gerritForge) Info(ctx context.Context, prURL string) (prInfo, error) {
+	change, err := f.change(ctx, prURL)
+	if err != nil {
+		return prInfo{}, err
+	}
+	message := change.Revisions[change.CurrentRevision].Commit.Message
+	_, body, _ := strings.Cut(message, "\n")
+	return prInfo{Title: change.Subject, Body: strings.TrimSpace(body), Labels: change.Hashtags}, nil
+}
+
+func (f gerritForge) Issue(ctx context.Context, prURL, number string) (prInfo, error) {
+	return prInfo{}, fmt.Errorf("Gerrit has no issues")
+}
+
+func (f gerritForge) HeadSHA(ctx context.Context, prURL string) (string, error) {
+	change, err := f.change(ctx, prURL)
+	return change.CurrentRevision, err
+}
+
+// IsFastForward is always false, as every patch set replaces the previous
+// one.
+func (f gerritForge) IsFastForward(ctx context.Context, prURL, oldSHA, newSHA string) bool {
+	return false
+}
+
+func (f gerritForge) PostComment(ctx context.Context, prURL, body string) error {
+	if _, err := f.do(ctx, "POST", prURL, "revisions/current/review", map[string]any{"message": body}); err != nil {
+		return fmt.Errorf("error posting Gerrit comment: %v", err)
+	}
+	return nil
+}
+
+// PostReview posts the review with a Code-Review vote of +1 or -1 for the
+// verdict, unless [gerrit] no_vote is set.
+func (f gerritForge) PostReview(ctx context.Context, prURL, body string, approved bool) error {
+	if f.noVote {
+		return f.PostComment(ctx, prURL, body)
+	}
+	vote := -1
+	if approved {
+		vote = 1
+	}
+	payload := map[string]any{"message": body, "labels": map[string]int{"Code-Review": vote}}
+	if _, err := f.do(ctx, "POST", prURL, "revisions/current/review", payload); err != nil {
+		return fmt.Errorf("error posting Gerrit review: %v", err)
+	}
+	return nil
+}
+
+// RepoFile reads the file from the branch the change targets, as Gerrit
+// sends it base64-encoded.
+func (f gerritForge) RepoFile(ctx context.Context, prURL, path string) ([]byte, error) {
+	change, err := f.change(ctx, prURL)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := "/projects/" + url.PathEscape(change.Project) + "/branches/" + url.PathEscape(change.Branch) + "/files/" + url.PathEscape(path) + "/content"
+	data, err := f.call(ctx, "GET", prURL, endpoint, nil)
+	if errors.Is(err, errGerritNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	content, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding Gerrit file: %v", err)
+	}
+	return content, nil
+}
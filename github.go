@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/loadfms/prgpt/pkg/prgpt"
+)
+
+// parsePRURL splits a PR URL into its owner, repository and number. For a
+// GitLab merge request the owner is the project's namespace, for a
+// Bitbucket pull request the workspace, for a Gerrit change the parent path
+// of the project.
+func parsePRURL(prURL string) (org, repo, prNumber string, err error) {
+	if isBitbucketURL(prURL) {
+		return parseBitbucketURL(prURL)
+	}
+	if isGerritURL(prURL) {
+		_, project, change, err := parseGerritURL(prURL)
+		if err != nil {
+			return "", "", "", err
+		}
+		i := strings.LastIndex(project, "/")
+		return project[:max(i, 0)], project[i+1:], change, nil
+	}
+	if strings.Contains(prURL, gitlabMRMarker) {
+		_, project, mrNumber, err := parseMRURL(prURL)
+		if err != nil {
+			return "", "", "", err
+		}
+		i := strings.LastIndex(project, "/")
+		if i < 0 {
+			return "", "", "", fmt.Errorf("invalid MR URL")
+		}
+		return project[:i], project[i+1:], mrNumber, nil
+	}
+
+	u, err := url.Parse(prURL)
+	if err != nil {
+		return "", "", "", fmt.Errorf("invalid PR URL: %v", err)
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if u.Host == "" || len(parts) < 4 || parts[2] != "pull" {
+		return "", "", "", fmt.Errorf("invalid PR URL %q, expected https://HOST/OWNER/REPO/pull/NUMBER", prURL)
+	}
+
+	return parts[0], parts[1], parts[3], nil
+}
+
+var shortPRRef = regexp.MustCompile(`^([\w.-]+)/([\w.-]+)#(\d+)$`)
+
+// normalizePRURL completes a PR reference given without a scheme, such as
+// github.mycorp.com/org/repo/pull/1, or without a host, such as
+// org/repo/pull/1 or org/repo#1, which refer to a PR on host.
+func normalizePRURL(ref, host string) string {
+	if strings.Contains(ref, "://") {
+		return ref
+	}
+	if host == "" {
+		host = "github.com"
+	}
+	if m := shortPRRef.FindStringSubmatch(ref); m != nil {
+		return "https://" + host + "/" + m[1] + "/" + m[2] + "/pull/" + m[3]
+	}
+	if first, _, _ := strings.Cut(ref, "/"); strings.Contains(first, ".") {
+		return "https://" + ref
+	}
+	return "https://" + host + "/" + ref
+}
+
+// ghRepo is the -R argument pointing gh at the PR's repository, with the
+// host so that PRs on a GitHub Enterprise Server work too.
+func ghRepo(prURL string) (string, error) {
+	org, repo, _, err := parsePRURL(prURL)
+	if err != nil {
+		return "", err
+	}
+	u, _ := url.Parse(prURL)
+	return u.Host + "/" + org + "/" + repo, nil
+}
+
+// ghContents fetches a file from the default branch of the PR's repository
+// with gh. A missing file yields nil.
+func ghContents(ctx context.Context, prURL, path string) ([]byte, error) {
+	org, repo, _, err := parsePRURL(prURL)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := ghAPI(ctx, prURL, "-H", "Accept: application/vnd.github.raw",
+		"repos/"+org+"/"+repo+"/contents/"+path).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && strings.Contains(string(exitErr.Stderr), "404") {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return output, nil
+}
+
+// ghAPI runs gh api against the host of the PR.
+func ghAPI(ctx context.Context, prURL string, args ...string) *exec.Cmd {
+	host := "github.com"
+	if u, err := url.Parse(prURL); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	return prgpt.Command(ctx, "gh", append([]string{"api", "--hostname", host}, args...)...)
+}
+
+func getCompareDiff(ctx context.Context, prURL, oldSHA, newSHA string) (string, error) {
+	org, repo, _, err := parsePRURL(prURL)
+	if err != nil {
+		return "", err
+	}
+
+	cmd := ghAPI(ctx, prURL, "-H", "Accept: application/vnd.github.diff", "repos/"+org+"/"+repo+"/compare/"+oldSHA+"..."+newSHA)
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("error running gh api compare: %v", err)
+	}
+
+	return string(output), nil
+}
+
+// prInfo is the description of a PR, or of an issue.
+type prInfo struct {
+	Title  string
+	Body   string
+	Labels []string
+}
+
+// githubIssue is a PR or an issue as gh and the REST API return them.
+type githubIssue struct {
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+	Labels []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+}
+
+func (i githubIssue) info() prInfo {
+	info := prInfo{Title: i.Title, Body: i.Body}
+	for _, l := range i.Labels {
+		info.Labels = append(info.Labels, l.Name)
+	}
+	return info
+}
+
+// ghView runs gh pr view or gh issue view for the given number of the PR's
+// repository.
+func ghView(ctx context.Context, kind, prURL, number string) (prInfo, error) {
+	repo, err := ghRepo(prURL)
+	if err != nil {
+		return prInfo{}, err
+	}
+
+	cmd := prgpt.Command(ctx, "gh", kind, "view", "-R", repo, number, "--json", "title,body,labels")
+	output, err := cmd.Output()
+	if err != nil {
+		return prInfo{}, fmt.Errorf("error running gh %s view: %v", kind, err)
+	}
+
+	var issue githubIssue
+	if err := json.Unmarshal(output, &issue); err != nil {
+		return prInfo{}, fmt.Errorf("error parsing gh %s view output: %v", kind, err)
+	}
+	return issue.info(), nil
+}
+
+func getPRInfo(ctx context.Context, prURL string) (prInfo, error) {
+	_, _, prNumber, err := parsePRURL(prURL)
+	if err != nil {
+		return prInfo{}, err
+	}
+	return ghView(ctx, "pr", prURL, prNumber)
+}
+
+func getPRHeadSHA(ctx context.Context, prURL string) (string, error) {
+	_, _, prNumber, err := parsePRURL(prURL)
+	if err != nil {
+		return "", err
+	}
+	repo, err := ghRepo(prURL)
+	if err != nil {
+		return "", err
+	}
+
+	cmd := prgpt.Command(ctx, "gh", "pr", "view", "-R", repo, prNumber, "--json", "headRefOid", "-q", ".headRefOid")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("error running gh pr view: %v", err)
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+// isFastForward reports whether newSHA only adds commits on top of oldSHA.
+// A force-push rewrites history, so the comparison is "diverged" or
+// "behind", or fails outright once the old commit is gone.
+func isFastForward(ctx context.Context, prURL, oldSHA, newSHA string) bool {
+	org, repo, _, err := parsePRURL(prURL)
+	if err != nil {
+		return false
+	}
+
+	cmd := ghAPI(ctx, prURL, "repos/"+org+"/"+repo+"/compare/"+oldSHA+"..."+newSHA, "-q", ".status")
+	output, err := cmd.Output()
+	if err != nil {
+		return false
+	}
+
+	status := strings.TrimSpace(string(output))
+	return status == "ahead" || status == "identical"
+}
+
+// commitStatus is the payload of the commit statuses API.
+type commitStatus struct {
+	State       string
+	Context     string
+	Description string
+}
+
+func commitStatusFor(approved bool, findings []Finding, labels severityLabels) commitStatus {
+	status := commitStatus{State: "success", Context: "prgpt", Description: "Approved by prgpt"}
+	if !approved {
+		status.State = "failure"
+		status.Description = fmt.Sprintf("Not approved: %d %s, %d finding(s)", countSeverity(findings, "blocker"), labels.label("blocker"), len(findings))
+	}
+	return status
+}
+
+// setCommitStatus sets the commit status with gh.
+func setCommitStatus(ctx context.Context, prURL, sha string, status commitStatus) error {
+	org, repo, _, err := parsePRURL(prURL)
+	if err != nil {
+		return err
+	}
+
+	cmd := ghAPI(ctx, prURL, "-X", "POST", "repos/"+org+"/"+repo+"/statuses/"+sha,
+		"-f", "state="+status.State,
+		"-f", "context="+status.Context,
+		"-f", "description="+status.Description)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("error setting commit status: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+// postPRComment adds body as a comment on the PR's conversation.
+func postPRComment(ctx context.Context, prURL, body string) error {
+	org, repo, prNumber, err := parsePRURL(prURL)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(map[string]string{"body": body})
+	if err != nil {
+		return fmt.Errorf("error marshaling PR comment: %v", err)
+	}
+
+	cmd := ghAPI(ctx, prURL, "-X", "POST", "repos/"+org+"/"+repo+"/issues/"+prNumber+"/comments", "--input", "-")
+	cmd.Stdin = bytes.NewReader(payload)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("error posting PR comment: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
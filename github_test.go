@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSetCommitStatus(t *testing.T) {
+	findings := []Finding{{Severity: "blocker", Message: "data race"}, {Severity: "nit", Message: "typo"}}
+	tests := []struct {
+		name     string
+		approved bool
+		findings []Finding
+		labels   severityLabels
+		want     map[string]string
+	}{
+		{"approved", true, nil, nil, map[string]string{"state": "success", "context": "prgpt", "description": "Approved by prgpt"}},
+		{"rejected", false, findings, nil, map[string]string{"state": "failure", "context": "prgpt", "description": "Not approved: 1 blocker, 2 finding(s)"}},
+		{"team labels", false, findings, severityLabels{"blocker": "P0"}, map[string]string{"state": "failure", "context": "prgpt", "description": "Not approved: 1 P0, 2 finding(s)"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var path string
+			var got map[string]string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				path = r.Method + " " + r.URL.Path
+				if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+					t.Error(err)
+				}
+				w.WriteHeader(http.StatusCreated)
+			}))
+			defer server.Close()
+
+			github := githubForge{api: &githubAPI{client: server.Client(), token: "t"}}
+			status := commitStatusFor(tt.approved, tt.findings, tt.labels)
+			if err := github.SetCommitStatus(context.Background(), server.URL+"/o/r/pull/1", "abc123", status); err != nil {
+				t.Fatal(err)
+			}
+			if path != "POST /api/v3/repos/o/r/statuses/abc123" {
+				t.Errorf("called %s, want the statuses of the head", path)
+			}
+			if len(got) != len(tt.want) || got["state"] != tt.want["state"] || got["context"] != tt.want["context"] || got["description"] != tt.want["description"] {
+				t.Errorf("payload = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetCommitStatusError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message":"Resource not accessible by integration"}`, http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	github := githubForge{api: &githubAPI{client: server.Client(), token: "t"}}
+	err := github.SetCommitStatus(context.Background(), server.URL+"/o/r/pull/1", "abc123", commitStatusFor(true, nil, nil))
+	if err == nil || err.Error() != `error setting commit status: GitHub API returned 403 Forbidden: {"message":"Resource not accessible by integration"}` {
+		t.Errorf("err = %v, want the API's explanation", err)
+	}
+}
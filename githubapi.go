@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// errGitHubNotFound is returned for a 404, such as for a file the
+// repository does not have.
+var errGitHubNotFound = errors.New("GitHub API returned 404 Not Found")
+
+// githubAPI calls the GitHub REST API directly, for environments where gh
+// is not installed or authenticated.
+type githubAPI struct {
+	client *http.Client
+	token  string
+}
+
+// newGitHubAPI returns nil when no token is available, leaving GitHub
+// calls to gh.
+func newGitHubAPI(cfg FileConfig, client *http.Client) *githubAPI {
+	token := os.Getenv("GITHUB_TOKEN")
+	if token == "" {
+		token = cfg.GitHub.Token
+	}
+	if token == "" {
+		return nil
+	}
+	return &githubAPI{client: client, token: token}
+}
+
+// githubAPIBase is the REST endpoint of the PR's host: api.github.com, or
+// the /api/v3 path of a GitHub Enterprise Server.
+func githubAPIBase(prURL string) (string, error) {
+	u, err := url.Parse(prURL)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid PR URL")
+	}
+	if u.Host == "github.com" {
+		return "https://api.github.com", nil
+	}
+	return u.Scheme + "://" + u.Host + "/api/v3", nil
+}
+
+// do calls the endpoint of the PR's repository at path, e.g. "pulls/1".
+func (a *githubAPI) do(ctx context.Context, method, prURL, path, accept string, payload any) ([]byte, error) {
+	org, repo, _, err := parsePRURL(prURL)
+	if err != nil {
+		return nil, err
+	}
+	base, err := githubAPIBase(prURL)
+	if err != nil {
+		return nil, err
+	}
+	return a.send(ctx, method, base+"/repos/"+org+"/"+repo+"/"+path, accept, payload)
+}
+
+func (a *githubAPI) send(ctx context.Context, method, endpoint, accept string, payload any) ([]byte, error) {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("error marshaling GitHub request: %v", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request to GitHub API: %v", err)
+	}
+	req.Header.Set("Accept", accept)
+	req.Header.Set("Authorization", "Bearer "+a.token)
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making request to GitHub API: %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response from GitHub API: %v", err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errGitHubNotFound
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("GitHub API returned %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
+
+type githubPull struct {
+	githubIssue
+	Head struct {
+		SHA string `json:"sha"`
+	} `json:"head"`
+}
+
+func (a *githubAPI) pull(ctx context.Context, prURL string) (githubPull, error) {
+	var pull githubPull
+	_, _, prNumber, err := parsePRURL(prURL)
+	if err != nil {
+		return pull, err
+	}
+
+	data, err := a.do(ctx, "GET", prURL, "pulls/"+prNumber, "application/vnd.github+json", nil)
+	if err != nil {
+		return pull, err
+	}
+	if err := json.Unmarshal(data, &pull); err != nil {
+		return pull, fmt.Errorf("error parsing GitHub pull request: %v", err)
+	}
+	return pull, nil
+}
+
+func (a *githubAPI) issue(ctx context.Context, prURL, number string) (prInfo, error) {
+	data, err := a.do(ctx, "GET", prURL, "issues/"+number, "application/vnd.github+json", nil)
+	if err != nil {
+		return prInfo{}, err
+	}
+
+	var issue githubIssue
+	if err := json.Unmarshal(data, &issue); err != nil {
+		return prInfo{}, fmt.Errorf("error parsing GitHub issue: %v", err)
+	}
+	return issue.info(), nil
+}
+
+func (a *githubAPI) diff(ctx context.Context, prURL string) (string, error) {
+	_, _, prNumber, err := parsePRURL(prURL)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := a.do(ctx, "GET", prURL, "pulls/"+prNumber, "application/vnd.github.diff", nil)
+	return string(data), err
+}
+
+func (a *githubAPI) compareDiff(ctx context.Context, prURL, oldSHA, newSHA string) (string, error) {
+	data, err := a.do(ctx, "GET", prURL, "compare/"+oldSHA+"..."+newSHA, "application/vnd.github.diff", nil)
+	return string(data), err
+}
+
+func (a *githubAPI) isFastForward(ctx context.Context, prURL, oldSHA, newSHA string) bool {
+	data, err := a.do(ctx, "GET", prURL, "compare/"+oldSHA+"..."+newSHA, "application/vnd.github+json", nil)
+	if err != nil {
+		return false
+	}
+
+	var comparison struct {
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal(data, &comparison); err != nil {
+		return false
+	}
+	return comparison.Status == "ahead" || comparison.Status == "identical"
+}
+
+func (a *githubAPI) postComment(ctx context.Context, prURL, body string) error {
+	_, _, prNumber, err := parsePRURL(prURL)
+	if err != nil {
+		return err
+	}
+
+	_, err = a.do(ctx, "POST", prURL, "issues/"+prNumber+"/comments", "application/vnd.github+json", map[string]string{"body": body})
+	if err != nil {
+		return fmt.Errorf("error posting PR comment: %v", err)
+	}
+	return nil
+}
+
+// contents fetches a file from the default branch of the PR's repository.
+// A missing file yields nil.
+func (a *githubAPI) contents(ctx context.Context, prURL, path string) ([]byte, error) {
+	data, err := a.do(ctx, "GET", prURL, "contents/"+path, "application/vnd.github.raw", nil)
+	if errors.Is(err, errGitHubNotFound) {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (a *githubAPI) setStatus(ctx context.Context, prURL, sha string, status commitStatus) error {
+	_, err := a.do(ctx, "POST", prURL, "statuses/"+sha, "application/vnd.github+json", map[string]string{
+		"state":       status.State,
+		"context":     status.Context,
+		"description": status.Description,
+	})
+	if err != nil {
+		return fmt.Errorf("error setting commit status: %v", err)
+	}
+	return nil
+}
+
+func (a *githubAPI) submitReview(ctx context.Context, prURL string, review map[string]any) error {
+	_, _, prNumber, err := parsePRURL(prURL)
+	if err != nil {
+		return err
+	}
+
+	_, err = a.do(ctx, "POST", prURL, "pulls/"+prNumber+"/reviews", "application/vnd.github+json", review)
+	if err != nil {
+		return fmt.Errorf("error submitting PR review: %v", err)
+	}
+	return nil
+}
+
+// mergedPRsQuery is what gh pr list --state merged --json
+// number,title,body,files asks for; the REST API would need a call per PR
+// for its files.
+const mergedPRsQuery = `query($owner: String!, $name: String!, $limit: Int!) {
+  repository(owner: $owner, name: $name) {
+    pullRequests(states: MERGED, first: $limit, orderBy: {field: CREATED_AT, direction: DESC}) {
+      nodes { number title body files(first: 100) { nodes { path } } }
+    }
+  }
+}`
+
+func (a *githubAPI) mergedPRs(ctx context.Context, prURL string, limit int) ([]relatedPR, error) {
+	org, repo, _, err := parsePRURL(prURL)
+	if err != nil {
+		return nil, err
+	}
+	base, err := githubAPIBase(prURL)
+	if err != nil {
+		return nil, err
+	}
+	// GitHub Enterprise Server serves GraphQL at /api/graphql, next to
+	// /api/v3.
+	endpoint := strings.TrimSuffix(base, "/v3") + "/graphql"
+
+	// The query changes nothing, so it is retried like a GET.
+	data, err := a.send(withRetries(ctx), "POST", endpoint, "application/json", map[string]any{
+		"query":     mergedPRsQuery,
+		"variables": map[string]any{"owner": org, "name": repo, "limit": limit},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing merged PRs: %v", err)
+	}
+
+	var result struct {
+		Data struct {
+			Repository struct {
+				PullRequests struct {
+					Nodes []struct {
+						Number int    `json:"number"`
+						Title  string `json:"title"`
+						Body   string `json:"body"`
+						Files  struct {
+							Nodes []struct {
+								Path string `json:"path"`
+							} `json:"nodes"`
+						} `json:"files"`
+					} `json:"nodes"`
+				} `json:"pullRequests"`
+			} `json:"repository"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("error parsing merged PRs: %v", err)
+	}
+	if len(result.Errors) > 0 {
+		return nil, fmt.Errorf("error listing merged PRs: %s", result.Errors[0].Message)
+	}
+
+	var prs []relatedPR
+	for _, node := range result.Data.Repository.PullRequests.Nodes {
+		pr := relatedPR{Number: node.Number, Title: node.Title, Body: node.Body}
+		for _, file := range node.Files.Nodes {
+			pr.Files = append(pr.Files, relatedPRFile{Path: file.Path})
+		}
+		prs = append(prs, pr)
+	}
+	return prs, nil
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"strings"
+
+	"github.com/loadfms/prgpt/pkg/prgpt"
+)
+
+const gitlabMRMarker = "/-/merge_requests/"
+
+// parseMRURL splits a GitLab merge request URL such as
+// https://gitlab.com/group/subgroup/project/-/merge_requests/12.
+func parseMRURL(mrURL string) (host, project, mrNumber string, err error) {
+	u, err := url.Parse(mrURL)
+	if err != nil {
+		return "", "", "", fmt.Errorf("invalid MR URL: %v", err)
+	}
+
+	project, mrNumber, ok := strings.Cut(strings.Trim(u.Path, "/"), strings.Trim(gitlabMRMarker, "/"))
+	project = strings.Trim(project, "/")
+	mrNumber = strings.Trim(mrNumber, "/")
+	if !ok || project == "" || mrNumber == "" {
+		return "", "", "", fmt.Errorf("invalid MR URL")
+	}
+	return u.Host, project, strings.Split(mrNumber, "/")[0], nil
+}
+
+type gitlabForge struct{}
+
+type gitlabMR struct {
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Labels      []string `json:"labels"`
+	SHA         string   `json:"sha"`
+}
+
+// glabMR runs a glab mr subcommand against the merge request.
+func glabMR(ctx context.Context, mrURL, subcommand string, args ...string) ([]byte, error) {
+	host, project, mrNumber, err := parseMRURL(mrURL)
+	if err != nil {
+		return nil, err
+	}
+
+	args = append([]string{"mr", subcommand, mrNumber, "-R", "https://" + host + "/" + project}, args...)
+	output, err := prgpt.Command(ctx, "glab", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("error running glab mr %s: %v", subcommand, err)
+	}
+	return output, nil
+}
+
+func viewMR(ctx context.Context, mrURL string) (gitlabMR, error) {
+	var mr gitlabMR
+	output, err := glabMR(ctx, mrURL, "view", "-F", "json")
+	if err != nil {
+		return mr, err
+	}
+	if err := json.Unmarshal(output, &mr); err != nil {
+		return mr, fmt.Errorf("error parsing glab mr view output: %v", err)
+	}
+	return mr, nil
+}
+
+func (gitlabForge) Diff(ctx context.Context, mrURL string) (string, error) {
+	output, err := glabMR(ctx, mrURL, "diff", "--raw")
+	return string(output), err
+}
+
+// DiffSince rebuilds a unified diff from the compare API, whose file diffs
+// come without their headers.
+func (gitlabForge) DiffSince(ctx context.Context, mrURL, oldSHA, newSHA string) (string, error) {
+	host, project, _, err := parseMRURL(mrURL)
+	if err != nil {
+		return "", err
+	}
+
+	endpoint := "projects/" + url.PathEscape(project) + "/repository/compare?from=" + oldSHA + "&to=" + newSHA
+	output, err := prgpt.Command(ctx, "glab", "api", "--hostname", host, endpoint).Output()
+	if err != nil {
+		return "", fmt.Errorf("error running glab api compare: %v", err)
+	}
+
+	var comparison struct {
+		Diffs []struct {
+			OldPath string `json:"old_path"`
+			NewPath string `json:"new_path"`
+			Diff    string `json:"diff"`
+		} `json:"diffs"`
+	}
+	if err := json.Unmarshal(output, &comparison); err != nil {
+		return "", fmt.Errorf("error parsing glab api compare output: %v", err)
+	}
+
+	var b strings.Builder
+	for _, d := range comparison.Diffs {
+		fmt.Fprintf(&b, "diff --git a/%s b/%s\n--- a/%s\n+++ b/%s\n%s", d.OldPath, d.NewPath, d.OldPath, d.NewPath, d.Diff)
+	}
+	return b.String(), nil
+}
+
+func (gitlabForge) Info(ctx context.Context, mrURL string) (prInfo, error) {
+	mr, err := viewMR(ctx, mrURL)
+	return prInfo{Title: mr.Title, Body: mr.Description, Labels: mr.Labels}, err
+}
+
+func (gitlabForge) Issue(ctx context.Context, mrURL, number string) (prInfo, error) {
+	host, project, _, err := parseMRURL(mrURL)
+	if err != nil {
+		return prInfo{}, err
+	}
+
+	output, err := prgpt.Command(ctx, "glab", "issue", "view", number, "-R", "https://"+host+"/"+project, "-F", "json").Output()
+	if err != nil {
+		return prInfo{}, fmt.Errorf("error running glab issue view: %v", err)
+	}
+
+	var issue gitlabMR
+	if err := json.Unmarshal(output, &issue); err != nil {
+		return prInfo{}, fmt.Errorf("error parsing glab issue view output: %v", err)
+	}
+	return prInfo{Title: issue.Title, Body: issue.Description, Labels: issue.Labels}, nil
+}
+
+func (gitlabForge) HeadSHA(ctx context.Context, mrURL string) (string, error) {
+	mr, err := viewMR(ctx, mrURL)
+	return mr.SHA, err
+}
+
+// IsFastForward checks that the old head is the merge base of both heads,
+// which stops being true once a force-push rewrote it.
+func (gitlabForge) IsFastForward(ctx context.Context, mrURL, oldSHA, newSHA string) bool {
+	host, project, _, err := parseMRURL(mrURL)
+	if err != nil {
+		return false
+	}
+
+	endpoint := "projects/" + url.PathEscape(project) + "/repository/merge_base?refs[]=" + oldSHA + "&refs[]=" + newSHA
+	output, err := prgpt.Command(ctx, "glab", "api", "--hostname", host, endpoint).Output()
+	if err != nil {
+		return false
+	}
+
+	var base struct {
+		ID string `json:"id"`
+	}
+	return json.Unmarshal(output, &base) == nil && base.ID == oldSHA
+}
+
+func (gitlabForge) PostComment(ctx context.Context, mrURL, body string) error {
+	host, project, mrNumber, err := parseMRURL(mrURL)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(map[string]string{"body": body})
+	if err != nil {
+		return fmt.Errorf("error marshaling MR note: %v", err)
+	}
+
+	cmd := prgpt.Command(ctx, "glab", "api", "--hostname", host, "-X", "POST",
+		"projects/"+url.PathEscape(project)+"/merge_requests/"+mrNumber+"/notes", "--input", "-")
+	cmd.Stdin = bytes.NewReader(payload)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("error posting MR note: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+// RepoFile reads the file from the project's default branch, which the
+// raw file API uses without a ref.
+func (gitlabForge) RepoFile(ctx context.Context, mrURL, path string) ([]byte, error) {
+	host, project, _, err := parseMRURL(mrURL)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := "projects/" + url.PathEscape(project) + "/repository/files/" + url.PathEscape(path) + "/raw"
+	output, err := prgpt.Command(ctx, "glab", "api", "--hostname", host, endpoint).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && strings.Contains(string(exitErr.Stderr), "404") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error running glab api files: %v", err)
+	}
+	return output, nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"path"
+	"strings"
+)
+
+// globMatch reports whether name matches a slash-separated glob pattern.
+// Besides the path.Match syntax, "**" matches any number of directories.
+// Patterns without a slash are matched against the base name as well.
+func globMatch(pattern, name string) bool {
+	if !strings.Contains(pattern, "/") {
+		if ok, _ := path.Match(pattern, path.Base(name)); ok {
+			return true
+		}
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
@@ -2,4 +2,10 @@ module github.com/loadfms/prgpt
 
 go 1.21.6
 
-require github.com/pelletier/go-toml/v2 v2.1.1
+require (
+	github.com/pelletier/go-toml/v2 v2.1.1
+	golang.org/x/net v0.25.0
+	golang.org/x/sys v0.20.0
+)
+
+require golang.org/x/text v0.15.0 // indirect
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// historyEntry is one review in the history log.
+type historyEntry struct {
+	ID       string    `json:"id"`
+	Time     time.Time `json:"time"`
+	PR       string    `json:"pr"`
+	Commit   string    `json:"commit,omitempty"`
+	Provider string    `json:"provider"`
+	Model    string    `json:"model"`
+	// Prompt names the prompt template and a hash of its text, so reviews
+	// made with an edited prompt can be told apart.
+	Prompt   string `json:"prompt"`
+	Tokens   int    `json:"tokens"`
+	Approved bool   `json:"approved"`
+	Review   string `json:"review"`
+}
+
+// dataDir is where prgpt keeps its logs: $XDG_DATA_HOME/prgpt or
+// ~/.local/share/prgpt.
+func dataDir() (string, error) {
+	base := os.Getenv("XDG_DATA_HOME")
+	if base == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("error locating home directory: %v", err)
+		}
+		base = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(base, "prgpt"), nil
+}
+
+// historyPath is the JSONL log of all reviews.
+func historyPath() (string, error) {
+	dir, err := dataDir()
+	return filepath.Join(dir, "history.jsonl"), err
+}
+
+// appendJSONLine appends v to a JSONL log, creating it if need be. A single
+// write of a whole line keeps concurrent runs from interleaving entries.
+func appendJSONLine(path string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// promptVersion identifies the prompt a review was made with.
+func promptVersion(name, persona string, tmpl PromptTemplate) string {
+	if name == "" {
+		name = "default"
+	}
+	if persona != "" {
+		name += "+" + persona
+	}
+	return name + "@" + cacheKey(tmpl.System, tmpl.User)[:8]
+}
+
+// recordReview appends the review to the history log, giving it an ID.
+func recordReview(entry historyEntry) (string, error) {
+	path, err := historyPath()
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256([]byte(entry.Time.String() + entry.PR + entry.Review))
+	entry.ID = hex.EncodeToString(sum[:])[:8]
+
+	if err := appendJSONLine(path, entry); err != nil {
+		return "", fmt.Errorf("error writing history: %v", err)
+	}
+	return entry.ID, nil
+}
+
+func loadHistory() ([]historyEntry, error) {
+	path, err := historyPath()
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening history: %v", err)
+	}
+	defer file.Close()
+
+	var entries []historyEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var entry historyEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// A line cut short by a crash must not hide the others.
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading history: %v", err)
+	}
+	return entries, nil
+}
+
+// runHistory implements "prgpt history": the most recent reviews, newest
+// first.
+func runHistory(args []string) int {
+	fs := newCommandFlagSet("history")
+	limit := fs.Int("n", 20, "How many reviews to list; 0 lists all")
+	pr := fs.String("pr", "", "Only list the reviews of this PR")
+	if err := fs.Parse(args); err != nil {
+		return parseExit(err)
+	}
+
+	entries, err := loadHistory()
+	if err != nil {
+		fmt.Println(err)
+		return exitError
+	}
+
+	shown := 0
+	for i := len(entries) - 1; i >= 0 && (*limit <= 0 || shown < *limit); i-- {
+		e := entries[i]
+		if *pr != "" && e.PR != *pr {
+			continue
+		}
+		verdict := "rejected"
+		if e.Approved {
+			verdict = "approved"
+		}
+		fmt.Printf("%s  %s  %-8s  %-7.7s  %-20s  %6d tokens  %s\n", e.ID, e.Time.Local().Format("2006-01-02 15:04"), verdict, e.Commit, e.Model, e.Tokens, e.PR)
+		shown++
+	}
+	if shown == 0 {
+		fmt.Println("No reviews recorded yet.")
+	}
+	return exitApproved
+}
+
+// runShow implements "prgpt show <id>": a past review as it was printed.
+// Any unambiguous prefix of the ID will do.
+func runShow(args []string) int {
+	fs := newCommandFlagSet("show")
+	if err := fs.Parse(args); err != nil {
+		return parseExit(err)
+	}
+	if fs.NArg() != 1 {
+		fs.SetOutput(os.Stdout)
+		fs.Usage()
+		return exitError
+	}
+	args = fs.Args()
+
+	entries, err := loadHistory()
+	if err != nil {
+		fmt.Println(err)
+		return exitError
+	}
+
+	var matches []historyEntry
+	for _, e := range entries {
+		if strings.HasPrefix(e.ID, args[0]) {
+			matches = append(matches, e)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		fmt.Println("No review with ID", args[0])
+		return exitError
+	case 1:
+	default:
+		fmt.Printf("ID %s is ambiguous: %d reviews match\n", args[0], len(matches))
+		return exitError
+	}
+
+	e := matches[0]
+	fmt.Printf("Review %s of %s\nCommit: %s\nDate: %s\nModel: %s/%s, prompt %s, %d tokens\n\n%s\n",
+		e.ID, e.PR, e.Commit, e.Time.Local().Format(time.RFC1123), e.Provider, e.Model, e.Prompt, e.Tokens, strings.TrimSpace(e.Review))
+	return exitApproved
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/loadfms/prgpt/pkg/prgpt"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// runInit asks for the provider, its credentials and the model, checks them
+// with a test call and writes the config file.
+func runInit(in io.Reader, out io.Writer) int {
+	path, err := configPath()
+	if err != nil {
+		fmt.Fprintln(out, "Error locating config file:", err)
+		return exitError
+	}
+
+	reader := bufio.NewReader(in)
+	ask := func(question, def string) string {
+		if def != "" {
+			fmt.Fprintf(out, "%s [%s]: ", question, def)
+		} else {
+			fmt.Fprintf(out, "%s: ", question)
+		}
+		answer, _ := reader.ReadString('\n')
+		if answer = strings.TrimSpace(answer); answer != "" {
+			return answer
+		}
+		return def
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		if !strings.HasPrefix(strings.ToLower(ask(path+" exists. Overwrite it? (y/N)", "")), "y") {
+			return exitError
+		}
+	}
+
+	var cfg FileConfig
+	file := map[string]any{}
+
+	cfg.Provider = ask("Provider (openai, azure, anthropic, ollama)", defaultProvider)
+	if _, ok := defaultModels[cfg.Provider]; !ok || cfg.Provider == "mock" {
+		fmt.Fprintln(out, "Unknown provider:", cfg.Provider)
+		return exitError
+	}
+	file["provider"] = cfg.Provider
+
+	switch cfg.Provider {
+	case "openai":
+		cfg.ApiKey.Key = ask("OpenAI API key", "")
+		file["apikey"] = map[string]any{"key": cfg.ApiKey.Key}
+	case "anthropic":
+		cfg.Anthropic.Key = ask("Anthropic API key", "")
+		file["anthropic"] = map[string]any{"key": cfg.Anthropic.Key}
+	case "azure":
+		cfg.Azure.Endpoint = ask("Azure OpenAI endpoint (https://<resource>.openai.azure.com)", "")
+		cfg.Azure.Deployment = ask("Deployment name", "")
+		cfg.Azure.Key = ask("API key", "")
+		file["azure"] = map[string]any{"endpoint": cfg.Azure.Endpoint, "deployment": cfg.Azure.Deployment, "key": cfg.Azure.Key}
+	case "ollama":
+		cfg.Ollama.BaseURL = ask("Ollama URL", prgpt.DefaultOllamaURL)
+		file["ollama"] = map[string]any{"base_url": cfg.Ollama.BaseURL}
+	}
+
+	cfg.Model.Name = ask("Model", defaultModels[cfg.Provider])
+	file["model"] = map[string]any{"name": cfg.Model.Name}
+
+	fmt.Fprintln(out, "Checking the settings with a test call...")
+	if err := checkProvider(cfg); err != nil {
+		fmt.Fprintln(out, "The test call failed:", err)
+		if hint := apiErrorHint(err); hint != "" {
+			fmt.Fprintln(out, "Hint:", hint)
+		}
+		if !strings.HasPrefix(strings.ToLower(ask("Save the config anyway? (y/N)", "")), "y") {
+			return exitError
+		}
+	}
+
+	data, err := toml.Marshal(file)
+	if err != nil {
+		fmt.Fprintln(out, "Error encoding config:", err)
+		return exitError
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		fmt.Fprintln(out, "Error creating config directory:", err)
+		return exitError
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		fmt.Fprintln(out, "Error writing config file:", err)
+		return exitError
+	}
+	// WriteFile keeps the mode of an existing file.
+	if err := os.Chmod(path, 0o600); err != nil {
+		fmt.Fprintln(out, "Error restricting config file permissions:", err)
+		return exitError
+	}
+
+	fmt.Fprintln(out, "Wrote", path)
+	return exitApproved
+}
+
+// checkProvider makes a minimal completion call with cfg.
+func checkProvider(cfg FileConfig) error {
+	client, err := newHTTPClient(cfg)
+	if err != nil {
+		return err
+	}
+	provider, err := newProvider(cfg.Provider, cfg, client)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_, err = provider.Complete(ctx, prgpt.CompletionRequest{Model: cfg.Model.Name, Prompt: "Reply with OK.", MaxTokens: 5})
+	return err
+}
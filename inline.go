@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	inlineInstruction  = "Anchor every finding to the line it is about, using the line numbers of the new version of the file and only lines shown in the diff."
+	suggestInstruction = "Where a finding has a concrete fix, anchor it as path:line, or path:start-end for several lines, using line numbers of the new version of the file shown in the diff, and put right below it a ```suggestion block, indented like the finding's text, holding the exact code that replaces those lines."
+)
+
+var hunkHeader = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+(\d+)(?:,\d+)? @@`)
+
+// reviewComment is an inline comment of a pull request review.
+type reviewComment struct {
+	Path string `json:"path"`
+	// StartLine is the first line of a comment on several lines, which
+	// ends at Line.
+	StartLine int    `json:"start_line,omitempty"`
+	StartSide string `json:"start_side,omitempty"`
+	Line      int    `json:"line"`
+	Side      string `json:"side"`
+	Body      string `json:"body"`
+}
+
+// diffRightLines returns, per file, the lines of the new version that appear
+// in the diff. GitHub only accepts inline comments on those lines.
+func diffRightLines(files []fileDiff) map[string]map[int]bool {
+	lines := map[string]map[int]bool{}
+	for _, f := range files {
+		inFile := map[int]bool{}
+		line := 0
+		for _, l := range strings.Split(f.Text, "\n") {
+			if m := hunkHeader.FindStringSubmatch(l); m != nil {
+				line, _ = strconv.Atoi(m[1])
+				continue
+			}
+			if line == 0 {
+				continue
+			}
+			switch {
+			case strings.HasPrefix(l, "+"), strings.HasPrefix(l, " "):
+				inFile[line] = true
+				line++
+			}
+		}
+		lines[f.Path] = inFile
+	}
+	return lines
+}
+
+// inlineComments turns the findings anchored to a line of the diff into
+// review comments and returns the review without them. A finding's
+// suggestion becomes a suggestion authors can apply from the comment when
+// every line it replaces is in the diff.
+func inlineComments(review string, findings []Finding, files []fileDiff, labels severityLabels) (string, []reviewComment) {
+	lines := diffRightLines(files)
+
+	var anchored []Finding
+	var comments []reviewComment
+	for _, f := range findings {
+		if f.Line == 0 || !lines[f.File][f.Line] {
+			continue
+		}
+		anchored = append(anchored, f)
+		comment := reviewComment{
+			Path: f.File,
+			Line: f.Line,
+			Side: "RIGHT",
+			Body: "**" + labels.label(f.Severity) + "**: " + f.Message,
+		}
+		if f.Suggestion != "" && linesInDiff(lines[f.File], f.Line, max(f.EndLine, f.Line)) {
+			if f.EndLine > f.Line {
+				comment.StartLine, comment.StartSide, comment.Line = f.Line, "RIGHT", f.EndLine
+			}
+			comment.Body += "\n\n```suggestion\n" + f.Suggestion + "\n```"
+		}
+		comments = append(comments, comment)
+	}
+	return removeFindings(review, anchored), comments
+}
+
+func linesInDiff(lines map[int]bool, start, end int) bool {
+	for l := start; l <= end; l++ {
+		if !lines[l] {
+			return false
+		}
+	}
+	return true
+}
+
+// submitPRReview submits a pull request review with gh.
+func submitPRReview(ctx context.Context, prURL string, review map[string]any) error {
+	org, repo, prNumber, err := parsePRURL(prURL)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(review)
+	if err != nil {
+		return fmt.Errorf("error marshaling PR review: %v", err)
+	}
+
+	cmd := ghAPI(ctx, prURL, "-X", "POST", "repos/"+org+"/"+repo+"/pulls/"+prNumber+"/reviews", "--input", "-")
+	cmd.Stdin = bytes.NewReader(payload)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("error submitting PR review: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	descriptionTokenLimit = 1000
+	issueTokenLimit       = 500
+	maxLinkedIssues       = 3
+)
+
+// closingReference matches the keywords GitHub and GitLab use to link a PR
+// to the issues it closes, e.g. "Fixes #12".
+var closingReference = regexp.MustCompile(`(?i)\b(?:close[sd]?|fix(?:e[sd])?|resolve[sd]?|implement(?:s|ed)?)\s*:?\s+#(\d+)\b`)
+
+func linkedIssues(body string) []string {
+	var numbers []string
+	seen := map[string]bool{}
+	for _, m := range closingReference.FindAllStringSubmatch(body, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			numbers = append(numbers, m[1])
+		}
+	}
+	return numbers
+}
+
+// describePR summarizes the PR's stated intent for the prompt: its title,
+// labels, description and the issues it closes.
+func describePR(ctx context.Context, fg forge, prURL string, info prInfo) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Title: %s\n", info.Title)
+	if len(info.Labels) > 0 {
+		fmt.Fprintf(&b, "Labels: %s\n", strings.Join(info.Labels, ", "))
+	}
+	if body := strings.TrimSpace(info.Body); body != "" {
+		body, _ = truncateToTokens(body, descriptionTokenLimit)
+		fmt.Fprintf(&b, "Description:\n%s\n", body)
+	}
+
+	issues := linkedIssues(info.Body)
+	if len(issues) > maxLinkedIssues {
+		issues = issues[:maxLinkedIssues]
+	}
+	for _, number := range issues {
+		issue, err := fg.Issue(ctx, prURL, number)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping linked issue #%s: %v\n", number, err)
+			continue
+		}
+		body, _ := truncateToTokens(strings.TrimSpace(issue.Body), issueTokenLimit)
+		fmt.Fprintf(&b, "Linked issue #%s: %s\n%s\n", number, issue.Title, body)
+	}
+
+	return strings.TrimSpace(b.String())
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/loadfms/prgpt/pkg/prgpt"
+)
+
+// jsonReport is the -output json document.
+type jsonReport struct {
+	Summary    string    `json:"summary"`
+	Issues     []Finding `json:"issues"`
+	Approved   bool      `json:"approved"`
+	TokensUsed int       `json:"tokens_used"`
+	Model      string    `json:"model"`
+}
+
+// reviewSummary is the review without its findings and verdict lines.
+func reviewSummary(review string, findings []Finding) string {
+	var lines []string
+	for _, line := range strings.Split(removeFindings(review, findings), "\n") {
+		if _, found := prgpt.ParseVerdict(line); !found {
+			lines = append(lines, line)
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+func jsonOutput(review string, findings []Finding, approved bool, tokens int, model string) ([]byte, error) {
+	if findings == nil {
+		findings = []Finding{}
+	}
+	return json.MarshalIndent(jsonReport{
+		Summary:    reviewSummary(review, findings),
+		Issues:     findings,
+		Approved:   approved,
+		TokensUsed: tokens,
+		Model:      model,
+	}, "", "  ")
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/loadfms/prgpt/pkg/prgpt"
+)
+
+// JuryModel is one member of the -jury panel.
+type JuryModel struct {
+	Name   string  `toml:"name"`
+	Weight float64 `toml:"weight"`
+}
+
+type juryVote struct {
+	model    JuryModel
+	approved bool
+	found    bool
+	review   string
+}
+
+// checkJuryWeights rejects the [jury] models of settings, the decoded
+// config or the tables under prefix, whose weight is not positive, which
+// would let models carrying less than half of the weight decide.
+func checkJuryWeights(path string, data []byte, prefix string, settings map[string]any) error {
+	jury, _ := settings["jury"].(map[string]any)
+	models, _ := jury["models"].([]any)
+	// Models given as [[jury.models]] tables set their weight on lines of
+	// their own; those given inline, on the line of models.
+	weightRows := settingLines(data, prefix+"jury.models.weight")
+	weighted := 0
+	for _, m := range models {
+		model, _ := m.(map[string]any)
+		value, ok := model["weight"]
+		if !ok {
+			continue
+		}
+		weighted++
+		weight, _ := value.(float64)
+		if n, ok := value.(int64); ok {
+			weight = float64(n)
+		}
+		if weight > 0 {
+			continue
+		}
+		row := 0
+		if rows := settingLines(data, prefix+"jury.models"); len(rows) > 0 {
+			row = rows[0]
+		}
+		if weighted <= len(weightRows) {
+			row = weightRows[weighted-1]
+		}
+		return fmt.Errorf("error in %s at line %d: the weight of [jury] model %q must be more than 0", path, row, model["name"])
+	}
+	return nil
+}
+
+// reviewJury runs the review once per jury model and combines the verdicts:
+// the PR is approved when the models approving it carry more than half of
+// the total weight. A model without a verdict counts as rejecting.
+func (r *reviewer) reviewJury(models []JuryModel, review func() (string, error)) (string, error) {
+	if len(models) == 0 {
+		return "", fmt.Errorf("-jury needs at least one model in [jury] models")
+	}
+
+	base := r.model
+	defer func() { r.model = base }()
+
+	var votes []juryVote
+	for _, m := range models {
+		if m.Weight == 0 {
+			m.Weight = 1
+		}
+		r.model = m.Name
+
+		content, err := review()
+		if err != nil {
+			return "", fmt.Errorf("error reviewing with %s: %w", m.Name, err)
+		}
+		approved, found := prgpt.ParseVerdict(content)
+		votes = append(votes, juryVote{model: m, approved: approved, found: found, review: content})
+	}
+
+	return formatJury(votes), nil
+}
+
+func formatJury(votes []juryVote) string {
+	var total, approving float64
+	var b strings.Builder
+
+	b.WriteString("## Jury verdict\n\n| Model | Weight | Approved |\n|---|---|---|\n")
+	for _, v := range votes {
+		verdict := fmt.Sprint(v.approved)
+		if !v.found {
+			verdict = "no verdict"
+		}
+		fmt.Fprintf(&b, "| %s | %g | %s |\n", v.model.Name, v.model.Weight, verdict)
+
+		total += v.model.Weight
+		if v.approved {
+			approving += v.model.Weight
+		}
+	}
+
+	if approving > 0 && approving < total {
+		b.WriteString("\n**The models disagree.** Read the individual reviews below before acting on the verdict.\n")
+	}
+
+	for _, v := range votes {
+		fmt.Fprintf(&b, "\n### %s\n%s\n", v.model.Name, strings.TrimSpace(v.review))
+	}
+
+	approved := approving*2 > total
+	fmt.Fprintf(&b, "\nWeighted approval: %g of %g\n\nApproved: %t\n", approving, total, approved)
+	return b.String()
+}
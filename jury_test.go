@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/loadfms/prgpt/pkg/prgpt"
+)
+
+// modelProvider answers with the review set for the requested model.
+type modelProvider map[string]string
+
+func (p modelProvider) Complete(ctx context.Context, req prgpt.CompletionRequest) (prgpt.Completion, error) {
+	return prgpt.Completion{Content: p[req.Model], Tokens: 10}, nil
+}
+
+func TestReviewJury(t *testing.T) {
+	reviews := modelProvider{
+		"yes":  "Fine.\n\nApproved: true",
+		"no":   "Broken.\n\nApproved: false",
+		"mute": "I am not sure.",
+	}
+	tests := []struct {
+		name     string
+		models   []JuryModel
+		weighted string
+		approved bool
+		disagree bool
+	}{
+		{"heavy approval", []JuryModel{{"yes", 3}, {"no", 1}, {"no", 1}}, "Weighted approval: 3 of 5", true, true},
+		{"half is not enough", []JuryModel{{"yes", 1}, {"yes", 1}, {"no", 2}}, "Weighted approval: 2 of 4", false, true},
+		{"weights default to 1", []JuryModel{{"yes", 0}, {"yes", 0}, {"no", 0}}, "Weighted approval: 2 of 3", true, true},
+		{"no verdict rejects", []JuryModel{{"yes", 1}, {"mute", 1}, {"mute", 1}}, "Weighted approval: 1 of 3", false, true},
+		{"unanimous", []JuryModel{{"yes", 1}, {"yes", 2}, {"yes", 3}}, "Weighted approval: 6 of 6", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &reviewer{ctx: context.Background(), provider: reviews, model: "base", timings: &timings{}}
+			got, err := r.reviewJury(tt.models, func() (string, error) { return r.complete("diff", nil) })
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(got, tt.weighted) {
+				t.Errorf("review lacks %q:\n%s", tt.weighted, got)
+			}
+			if approved, found := prgpt.ParseVerdict(got); !found || approved != tt.approved {
+				t.Errorf("verdict = %t (found %t), want %t", approved, found, tt.approved)
+			}
+			if disagree := strings.Contains(got, "The models disagree"); disagree != tt.disagree {
+				t.Errorf("disagreement noted: %t, want %t", disagree, tt.disagree)
+			}
+			if r.tokens != 30 || r.model != "base" {
+				t.Errorf("tokens = %d, model = %q; want the 30 tokens of the three calls and the model restored", r.tokens, r.model)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const lineNumbersInstruction = "Every added and unchanged line of the diff starts with its line number in the new version of the file followed by a |, e.g. \"+12| \"; use those numbers for locations, and leave the numbers out of any code you quote or suggest."
+
+// lineNumberPrefix matches the prefix annotateLineNumbers adds, as the model
+// may copy it into suggested code.
+var lineNumberPrefix = regexp.MustCompile(`^\d+\| ?`)
+
+// annotateLineNumbers prefixes every added and context line of the diff's
+// hunks with its line number in the new version of the file, so the model
+// need not count lines from the hunk headers. Removed lines have no number
+// there and are left as they are.
+func annotateLineNumbers(diff string) string {
+	var b strings.Builder
+	b.Grow(len(diff) + len(diff)/8)
+	line := 0
+	for _, l := range strings.SplitAfter(diff, "\n") {
+		if m := hunkHeader.FindStringSubmatch(l); m != nil {
+			line, _ = strconv.Atoi(m[1])
+			b.WriteString(l)
+			continue
+		}
+		if isFileHeader(l) {
+			line = 0
+		}
+		if line > 0 && (strings.HasPrefix(l, "+") || strings.HasPrefix(l, " ")) {
+			fmt.Fprintf(&b, "%c%d| %s", l[0], line, l[1:])
+			line++
+			continue
+		}
+		b.WriteString(l)
+	}
+	return b.String()
+}
+
+// stripLineNumbers removes the prefixes of annotateLineNumbers from code
+// when every line has one.
+func stripLineNumbers(code string) string {
+	lines := strings.Split(code, "\n")
+	for _, l := range lines {
+		if l != "" && !lineNumberPrefix.MatchString(l) {
+			return code
+		}
+	}
+	for i, l := range lines {
+		lines[i] = lineNumberPrefix.ReplaceAllString(l, "")
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// levelTrace is the level of -vv, for the payloads of API calls.
+const levelTrace = slog.LevelDebug - 4
+
+// redactedHeaders carry credentials and are never logged.
+var redactedHeaders = []string{"Authorization", "Api-Key", "X-Api-Key"}
+
+// setupLogging sends the log to stderr: nothing but warnings by default,
+// diagnostics with -v and the payloads of API calls with -vv.
+func setupLogging(v, vv bool) {
+	level := slog.LevelWarn
+	switch {
+	case vv:
+		level = levelTrace
+	case v:
+		level = slog.LevelDebug
+	}
+	handler := slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.LevelKey && a.Value.Any() == levelTrace {
+				a.Value = slog.StringValue("TRACE")
+			}
+			return a
+		},
+	})
+	slog.SetDefault(slog.New(handler))
+}
+
+// logConfig logs every resolved setting and where it came from, with the
+// secrets masked.
+func logConfig(cfg FileConfig, origins configOrigins) {
+	if !slog.Default().Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+	for _, s := range configSettings(cfg) {
+		value := slog.AnyValue(s.value)
+		if s.secret {
+			value = slog.StringValue(maskSetting(s.value))
+		}
+		slog.Debug("config", "key", s.key, "value", value, "origin", origins.of(s.key))
+	}
+}
+
+// loggingTransport logs every HTTP request the API clients make with its
+// status and duration, and with -vv the request and response bodies.
+// Headers in redact, e.g. those of [openai] headers, are redacted like
+// redactedHeaders.
+type loggingTransport struct {
+	next   http.RoundTripper
+	redact []string
+}
+
+func (t *loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	url := req.URL.Scheme + "://" + req.URL.Host + req.URL.Path
+	if slog.Default().Enabled(ctx, levelTrace) {
+		headers := req.Header.Clone()
+		for _, h := range append(append([]string{}, redactedHeaders...), t.redact...) {
+			if headers.Get(h) != "" {
+				headers.Set(h, "REDACTED")
+			}
+		}
+		var body []byte
+		if req.GetBody != nil {
+			if r, err := req.GetBody(); err == nil {
+				body, _ = io.ReadAll(r)
+				r.Close()
+			}
+		}
+		slog.Log(ctx, levelTrace, "http request", "method", req.Method, "url", url, "headers", headers, "body", string(body))
+	}
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		slog.Debug("http error", "method", req.Method, "url", url, "error", err, "duration", time.Since(start))
+		return resp, err
+	}
+	slog.Debug("http response", "method", req.Method, "url", url, "status", resp.StatusCode, "duration", time.Since(start))
+
+	// Streamed responses are left alone so they still arrive as they are
+	// generated.
+	contentType := resp.Header.Get("Content-Type")
+	if slog.Default().Enabled(ctx, levelTrace) && !strings.HasPrefix(contentType, "text/event-stream") && !strings.HasPrefix(contentType, "application/x-ndjson") {
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		slog.Log(ctx, levelTrace, "http response body", "url", url, "body", string(body))
+	}
+	return resp, nil
+}
@@ -1,178 +1,1350 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"io"
-	"net/http"
+	"log/slog"
 	"os"
-	"os/exec"
-	"os/user"
+	"os/signal"
+	"regexp"
 	"strings"
+	"time"
 
-	"github.com/pelletier/go-toml/v2"
+	"github.com/loadfms/prgpt/pkg/prgpt"
 )
 
 const (
-	openAICompletionURL = "https://api.openai.com/v1/chat/completions"
-	openAIModel         = "gpt-3.5-turbo-1106"
-	CONFIG_FOLDER       = "/.config/openai/"
-	FILENAME            = "config.toml"
+	askInstruction = "Answer this question about the PR above: "
+
+	sinceLastInstruction = "The diff above only contains the commits pushed since this PR was last reviewed; review just these changes."
 )
 
-type OpenAIRequest struct {
-	Model       string                  `json:"model"`
-	Messages    []OpenAIRequestMessages `json:"messages"`
-	Temperature float64                 `json:"temperature"`
-}
+// Exit codes: a review that is not approved exits non-zero so the tool can
+// gate CI, and errors are kept distinct from rejections.
+const (
+	exitApproved = 0
+	exitRejected = 1
+	exitError    = 2
+)
 
-type OpenAIRequestMessages struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+func main() {
+	os.Exit(run(os.Args[1:]))
 }
 
-type OpenAIReponse struct {
-	ID      string `json:"id"`
-	Object  string `json:"object"`
-	Created int    `json:"created"`
-	Model   string `json:"model"`
-	Usage   struct {
-		PromptTokens     int `json:"prompt_tokens"`
-		CompletionTokens int `json:"completion_tokens"`
-		TotalTokens      int `json:"total_tokens"`
-	} `json:"usage"`
-	Choices []struct {
-		Message struct {
-			Role    string `json:"role"`
-			Content string `json:"content"`
-		} `json:"message"`
-		FinishReason string `json:"finish_reason"`
-		Index        int    `json:"index"`
-	} `json:"choices"`
-}
+// run runs the command given by args, the arguments of prgpt.
+func run(args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "review", "ask":
+			// A question is answered by the review flow, with the question
+			// in place of the review instruction.
+			return runReview(args[0], args[1:])
+		case "init":
+			fs := newCommandFlagSet("init")
+			if err := fs.Parse(args[1:]); err != nil {
+				return parseExit(err)
+			}
+			return runInit(os.Stdin, os.Stdout)
+		case "serve":
+			return runServe(args[1:])
+		case "watch":
+			return runWatch(args[1:])
+		case "history":
+			return runHistory(args[1:])
+		case "show":
+			return runShow(args[1:])
+		case "usage":
+			return runUsage(args[1:])
+		case "describe":
+			return runDescribe(args[1:])
+		case "commit":
+			return runCommit(args[1:])
+		case "release":
+			return runRelease(args[1:])
+		case "range":
+			return runRange(args[1:])
+		case "update":
+			return runUpdate(args[1:])
+		case "version":
+			fs := newCommandFlagSet("version")
+			if err := fs.Parse(args[1:]); err != nil {
+				return parseExit(err)
+			}
+			fmt.Println("prgpt", version)
+			return exitApproved
+		case "help", "-h", "-help", "--help":
+			return runHelp(args[1:])
+		}
+	}
 
-type FileConfig struct {
-	ApiKey struct {
-		Key string `toml:"key"`
-	} `toml:"apikey"`
-	Prompt struct {
-		Custom string `toml:"custom"`
-	} `toml:"prompt"`
+	return runReview("review", args)
 }
 
-func main() {
+// runReview implements "prgpt review" and "prgpt ask", and prgpt given the
+// flags of review without a command.
+func runReview(command string, args []string) int {
+	fs := newCommandFlagSet(command)
+
 	var prURL string
-	flag.StringVar(&prURL, "pr", "", "URL of the pull request")
-	flag.Parse()
+	var prURLs stringList
+	var workers int
+	var summary bool
+	var mergeCommit string
+	var local, staged bool
+	var diffFile string
+	var base string
+	var question string
+	var thread bool
+	var chat bool
+	var newThread bool
+	var outputFormat string
+	var backend string
+	var model string
+	var lang string
+	var profile string
+	var templateFile string
+	var schemaFile string
+	var structured bool
+	var maxFileDiffLines int
+	var normalize bool
+	var excludes stringList
+	var onlyFiles string
+	var chunked bool
+	var concurrency int
+	var sinceLast bool
+	var maxAPICalls int
+	var pace time.Duration
+	var timeout time.Duration
+	var jury bool
+	var consensus bool
+	var multiPass bool
+	var statusFile string
+	var outputDir string
+	var statusCheck bool
+	var post bool
+	var githubActions bool
+	var inline bool
+	var suggest bool
+	var lineNumbers bool
+	var mode string
+	var grepPattern string
+	var fromFindings bool
+	var failOnFlag string
+	var minConfidence float64
+	var coverage bool
+	var relatedPRs int
+	var raw bool
+	var verbose, veryVerbose bool
+	var plain bool
+	var stream bool
+	var quiet bool
+	var promptName string
+	var persona string
+	var prDescription bool
+	var autoTemperature bool
+	var temperature, topP float64
+	var maxTokens int
+	var showTimings bool
+	var explain bool
+	var dryRun bool
+	var noCache bool
+	var recordDir, replayDir string
+	fs.Var(&prURLs, "pr", "URL of the GitHub pull request or GitLab merge request; repeat it, or give - to read URLs from stdin, to review several PRs")
+	fs.IntVar(&workers, "workers", defaultWorkers, "With several -pr, how many PRs to review at the same time")
+	fs.BoolVar(&summary, "summary", false, "With several -pr, print a table of the verdicts instead of every review")
+	fs.BoolVar(&local, "local", false, "Review the uncommitted changes of the local repository instead of a PR")
+	fs.BoolVar(&staged, "staged", false, "With -local, review only the staged changes")
+	fs.StringVar(&base, "base", "", "With -local, review the commits of the current branch since it forked from this branch")
+	fs.StringVar(&diffFile, "diff", "", "Review the patch in this file, or on stdin with -, instead of a PR")
+	fs.StringVar(&mergeCommit, "merge-commit", "", "Review only the conflict resolution of this local merge commit")
+	fs.StringVar(&question, "ask", "", "Ask a question about the PR instead of reviewing it")
+	fs.BoolVar(&thread, "thread", false, "Continue, and remember, the conversation about this PR across runs")
+	fs.BoolVar(&chat, "chat", false, "After the review, keep reading follow-up questions about the PR from stdin and answer them")
+	fs.BoolVar(&newThread, "reset-thread", false, "Forget the remembered conversation about this PR before running")
+	fs.StringVar(&outputFormat, "output", "markdown", "Output format: markdown, text, json or gitlab-codequality")
+	fs.StringVar(&backend, "backend", "", "Provider to review with, overriding provider in config.toml: openai, azure, anthropic, ollama, or mock for a canned offline review (default openai)")
+	fs.StringVar(&model, "model", "", "Model to review with, overriding [model] name (default depends on the provider)")
+	fs.StringVar(&profile, "profile", "", "Use the settings of [profile.NAME] in config.toml over the others; PRGPT_PROFILE sets the default")
+	fs.StringVar(&lang, "lang", "", "Language to write the review in, e.g. pt-BR, overriding language in config.toml (default English)")
+	fs.StringVar(&templateFile, "pr-template", "", "PR description template whose required sections must be filled in")
+	fs.StringVar(&schemaFile, "json-schema-file", "", "JSON Schema the review must follow, sent as the response_format")
+	fs.BoolVar(&structured, "structured", false, "Ask for the findings as JSON following prgpt's own schema instead of Markdown")
+	fs.IntVar(&maxFileDiffLines, "max-file-diff-lines", 0, "Truncate any single file's diff beyond this many lines (0 disables)")
+	fs.BoolVar(&normalize, "normalize", false, "Strip noise from the diff before sending it: binary files, large generated hunks, whitespace-only changes and very long lines; overrides [normalize] enabled")
+	fs.Var(&excludes, "exclude", "Glob of files to leave out of the review, on top of [filters] exclude; repeatable")
+	fs.StringVar(&onlyFiles, "files", "", "Comma-separated globs of the files to review, e.g. \"pkg/api/*.go,cmd/**\"; the rest of the diff is left out")
+	fs.BoolVar(&chunked, "chunked", false, "Review each file separately, reusing cached reviews of unchanged files, then combine them")
+	fs.IntVar(&concurrency, "concurrency", 4, "How many files of a chunked review to review at the same time")
+	fs.BoolVar(&sinceLast, "since-last-review", false, "Review only the commits pushed since the last review of this PR; the first review covers the whole PR")
+	fs.IntVar(&maxAPICalls, "max-api-calls", 0, "Abort once this many API calls were made (0 disables)")
+	fs.DurationVar(&timeout, "timeout", 10*time.Minute, "Give up on the whole run after this long; 0 means no limit")
+	fs.DurationVar(&pace, "pace", 0, "Minimum interval between the starts of consecutive API calls")
+	fs.BoolVar(&jury, "jury", false, "Review with every model in [jury] and combine their verdicts by weight")
+	fs.BoolVar(&consensus, "consensus", false, "Review with every provider and model in [consensus] at the same time and report the findings they agree and disagree on")
+	fs.BoolVar(&multiPass, "multi-pass", false, "Review in separate passes for correctness, security and style, then merge the findings by severity")
+	fs.StringVar(&statusFile, "status-file", "", "Write a JSON status (approved, blockers, tokens) to this file")
+	fs.StringVar(&outputDir, "output-dir", "", "Save review.md, findings.json and raw.txt for each PR under this directory")
+	fs.BoolVar(&inline, "inline", false, "Submit the review as a GitHub PR review, with findings on diff lines as inline comments")
+	fs.StringVar(&mode, "mode", "review", "Kind of review: review, or security for injection, authz, secrets, deserialization and dependency risks with CWE identifiers")
+	fs.BoolVar(&suggest, "suggest", false, "Ask for concrete fixes as suggestion blocks; with -inline they can be applied from the comments")
+	fs.BoolVar(&lineNumbers, "line-numbers", false, "Number the lines of the diff sent to the model, so findings cite the right lines (default with -inline and -suggest)")
+	fs.BoolVar(&githubActions, "github-actions", false, "Annotate the findings, write the review to the job summary and set the approved output in a GitHub Actions step")
+	fs.BoolVar(&post, "post", false, "Post the review as a comment on the PR")
+	fs.BoolVar(&statusCheck, "status-check", false, "Set a \"prgpt\" commit status with the verdict on the PR head")
+	fs.BoolVar(&fromFindings, "verdict-from-findings", false, "Ignore the model's 'Approved' line and approve only if no finding reaches [verdict] fail_on")
+	fs.StringVar(&failOnFlag, "fail-on", "", "Reject only for findings of this severity or worse (blocker, major, minor, nit), overriding [verdict] fail_on; implies -verdict-from-findings")
+	fs.BoolVar(&coverage, "coverage-hint", false, "Report the ratio of changed test lines to changed source lines")
+	fs.IntVar(&relatedPRs, "related-prs", 0, "Include summaries of up to N recently merged PRs touching the same files (an extra GitHub call)")
+	fs.Float64Var(&minConfidence, "min-confidence", 0, "Leave out findings the model is less confident about than this, from 0 to 1, overriding [suppress] min_confidence")
+	fs.StringVar(&grepPattern, "grep-findings", "", "Only show findings matching this regular expression")
+	fs.Float64Var(&temperature, "temperature", prgpt.DefaultTemperature, "Sampling temperature, overriding [model] temperature; 0 for the most deterministic reviews")
+	fs.IntVar(&maxTokens, "max-tokens", 0, "Cap on the response length in tokens, overriding [model] max_tokens (default: the provider's)")
+	fs.Float64Var(&topP, "top-p", 0, "Nucleus sampling probability mass, overriding [model] top_p (default: the provider's)")
+	fs.BoolVar(&autoTemperature, "auto-temperature", false, "Pick the temperature from the diff size: lower for large diffs, higher for small ones")
+	fs.BoolVar(&verbose, "v", false, "Log diagnostics to stderr: the resolved config, HTTP status codes, retries and token usage")
+	fs.BoolVar(&veryVerbose, "vv", false, "Like -v, and also log the payloads of API calls, with credentials redacted")
+	fs.BoolVar(&prDescription, "pr-description", true, "Tell the model the PR's title, description, labels and linked issues")
+	fs.StringVar(&promptName, "prompt", "", "Prompt template from [prompt.templates] to review with")
+	fs.StringVar(&persona, "persona", "", "Reviewer persona to review as: security, performance, api, docs, or one of ~/.config/openai/prompts/<name>.toml")
+	fs.BoolVar(&quiet, "quiet", false, "Only print the verdict; the exit code still reflects it")
+	fs.BoolVar(&stream, "stream", false, "Print the review as it is generated")
+	fs.BoolVar(&raw, "raw", false, "Print the model's response verbatim, without any post-processing")
+	fs.BoolVar(&plain, "plain", false, "Print the Markdown review as is, without colors, even on a terminal")
+	fs.BoolVar(&showTimings, "timings", false, "Print how long each phase took to stderr")
+	fs.BoolVar(&dryRun, "dry-run", false, "Print the estimated tokens and cost of the review without calling the API; tokens are approximated, not counted with the model's tokenizer")
+	fs.BoolVar(&noCache, "no-cache", false, "Run a fresh review instead of reusing a cached one, and skip the per-file cache of -chunked")
+	fs.StringVar(&recordDir, "record", "", "Save every API request and its response to this directory, for -replay")
+	fs.StringVar(&replayDir, "replay", "", "Answer API requests from the responses -record saved to this directory, without calling the provider")
+	fs.BoolVar(&explain, "explain-config", false, "Print every setting with its value and where it came from, then exit")
+	if err := fs.Parse(args); err != nil {
+		return parseExit(err)
+	}
+	setupLogging(verbose, veryVerbose)
+	if command == "ask" {
+		if question != "" {
+			fmt.Println("prgpt ask takes the question as an argument rather than -ask")
+			return exitError
+		}
+		q, err := askQuestion(fs)
+		if err != nil {
+			fmt.Println(err)
+			return exitError
+		}
+		// Set as a flag, so the reviews of a batch are asked it too.
+		fs.Set("ask", q)
+	} else if fs.NArg() > 0 {
+		fmt.Printf("Unknown command %q; run \"prgpt help\" for the commands\n", fs.Arg(0))
+		return exitError
+	}
+
+	// Interrupting cancels the calls in flight rather than killing the
+	// process, so partial state such as the thread is not written.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	defer func() {
+		switch ctx.Err() {
+		case context.DeadlineExceeded:
+			fmt.Fprintf(os.Stderr, "Gave up after -timeout %v\n", timeout)
+		case context.Canceled:
+			fmt.Fprintln(os.Stderr, "Interrupted")
+		}
+	}()
 
-	cfg, err := loadConfig()
+	if len(prURLs) > 1 || (len(prURLs) == 1 && prURLs[0] == "-") {
+		urls := []string(prURLs)
+		if len(prURLs) == 1 {
+			var err error
+			urls, err = readPRList(os.Stdin)
+			if err != nil {
+				fmt.Println(err)
+				return exitError
+			}
+			if len(urls) == 0 {
+				fmt.Println("No PR URLs on stdin; nothing to review")
+				return exitError
+			}
+		}
+		if chat || stream || statusFile != "" || diffFile != "" || outputFormat == "gitlab-codequality" {
+			fmt.Println("Reviewing several PRs cannot be combined with -chat, -stream, -status-file, -diff or -output gitlab-codequality")
+			return exitError
+		}
+		return runBatch(ctx, urls, batchArgs(fs), workers, summary, outputFormat == "json")
+	}
+	if len(prURLs) == 1 {
+		prURL = prURLs[0]
+	}
 
-	if prURL == "" {
-		fmt.Println("Usage: pr_review_cli -pr <PR_URL>")
-		return
+	switch outputFormat {
+	case "markdown", "text", "json":
+	case "gitlab-codequality":
+		if schemaFile != "" {
+			fmt.Println("-output gitlab-codequality cannot be combined with -json-schema-file")
+			return exitError
+		}
+	default:
+		fmt.Println("Unknown output format:", outputFormat)
+		return exitError
 	}
 
-	prDiff, err := getPRDiff(prURL)
-	if err != nil {
-		fmt.Println("Error fetching PR diff:", err)
-		return
+	if stream && (jury || multiPass || schemaFile != "" || grepPattern != "" || outputFormat != "markdown") {
+		fmt.Println("-stream cannot be combined with -jury, -multi-pass, -json-schema-file, -grep-findings or -output")
+		return exitError
 	}
 
-	finalConsideration, err := generateFinalConsideration(prDiff, cfg.ApiKey.Key)
-	if err != nil {
-		fmt.Println("Error generating final consideration:", err)
-		return
+	if quiet && (stream || raw) {
+		fmt.Println("-quiet cannot be combined with -stream or -raw")
+		return exitError
 	}
 
-	fmt.Println(finalConsideration)
-}
+	if jury && raw {
+		fmt.Println("-raw cannot be combined with -jury")
+		return exitError
+	}
 
-func getPRDiff(prURL string) (string, error) {
-	parts := strings.Split(prURL, "/")
-	if len(parts) < 7 {
-		return "", fmt.Errorf("invalid PR URL")
+	var grep *regexp.Regexp
+	if grepPattern != "" {
+		var err error
+		grep, err = regexp.Compile(grepPattern)
+		if err != nil {
+			fmt.Println("Invalid -grep-findings pattern:", err)
+			return exitError
+		}
 	}
 
-	org := parts[3]
-	repo := parts[4]
-	prNumber := parts[6]
+	fromFindings = fromFindings || failOnFlag != ""
+	if fromFindings && schemaFile != "" {
+		fmt.Println("-verdict-from-findings cannot be combined with -json-schema-file")
+		return exitError
+	}
 
-	cmd := exec.Command("gh", "pr", "diff", "-R", org+"/"+repo, prNumber)
-	output, err := cmd.Output()
-	if err != nil {
-		return "", fmt.Errorf("error running gh pr diff: %v", err)
+	if jury && schemaFile != "" {
+		fmt.Println("-jury cannot be combined with -json-schema-file")
+		return exitError
 	}
 
-	return string(output), nil
-}
+	if structured && (schemaFile != "" || jury || multiPass || stream || question != "" || chat || thread || suggest || mode == "security") {
+		fmt.Println("-structured cannot be combined with -json-schema-file, -jury, -multi-pass, -stream, -ask, -chat, -thread, -suggest or -mode security")
+		return exitError
+	}
 
-func generateFinalConsideration(prDiff string, apiKey string) (string, error) {
-	prompt := prDiff + "\nPlease provide a final consideration for this PR in Markdown format, focusing only on potential issues and ensuring the application's stability. Include an 'Approved: true/false' statement at the end for easy decision-making.Thank you!"
+	if recordDir != "" && replayDir != "" {
+		fmt.Println("-record cannot be combined with -replay")
+		return exitError
+	}
+	// Every call has to reach the provider to be recorded or replayed.
+	if recordDir != "" || replayDir != "" {
+		noCache = true
+	}
 
-	message := OpenAIRequestMessages{
-		Role:    "user",
-		Content: prompt,
+	if consensus && (jury || multiPass || chunked || stream || raw || schemaFile != "" || structured || question != "" || chat || thread) {
+		fmt.Println("-consensus cannot be combined with -jury, -multi-pass, -chunked, -stream, -raw, -json-schema-file, -structured, -ask, -chat or -thread")
+		return exitError
 	}
 
-	reqBody, err := json.Marshal(OpenAIRequest{
-		Model:       openAIModel,
-		Temperature: 0.5,
-		Messages:    []OpenAIRequestMessages{message},
+	var schema map[string]any
+	if schemaFile != "" {
+		var err error
+		schema, err = loadJSONSchema(schemaFile)
+		if err != nil {
+			fmt.Println("Error loading JSON schema:", err)
+			return exitError
+		}
+	}
+
+	tm := &timings{}
+	if showTimings {
+		defer tm.print(os.Stderr)
+	}
+
+	start := time.Now()
+	cfg, origins, err := loadConfigProfile(profileName(profile))
+	fromEnv, envErr := applyEnv(&cfg, origins)
+	if envErr != nil {
+		fmt.Println("Error in environment:", envErr)
+		return exitError
+	}
+	// Settings given only through the environment need no config file.
+	if fromEnv > 0 && errors.Is(err, errNoConfigFile) {
+		err = nil
+	}
+	if backend != "" {
+		cfg.Provider = backend
+		origins["provider"] = originFlag
+	}
+	if cfg.Provider == "" {
+		cfg.Provider = defaultProvider
+	}
+	if model != "" {
+		cfg.Model.Name = model
+		origins["model.name"] = originFlag
+	}
+	if cfg.Model.Name == "" {
+		cfg.Model.Name = defaultModels[cfg.Provider]
+	}
+	if lang != "" {
+		cfg.Language = lang
+		origins["language"] = originFlag
+	}
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "temperature":
+			cfg.Model.Temperature = temperature
+		case "max-tokens":
+			cfg.Model.MaxTokens = maxTokens
+		case "top-p":
+			cfg.Model.TopP = topP
+		case "normalize":
+			cfg.Normalize.Enabled = normalize
+			origins["normalize.enabled"] = originFlag
+			return
+		case "min-confidence":
+			cfg.Suppress.MinConfidence = minConfidence
+			origins["suppress.min_confidence"] = originFlag
+			return
+		default:
+			return
+		}
+		origins["model."+strings.ReplaceAll(f.Name, "-", "_")] = originFlag
 	})
+	if autoTemperature && origins.of("model.temperature") == originFlag {
+		fmt.Println("-temperature cannot be combined with -auto-temperature")
+		return exitError
+	}
+	if autoTemperature {
+		if err := cfg.Temperature.validate(); err != nil {
+			fmt.Println("Error in [temperature] config:", err)
+			return exitError
+		}
+	}
+	// An explicit temperature of 0 is kept, so only a missing one defaults.
+	if origins.of("model.temperature") == originDefault {
+		cfg.Model.Temperature = prgpt.DefaultTemperature
+	}
+
+	client, clientErr := newHTTPClient(cfg)
+	if clientErr != nil {
+		fmt.Println("Error configuring HTTP client:", clientErr)
+		return exitError
+	}
+	// github makes the GitHub-only calls: repository files, status checks,
+	// inline reviews and related PRs.
+	github := newGitHubForge(cfg, client)
+
+	// The repository's conventions override the user's, except where the
+	// flags or the environment say otherwise.
+	repoPR := prURL
+	if repoPR != "" {
+		repoPR = normalizePRURL(repoPR, cfg.GitHub.Host)
+	}
+	repoCfg, repoSource, repoErr := loadRepoConfig(ctx, github, repoPR)
+	if repoErr != nil && repoSource == "" {
+		fmt.Fprintln(os.Stderr, "Warning: ignoring repository config:", repoErr)
+	} else if repoErr != nil {
+		fmt.Println("Error in repository config:", repoErr)
+		return exitError
+	} else if repoSource != "" {
+		applyRepoConfig(&cfg, origins, repoCfg)
+		slog.Debug("applied repository config", "source", repoSource)
+	}
+	// A stronger model for security reviews wins over any general one but
+	// -model.
+	if mode == "security" && cfg.Security.Model != "" && origins.of("model.name") != originFlag {
+		cfg.Model.Name = cfg.Security.Model
+		origins["model.name"] = origins.of("security.model")
+	}
+	tm.track("config load", start)
+	logConfig(cfg, origins)
+
+	if explain {
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Warning:", err)
+		}
+		explainConfig(cfg, origins, fs)
+		return exitApproved
+	}
+	// Without a config file the defaults and the environment may be enough;
+	// a broken one is better fixed than half applied.
+	if errors.Is(err, errNoConfigFile) {
+		fmt.Fprintln(os.Stderr, "Warning:", err)
+	} else if err != nil {
+		fmt.Println("Error in config:", err)
+		return exitError
+	}
+
+	if prURL != "" {
+		prURL = normalizePRURL(prURL, cfg.GitHub.Host)
+		if _, _, _, err := parsePRURL(prURL); err != nil {
+			fmt.Println("Error:", err)
+			return exitError
+		}
+	}
 
+	if staged && base != "" {
+		fmt.Println("-staged cannot be combined with -base")
+		return exitError
+	}
+	local = local || staged || base != ""
+	if prURL == "" && mergeCommit == "" && !local && diffFile == "" {
+		fmt.Println("Usage: pr_review_cli -pr <PR_URL> | -local [-staged | -base <branch>] | -diff <file>")
+		return exitError
+	}
+
+	if diffFile != "" && (prURL != "" || mergeCommit != "" || local) {
+		fmt.Println("-diff cannot be combined with -pr, -merge-commit or -local")
+		return exitError
+	}
+
+	if diffFile == "-" && chat {
+		fmt.Println("-diff - cannot be combined with -chat, which reads its questions from stdin")
+		return exitError
+	}
+
+	if local && (prURL != "" || mergeCommit != "") {
+		fmt.Println("-local cannot be combined with -pr or -merge-commit")
+		return exitError
+	}
+
+	if prURL == "" && (templateFile != "" || statusCheck || post || inline || thread || newThread || sinceLast) {
+		fmt.Println("-pr-template, -status-check, -post, -inline, -thread, -reset-thread and -since-last-review need -pr")
+		return exitError
+	}
+
+	if prURL != "" && !isGitHubURL(prURL) && (statusCheck || inline || relatedPRs > 0) {
+		fmt.Println("-status-check, -inline and -related-prs are only supported for GitHub PRs")
+		return exitError
+	}
+
+	fg := forgeFor(prURL, cfg, client)
+
+	promptTmpl, err := selectPrompt(cfg, promptName)
+	if err != nil {
+		fmt.Println("Error in [prompt] config:", err)
+		return exitError
+	}
+	if persona != "" {
+		p, err := loadPersona(persona)
+		if err != nil {
+			fmt.Println("Error loading persona:", err)
+			return exitError
+		}
+		promptTmpl = p.over(promptTmpl)
+	}
+
+	switch mode {
+	case "review":
+	case "security":
+		if multiPass || schemaFile != "" {
+			fmt.Println("-mode security cannot be combined with -multi-pass or -json-schema-file")
+			return exitError
+		}
+	default:
+		fmt.Println("Unknown -mode:", mode)
+		return exitError
+	}
+
+	if suggest && (question != "" || multiPass || schemaFile != "") {
+		fmt.Println("-suggest cannot be combined with -ask, -multi-pass or -json-schema-file")
+		return exitError
+	}
+
+	if (post || inline) && question != "" {
+		fmt.Println("-post and -inline cannot be combined with -ask")
+		return exitError
+	}
+
+	if (thread || question != "") && (chunked || jury || schemaFile != "") {
+		fmt.Println("-thread and -ask cannot be combined with -chunked, -jury or -json-schema-file")
+		return exitError
+	}
+
+	// The annotations go to stdout, where they would break a JSON report.
+	if githubActions && (question != "" || outputFormat == "json" || outputFormat == "gitlab-codequality") {
+		fmt.Println("-github-actions cannot be combined with -ask, -output json or -output gitlab-codequality")
+		return exitError
+	}
+
+	if chat && (question != "" || quiet || chunked || jury || multiPass || schemaFile != "" || outputFormat != "markdown") {
+		fmt.Println("-chat cannot be combined with -ask, -quiet, -chunked, -jury, -multi-pass, -json-schema-file or -output")
+		return exitError
+	}
+
+	if multiPass && (jury || thread || question != "" || schemaFile != "") {
+		fmt.Println("-multi-pass cannot be combined with -jury, -thread, -ask or -json-schema-file")
+		return exitError
+	}
+
+	if newThread {
+		if err := resetThread(prURL); err != nil {
+			fmt.Println("Error resetting thread:", err)
+			return exitError
+		}
+	}
+
+	var history []prgpt.Message
+	if thread {
+		history, err = loadThread(prURL)
+		if err != nil {
+			fmt.Println("Error loading thread:", err)
+			return exitError
+		}
+	}
+
+	labels, err := newSeverityLabels(cfg.Severity.Mapping)
 	if err != nil {
-		return "", fmt.Errorf("error marshaling OpenAI request: %v", err)
+		fmt.Println("Error in [severity] config:", err)
+		return exitError
+	}
+
+	failOn := defaultFailOn
+	if failOnFlag != "" {
+		var ok bool
+		failOn, ok = labels.canonical(failOnFlag)
+		if !ok {
+			fmt.Println("Unknown -fail-on severity:", failOnFlag)
+			return exitError
+		}
+	} else if cfg.Verdict.FailOn != "" {
+		var ok bool
+		failOn, ok = labels.canonical(cfg.Verdict.FailOn)
+		if !ok {
+			fmt.Println("Error in [verdict] config: unknown severity", cfg.Verdict.FailOn)
+			return exitError
+		}
 	}
 
-	req, err := http.NewRequest("POST", openAICompletionURL, bytes.NewBuffer(reqBody))
+	suppress, err := newSuppressor(cfg.Suppress.Rules)
 	if err != nil {
-		return "", fmt.Errorf("error creating request to OpenAI API: %v", err)
+		fmt.Println("Error in [suppress] config:", err)
+		return exitError
+	}
+	if c := cfg.Suppress.MinConfidence; c < 0 || c > 1 {
+		fmt.Println("The minimum confidence must be between 0 and 1, not", c)
+		return exitError
+	}
+
+	// The head is checked before fetching the diff so that a force-push
+	// is noticed and the fresh diff is the one reviewed.
+	var headSHA, previousSHA string
+	var forcePushed bool
+	if sinceLast && prURL != "" {
+		headSHA, previousSHA, forcePushed = detectForcePush(ctx, fg, prURL)
+	}
+	// Only the new commits are reviewed, unless rewritten history makes
+	// the previous head meaningless.
+	sinceSHA := ""
+	if sinceLast {
+		switch {
+		case headSHA == "":
+			fmt.Println("Error fetching PR head for -since-last-review")
+			return exitError
+		case previousSHA == "":
+			fmt.Fprintln(os.Stderr, "No earlier review of this PR; reviewing all of it")
+		case previousSHA == headSHA:
+			fmt.Printf("No new commits since the last review at %.7s\n", headSHA)
+			return exitApproved
+		case forcePushed:
+			fmt.Fprintln(os.Stderr, "The PR was force-pushed since the last review; reviewing all of it")
+		default:
+			sinceSHA = previousSHA
+		}
+	}
+	if (statusCheck || inline) && headSHA == "" {
+		headSHA, err = fg.HeadSHA(ctx, prURL)
+		if err != nil {
+			fmt.Println("Error fetching PR head:", err)
+			return exitError
+		}
 	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+apiKey)
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	start = time.Now()
+	var prDiff string
+	if mergeCommit != "" {
+		prDiff, err = getMergeResolutionDiff(ctx, mergeCommit)
+	} else if diffFile != "" {
+		prDiff, err = prgpt.PatchFile{Path: diffFile}.Diff(ctx)
+	} else if local {
+		prDiff, err = prgpt.LocalDiff{Staged: staged, Base: base}.Diff(ctx)
+	} else if sinceSHA != "" {
+		prDiff, err = fg.DiffSince(ctx, prURL, sinceSHA, headSHA)
+	} else {
+		prDiff, err = fg.Diff(ctx, prURL)
+	}
+	tm.track("diff fetch", start)
 	if err != nil {
-		return "", fmt.Errorf("error making request to OpenAI API: %v", err)
+		fmt.Println("Error fetching diff:", err)
+		if errors.Is(err, prgpt.ErrDiffTooLarge) {
+			fmt.Println("Hint: raise [github] max_diff_bytes, or review the PR locally with -local -base and -files or -exclude")
+		}
+		return exitError
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	if patterns := append(cfg.Filters.Exclude, excludes...); len(patterns) > 0 {
+		files, excluded := excludeFiles(splitDiffFiles(prDiff), patterns)
+		if len(excluded) > 0 {
+			fmt.Fprintf(os.Stderr, "Excluded from the review: %s\n", strings.Join(excluded, ", "))
+			prDiff = joinDiffFiles(files)
+		}
+		if len(files) == 0 {
+			fmt.Println("Every changed file is excluded by [filters] or -exclude; nothing to review.")
+			return exitApproved
+		}
+	}
+
+	if patterns := splitPatterns(onlyFiles); len(patterns) > 0 {
+		files, excluded := includeFiles(splitDiffFiles(prDiff), patterns)
+		if len(files) == 0 {
+			fmt.Println("No changed file matches -files; nothing to review.")
+			return exitApproved
+		}
+		if len(excluded) > 0 {
+			fmt.Fprintf(os.Stderr, "Reviewing only %d of %d changed files (-files)\n", len(files), len(files)+len(excluded))
+			prDiff = joinDiffFiles(files)
+		}
+	}
+
+	prDiff, err = redactDiff(cfg, prDiff)
 	if err != nil {
-		return "", fmt.Errorf("error reading response from OpenAI API: %v", err)
+		fmt.Println(err)
+		return exitError
 	}
 
-	var openAIResp OpenAIReponse
-	if err := json.Unmarshal(body, &openAIResp); err != nil {
-		return "", fmt.Errorf("error unmarshaling OpenAI response: %v", err)
+	if cfg.Normalize.Enabled {
+		maxLineLength, maxGenerated := cfg.Normalize.MaxLineLength, cfg.Normalize.MaxGeneratedLines
+		if maxLineLength == 0 {
+			maxLineLength = defaultMaxLineLength
+		}
+		if maxGenerated == 0 {
+			maxGenerated = defaultMaxGeneratedLines
+		}
+		before := estimateTokens(prDiff)
+		files, stats := normalizeDiff(splitDiffFiles(prDiff), maxLineLength, maxGenerated)
+		if summary := stats.String(); summary != "" {
+			prDiff = joinDiffFiles(files)
+			fmt.Fprintf(os.Stderr, "Normalized the diff: %s; saved ~%d tokens\n", summary, before-estimateTokens(prDiff))
+		}
+		if len(files) == 0 {
+			fmt.Println("Only binary files changed; nothing to review.")
+			return exitApproved
+		}
 	}
 
-	if len(openAIResp.Choices) == 0 {
-		return "", fmt.Errorf("no response received from OpenAI API")
+	if maxFileDiffLines > 0 {
+		files := splitDiffFiles(prDiff)
+		if truncated := truncateFileDiffs(files, maxFileDiffLines); len(truncated) > 0 {
+			fmt.Fprintf(os.Stderr, "Truncated diffs longer than %d lines: %s\n", maxFileDiffLines, strings.Join(truncated, ", "))
+			prDiff = joinDiffFiles(files)
+		}
 	}
 
-	return openAIResp.Choices[0].Message.Content, nil
-}
+	var info prInfo
+	if prURL != "" && (templateFile != "" || prDescription || strings.Contains(promptTmpl.User, ".Title")) {
+		info, err = fg.Info(ctx, prURL)
+		// Only the template check cannot do without the description.
+		if err != nil && templateFile != "" {
+			fmt.Println("Error fetching PR description:", err)
+			return exitError
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Warning: reviewing without the PR description:", err)
+			prDescription = false
+		}
+	}
 
-func loadConfig() (result FileConfig, err error) {
-	currentUser, err := user.Current()
+	var templateReport string
+	if templateFile != "" {
+		template, err := os.ReadFile(templateFile)
+		if err != nil {
+			fmt.Println("Error reading PR template:", err)
+			return exitError
+		}
+		templateReport = formatTemplateReport(checkPRTemplate(string(template), info.Body, cfg.Template.Required))
+	}
+
+	start = time.Now()
+	repoContext := loadRepoContext(ctx, fg, prURL, cfg.Context.MaxTokens)
+	if relatedPRs > 0 && prURL != "" {
+		candidates, err := github.RecentMergedPRs(ctx, prURL)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Warning: skipping related PRs:", err)
+		} else if related := relatedPRContext(candidates, splitDiffFiles(prDiff), relatedPRs); related != "" {
+			repoContext = strings.TrimSpace(repoContext + "\n\n" + related)
+		}
+	}
+	instruction := reviewInstruction(labels)
+	if mode == "security" {
+		instruction = securityInstruction(labels)
+	}
+	var responseFormat *prgpt.ResponseFormat
+	if schema != nil {
+		instruction = structuredInstruction
+		responseFormat = &prgpt.ResponseFormat{
+			Type:       "json_schema",
+			JSONSchema: &prgpt.JSONSchema{Name: "review", Schema: schema},
+		}
+	} else if structured {
+		instruction = findingsSchemaInstruction(labels)
+		responseFormat = &prgpt.ResponseFormat{
+			Type:       "json_schema",
+			JSONSchema: &prgpt.JSONSchema{Name: "findings", Schema: findingsSchema(labels)},
+		}
+	}
+	if mergeCommit != "" {
+		instruction = mergeInstruction + " " + instruction
+	}
+	if sinceSHA != "" {
+		instruction = sinceLastInstruction + " " + instruction
+	}
+	var hint coverageHint
+	if coverage {
+		hint = computeCoverageHint(splitDiffFiles(prDiff), cfg.Coverage.MinRatio)
+		instruction = hint.prompt() + "\n" + instruction
+	}
+	if suggest {
+		instruction += " " + suggestInstruction
+	} else if inline {
+		instruction += " " + inlineInstruction
+	}
+	// The model is shown the numbered diff; the rest of the review works
+	// on prDiff.
+	promptDiff := prDiff
+	annotate := inline || suggest
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "line-numbers" {
+			annotate = lineNumbers
+		}
+	})
+	if annotate && question == "" {
+		promptDiff = annotateLineNumbers(prDiff)
+		instruction += " " + lineNumbersInstruction
+	}
+	if len(cfg.Checklist.Items) > 0 && schema == nil {
+		if multiPass {
+			fmt.Fprintln(os.Stderr, "Warning: [checklist] is not checked with -multi-pass")
+		}
+		instruction += "\n" + checklistInstruction(cfg.Checklist.Items)
+	}
+	enforceSections := len(cfg.Output.Sections) > 0 && schema == nil && !structured && question == ""
+	if enforceSections {
+		if multiPass || jury || consensus || chunked {
+			fmt.Fprintln(os.Stderr, "Warning: [output] sections are not enforced with -multi-pass, -jury, -consensus or -chunked")
+		}
+		instruction += "\n" + sectionsInstruction(cfg.Output.Sections)
+	}
+	if cfg.Suppress.MinConfidence > 0 && schema == nil && !structured {
+		instruction += "\n" + confidenceInstruction
+	}
+	if question != "" {
+		instruction = askInstruction + question
+	}
+	var description string
+	if prURL != "" && prDescription {
+		description = describePR(ctx, fg, prURL, info)
+	}
+	var repoName string
+	if org, repo, _, err := parsePRURL(prURL); prURL != "" && err == nil {
+		repoName = org + "/" + repo
+	}
+	prompts, err := newPromptBuilder(promptTmpl.User, promptData{Repo: repoName, Title: info.Title, Description: description})
+	if err != nil {
+		fmt.Println("Error in [prompt] config:", err)
+		return exitError
+	}
+	prompt, err := prompts.build(promptDiff, repoContext, instruction)
 	if err != nil {
-		return result, fmt.Errorf("Error getting current user")
+		fmt.Println("Error building prompt:", err)
+		return exitError
+	}
+	if len(history) > 0 {
+		if threadHasDiff(history, promptDiff) {
+			// The diff is already part of the conversation.
+			prompt = instruction
+		} else {
+			prompt = threadUpdatedNote + "\n\n" + prompt
+		}
+	}
+	tm.track("prompt build", start)
+
+	budget := promptBudget(contextWindowFor(cfg.Model.Name, cfg.Model.ContextWindow))
+	if consensus && estimateTokens(prompt) > budget {
+		fmt.Printf("The prompt (~%d tokens) exceeds the context window of %s; -consensus needs the whole diff in one prompt\n", estimateTokens(prompt), cfg.Model.Name)
+		return exitError
+	}
+	if chat && estimateTokens(prompt) > budget {
+		fmt.Printf("The prompt (~%d tokens) exceeds the context window of %s; -chat needs the whole diff in one prompt\n", estimateTokens(prompt), cfg.Model.Name)
+		return exitError
+	}
+	if !chunked && !thread && question == "" && estimateTokens(prompt) > budget {
+		fmt.Fprintf(os.Stderr, "The prompt (~%d tokens) exceeds the context window of %s; reviewing the diff in chunks\n", estimateTokens(prompt), cfg.Model.Name)
+		chunked = true
+		if enforceSections {
+			fmt.Fprintln(os.Stderr, "Warning: [output] sections are not enforced with -chunked")
+		}
+	}
+	// Whether the review is chunked is only known now that the prompt is
+	// built, and with it whether the cached file reviews may be reused.
+	if chunked && !sinceLast && prURL != "" {
+		headSHA, previousSHA, forcePushed = detectForcePush(ctx, fg, prURL)
 	}
 
-	file, err := os.Open(currentUser.HomeDir + CONFIG_FOLDER + FILENAME)
+	// Replayed reviews need no API key.
+	var provider prgpt.Provider = fixtureProvider{dir: replayDir, replay: true}
+	if replayDir == "" {
+		provider, err = newProvider(cfg.Provider, cfg, client)
+		if err != nil {
+			fmt.Println("Error configuring provider:", err)
+			return exitError
+		}
+	}
+	if recordDir != "" {
+		provider = fixtureProvider{provider: provider, dir: recordDir}
+	}
+
+	var members []ConsensusMember
+	var memberProviders []prgpt.Provider
+	if consensus {
+		newMember := func(name string) (prgpt.Provider, error) {
+			if replayDir != "" {
+				return fixtureProvider{dir: replayDir, replay: true}, nil
+			}
+			p, err := newProvider(name, cfg, client)
+			if err != nil || recordDir == "" {
+				return p, err
+			}
+			return fixtureProvider{provider: p, dir: recordDir}, nil
+		}
+		members, memberProviders, err = consensusMembers(cfg, newMember)
+		if err != nil {
+			fmt.Println("Error configuring provider:", err)
+			return exitError
+		}
+	}
+
+	r := &reviewer{ctx: ctx, provider: provider, providerName: cfg.Provider, model: cfg.Model.Name, temperature: cfg.Model.Temperature, maxTokens: cfg.Model.MaxTokens, topP: cfg.Model.TopP, history: history, system: withLanguage(promptTmpl.System, cfg.Language), prompts: prompts, timings: tm, maxPromptTokens: budget, maxCalls: maxAPICalls, pacer: &pacer{interval: pace, path: os.Getenv(paceFileEnv)}, concurrency: concurrency, security: mode == "security"}
+	if autoTemperature {
+		changed := countChangedLines(prDiff)
+		r.temperature = cfg.Temperature.temperatureFor(changed)
+		slog.Debug("auto-temperature", "changed_lines", changed, "temperature", r.temperature)
+	}
+	// Calls that failed halfway through a review were still paid for.
+	if cfg.Provider != "mock" && replayDir == "" {
+		defer func() {
+			price := modelPrice{Input: cfg.Budget.InputPerMillion, Output: cfg.Budget.OutputPerMillion}
+			if err := recordUsage(prURL, cfg.Provider, r.usage, price); err != nil {
+				fmt.Fprintln(os.Stderr, "Warning: could not record usage:", err)
+			}
+		}()
+	}
+
+	// A review is reused as long as nothing that went into it changed. The
+	// conversation of -thread is not part of the key, so it is never cached,
+	// and after a force-push the PR is reviewed afresh.
+	var reviews *reviewCache
+	var reviewKey, cachedReview string
+	var cacheHit bool
+	if !noCache && !thread && !forcePushed && cfg.Provider != "mock" {
+		var cacheErr error
+		reviews, cacheErr = openReviewCache("reviews")
+		if cacheErr != nil {
+			fmt.Fprintln(os.Stderr, "Warning: reviewing without cache:", cacheErr)
+		}
+		var juryModels, memberList string
+		if jury {
+			juryModels = fmt.Sprint(cfg.Jury.Models)
+		}
+		if consensus {
+			memberList = fmt.Sprint(members)
+		}
+		settings := fmt.Sprint(r.temperature, r.maxTokens, r.topP, schema, chunked, multiPass, juryModels, memberList)
+		reviewKey = cacheKey(cfg.Provider, r.model, r.system, prompt, settings)
+		cachedReview, cacheHit = reviews.get(reviewKey)
+	}
+
+	var estimate costEstimate
+	if !cacheHit {
+		var models []string
+		if jury {
+			for _, m := range cfg.Jury.Models {
+				models = append(models, m.Name)
+			}
+		}
+		for _, m := range members {
+			models = append(models, m.Model)
+		}
+		estimate, err = r.estimate(prompt, promptDiff, repoContext, chunked, models, cfg)
+		if err != nil {
+			fmt.Println("Error building prompt:", err)
+			return exitError
+		}
+		if multiPass {
+			estimate = estimate.times(len(reviewPasses))
+		}
+	}
+	if dryRun && cacheHit {
+		fmt.Println("Estimated: no API calls; the cached review would be reused (-no-cache to run it again)")
+	} else if dryRun {
+		fmt.Println("Estimated:", estimate)
+	}
+	if estimate.Priced && cfg.Budget.AbortUSD > 0 && estimate.Cost > cfg.Budget.AbortUSD {
+		fmt.Printf("Estimated cost $%.4f exceeds [budget] abort_usd $%.2f; not sending\n", estimate.Cost, cfg.Budget.AbortUSD)
+		return exitError
+	}
+	if cfg.Budget.MonthlyUSD > 0 && !cacheHit {
+		spent, err := monthSpend(time.Now())
+		if err != nil {
+			fmt.Println("Error checking [budget] monthly_usd:", err)
+			return exitError
+		}
+		if spent >= cfg.Budget.MonthlyUSD {
+			fmt.Printf("This month's spend of $%.2f reached [budget] monthly_usd $%.2f; not sending (see prgpt usage)\n", spent, cfg.Budget.MonthlyUSD)
+			return exitError
+		}
+		if spent+estimate.Cost > cfg.Budget.MonthlyUSD {
+			fmt.Printf("This month's spend of $%.2f and the estimated $%.4f of this review would exceed [budget] monthly_usd $%.2f; not sending (see prgpt usage)\n", spent, estimate.Cost, cfg.Budget.MonthlyUSD)
+			return exitError
+		}
+	}
+	if estimate.Priced && cfg.Budget.WarnUSD > 0 && estimate.Cost > cfg.Budget.WarnUSD {
+		fmt.Fprintf(os.Stderr, "Warning: estimated cost $%.4f exceeds [budget] warn_usd $%.2f\n", estimate.Cost, cfg.Budget.WarnUSD)
+	}
+	if dryRun {
+		return exitApproved
+	}
+
+	if stream {
+		r.stream = func(delta string) { fmt.Print(delta) }
+	}
+
+	var cache *reviewCache
+	if chunked && !forcePushed && !noCache {
+		var cacheErr error
+		cache, cacheErr = openReviewCache("chunks")
+		if cacheErr != nil {
+			fmt.Fprintln(os.Stderr, "Warning: reviewing without cache:", cacheErr)
+		}
+	}
+
+	review := func() (string, error) {
+		if multiPass {
+			pass := func(instruction string) (string, error) {
+				if chunked {
+					return r.reviewChunked(splitDiffFiles(promptDiff), repoContext, instruction, nil, cache)
+				}
+				prompt, err := prompts.build(promptDiff, repoContext, instruction)
+				if err != nil {
+					return "", err
+				}
+				return r.complete(prompt, nil)
+			}
+			return r.reviewMultiPass(pass, labels, failOn)
+		}
+		if chunked {
+			return r.reviewChunked(splitDiffFiles(promptDiff), repoContext, instruction, responseFormat, cache)
+		}
+		return r.complete(prompt, responseFormat)
+	}
+
+	var finalConsideration string
+	switch {
+	case cacheHit:
+		finalConsideration = cachedReview
+		fmt.Fprintln(os.Stderr, "Reused the cached review of this diff; -no-cache to run it again")
+		if stream {
+			fmt.Print(finalConsideration)
+		}
+	case jury:
+		finalConsideration, err = r.reviewJury(cfg.Jury.Models, review)
+	case consensus:
+		var opinions []consensusOpinion
+		opinions, err = r.reviewConsensus(members, memberProviders, prompt, labels)
+		for _, o := range opinions {
+			if o.member.Provider == "mock" || replayDir != "" {
+				continue
+			}
+			price := modelPrice{Input: cfg.Budget.InputPerMillion, Output: cfg.Budget.OutputPerMillion}
+			if err := recordUsage(prURL, o.member.Provider, o.usage, price); err != nil {
+				fmt.Fprintln(os.Stderr, "Warning: could not record usage:", err)
+			}
+		}
+		if err == nil {
+			finalConsideration = formatConsensus(opinions, labels)
+		}
+	default:
+		finalConsideration, err = review()
+		if err == nil && enforceSections && !multiPass && !chunked {
+			finalConsideration, err = r.ensureSections(prompt, finalConsideration, cfg.Output.Sections)
+		}
+	}
+	if err == nil && headSHA != "" {
+		if err := savePRState(prURL, prState{HeadSHA: headSHA}); err != nil {
+			fmt.Fprintln(os.Stderr, "Warning: could not record PR head:", err)
+		}
+	}
 	if err != nil {
-		return result, fmt.Errorf("Error opening TOML file: %v", err)
+		fmt.Println("Error generating final consideration:", err)
+		if hint := apiErrorHint(err); hint != "" {
+			fmt.Println("Hint:", hint)
+		}
+		return exitError
+	}
+	if !cacheHit {
+		if err := reviews.put(reviewKey, finalConsideration); err != nil {
+			fmt.Fprintln(os.Stderr, "Warning: could not cache review:", err)
+		}
+	}
+	rawResponse := finalConsideration
+	if stream {
+		fmt.Println()
+	}
+
+	history = append(history,
+		prgpt.Message{Role: "user", Content: prompt},
+		prgpt.Message{Role: "assistant", Content: finalConsideration})
+	if thread {
+		if err := saveThread(prURL, history, cfg.Thread.MaxMessages); err != nil {
+			fmt.Fprintln(os.Stderr, "Warning: could not save thread:", err)
+		}
+	}
+
+	if question != "" {
+		if !stream {
+			if outputFormat == "text" && !raw {
+				finalConsideration = markdownToText(finalConsideration)
+			} else if outputFormat == "markdown" && !raw && useColor(plain) {
+				finalConsideration = renderMarkdown(finalConsideration, labels)
+			}
+			fmt.Println(finalConsideration)
+		}
+		return exitApproved
+	}
+
+	var structuredFindings []Finding
+	if structured {
+		finalConsideration, structuredFindings, err = renderStructuredReview(finalConsideration, labels)
+		if err != nil {
+			fmt.Println("Error rendering structured review:", err)
+			return exitError
+		}
+	}
+
+	var approved bool
+	var findings []Finding
+	if schema != nil {
+		approved, _ = structuredVerdict(finalConsideration)
+		finalConsideration, err = renderStructured(schema, finalConsideration)
+		if err != nil {
+			fmt.Println("Error rendering structured review:", err)
+			return exitError
+		}
+	} else {
+		approved, _ = prgpt.ParseVerdict(finalConsideration)
+		parsed := structuredFindings
+		if !structured {
+			parsed = parseFindings(finalConsideration, labels)
+		}
+		finalConsideration, findings = suppress.apply(finalConsideration, parsed)
+		if cfg.Suppress.MinConfidence > 0 {
+			finalConsideration, findings = dropUnconfident(finalConsideration, findings, cfg.Suppress.MinConfidence)
+		}
+		// The model's verdict counts the findings left out, so when one of
+		// them would fail the review it gives way to the verdict derived
+		// from the findings that remain.
+		switch {
+		case blockingFindings(findings, failOn) < blockingFindings(parsed, failOn):
+			approved = verdictFromFindings(findings, failOn)
+			finalConsideration += fmt.Sprintf("\n\n_Verdict derived from the remaining findings, failing on %s or worse._\n\nApproved: %t", labels.label(failOn), approved)
+		case fromFindings && !multiPass:
+			approved = verdictFromFindings(findings, failOn)
+			finalConsideration += fmt.Sprintf("\n\n_Verdict derived from the findings, failing on %s or worse._\n\nApproved: %t", labels.label(failOn), approved)
+		}
+	}
+
+	if !cfg.History.Disabled {
+		entry := historyEntry{Time: time.Now().UTC(), PR: prURL, Commit: headSHA, Provider: cfg.Provider, Model: r.model, Prompt: promptVersion(promptName, persona, promptTmpl), Tokens: r.tokens, Approved: approved, Review: finalConsideration}
+		switch {
+		case mergeCommit != "":
+			entry.PR, entry.Commit = "merge", mergeCommit
+		case diffFile != "":
+			entry.PR = "patch " + diffFile
+		case prURL == "":
+			entry.PR = "local"
+		case entry.Commit == "":
+			entry.Commit, _ = fg.HeadSHA(ctx, prURL)
+		}
+		if id, err := recordReview(entry); err != nil {
+			fmt.Fprintln(os.Stderr, "Warning: could not record review in history:", err)
+		} else {
+			slog.Debug("recorded review in history", "id", id)
+		}
+	}
+
+	if statusFile != "" {
+		status := runStatus{Approved: approved, Blockers: countSeverity(findings, "blocker"), Tokens: r.tokens}
+		if err := writeStatusFile(statusFile, status); err != nil {
+			fmt.Println("Error writing status file:", err)
+			return exitError
+		}
+	}
+
+	if statusCheck {
+		if err := github.SetCommitStatus(ctx, prURL, headSHA, commitStatusFor(approved, findings, labels)); err != nil {
+			fmt.Println("Error posting status check:", err)
+			return exitError
+		}
+	}
+
+	if outputDir != "" {
+		dir, err := writeArtifacts(outputDir, artifactName(prURL, mergeCommit), finalConsideration, rawResponse, findings)
+		if err != nil {
+			fmt.Println("Error writing artifacts:", err)
+			return exitError
+		}
+		fmt.Fprintln(os.Stderr, "Wrote review artifacts to", dir)
+	}
+
+	// Filtering happens after the verdict was acted on: it only changes
+	// what is displayed.
+	if grep != nil && schema == nil {
+		finalConsideration = grepFindings(finalConsideration, findings, grep)
+	}
+
+	if coverage {
+		finalConsideration = "_" + hint.String() + "_\n\n" + finalConsideration
+	}
+
+	if sinceSHA != "" {
+		finalConsideration = fmt.Sprintf("_Reviewed only the commits pushed since the last review (%.7s..%.7s)._\n\n", sinceSHA, headSHA) + finalConsideration
 	}
-	defer file.Close()
 
-	// Unmarshal the TOML content into a struct
-	if err := toml.NewDecoder(file).Decode(&result); err != nil {
-		return result, fmt.Errorf("Error parsing TOML file: %v", err)
+	if forcePushed {
+		finalConsideration = fmt.Sprintf("_The PR was force-pushed since the last review (%.7s -> %.7s); cached reviews were bypassed and the diff re-fetched._\n\n", previousSHA, headSHA) + finalConsideration
+	}
+
+	if templateReport != "" {
+		finalConsideration = templateReport + "\n" + finalConsideration
+	}
+
+	if githubActions {
+		if err := reportToActions(finalConsideration, findings, approved, labels); err != nil {
+			fmt.Println("Error reporting to GitHub Actions:", err)
+			return exitError
+		}
+	}
+
+	if post {
+		if vp, ok := fg.(verdictPoster); ok {
+			err = vp.PostReview(ctx, prURL, finalConsideration, approved)
+		} else {
+			err = fg.PostComment(ctx, prURL, finalConsideration)
+		}
+		if err != nil {
+			fmt.Println("Error posting review:", err)
+			return exitError
+		}
+		fmt.Fprintln(os.Stderr, "Posted the review to", prURL)
+	}
+
+	if inline {
+		body, comments := inlineComments(finalConsideration, findings, splitDiffFiles(prDiff), labels)
+		if err := github.SubmitReview(ctx, prURL, headSHA, body, comments); err != nil {
+			fmt.Println("Error submitting review:", err)
+			return exitError
+		}
+		fmt.Fprintf(os.Stderr, "Submitted a review with %d inline comment(s) to %s\n", len(comments), prURL)
+	}
+
+	if prURL != "" && (cfg.Notify.SlackWebhook != "" || cfg.Notify.TeamsWebhook != "") {
+		notice := reviewNotice{PR: prURL, Approved: approved, Findings: findings, Summary: reviewSummary(finalConsideration, findings)}
+		if err := notifyReview(ctx, cfg, notice, labels); err != nil {
+			fmt.Fprintln(os.Stderr, "Warning: could not send notification:", err)
+		}
+	}
+
+	start = time.Now()
+	switch {
+	case quiet:
+		fmt.Printf("Approved: %t\n", approved)
+	case stream:
+		// The review was printed as it arrived; the notes and verdict
+		// added to it since follow it.
+		if tail := streamTail(rawResponse, finalConsideration); tail != "" {
+			fmt.Printf("\n%s\n\nApproved: %t\n", tail, approved)
+		}
+	case raw:
+		fmt.Print(rawResponse)
+	case outputFormat == "gitlab-codequality":
+		report, err := codeQualityReport(findings)
+		if err != nil {
+			fmt.Println("Error building code quality report:", err)
+			return exitError
+		}
+		fmt.Println(string(report))
+	case outputFormat == "json":
+		report, err := jsonOutput(finalConsideration, findings, approved, r.tokens, r.model)
+		if err != nil {
+			fmt.Println("Error building JSON output:", err)
+			return exitError
+		}
+		fmt.Println(string(report))
+	case outputFormat == "text":
+		fmt.Println(markdownToText(finalConsideration))
+	case useColor(plain):
+		fmt.Println(renderMarkdown(finalConsideration, labels))
+	default:
+		fmt.Println(finalConsideration)
+	}
+	tm.track("output rendering", start)
+
+	if chat {
+		history, err = r.chat(os.Stdin, os.Stdout, history)
+		if thread {
+			if err := saveThread(prURL, history, cfg.Thread.MaxMessages); err != nil {
+				fmt.Fprintln(os.Stderr, "Warning: could not save thread:", err)
+			}
+		}
+		if err != nil {
+			fmt.Println("Error in chat:", err)
+			if hint := apiErrorHint(err); hint != "" {
+				fmt.Println("Hint:", hint)
+			}
+			return exitError
+		}
+	}
+
+	if !approved {
+		return exitRejected
+	}
+	return exitApproved
+}
+
+func reviewInstruction(labels severityLabels) string {
+	return prgpt.ReviewIntro + " " + labels.findingsInstruction() + " " + prgpt.VerdictInstruction
+}
+
+// withLanguage asks, in the system message so that every call of a review
+// follows it, for the review in lang. What prgpt parses stays in English.
+func withLanguage(system, lang string) string {
+	if lang == "" {
+		return system
+	}
+	instruction := fmt.Sprintf("Write your answer in the language %s. Keep the severity tags, file paths, code and the final \"Approved: true\" or \"Approved: false\" line exactly as specified, in English.", lang)
+	return strings.TrimSpace(system + "\n\n" + instruction)
+}
+
+func buildPrompt(prDiff string, repoContext string, description string, instruction string) string {
+	prompt := ""
+	if repoContext != "" {
+		prompt = "Background on this repository:\n" + repoContext + "\n\n"
+	}
+	if description != "" {
+		prompt += "What the author says about this PR:\n" + description + "\n\n"
 	}
 
-	return result, nil
+	return prompt + prDiff + "\n" + instruction
 }
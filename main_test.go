@@ -0,0 +1,105 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// TestMain runs prgpt in place of the tests when PRGPT_TEST_MAIN is set,
+// so the reviews a batch or a range starts as processes of the running
+// executable work under test.
+func TestMain(m *testing.M) {
+	if os.Getenv("PRGPT_TEST_MAIN") != "" {
+		main()
+	}
+	os.Exit(m.Run())
+}
+
+// useConfig points prgpt at a config file with the given content, and at
+// empty data, cache and history directories.
+func useConfig(t *testing.T, config string) {
+	t.Helper()
+	home := t.TempDir()
+	dir := filepath.Join(home, CONFIG_FOLDER)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, FILENAME), []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("PRGPT_PROFILE", "")
+}
+
+// runPrgpt runs prgpt with args, returning what it printed to stdout and
+// its exit code.
+func runPrgpt(t *testing.T, args ...string) (string, int) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		output <- string(data)
+	}()
+	code := run(args)
+	w.Close()
+	return <-output, code
+}
+
+// stderrOf runs f, returning what it printed to stderr.
+func stderrOf(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr := os.Stderr
+	os.Stderr = w
+	defer func() { os.Stderr = stderr }()
+
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		output <- string(data)
+	}()
+	f()
+	w.Close()
+	return <-output
+}
+
+func TestRawOutput(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		approve bool
+		args    []string
+	}{
+		{"markdown", "## Final consideration\n**Bold** and `code`, trailing space \n\n```go\nx := 1\n```", true, nil},
+		{"findings", "- [blocker] a.go:1 - nil map write\n- [nit] a.go:2 - typo", false, nil},
+		{"suppressed and filtered", "- [nit] gen/a.go:1 - generated\n- [major] b.go:2 - goroutine leak", false, []string{"-grep-findings", "leak", "-output", "text"}},
+		{"unicode", "Revisão: tudo certo ✓", true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useConfig(t, "provider = \"mock\"\n[[suppress.rules]]\npath = \"gen/**\"\n")
+			t.Setenv("PRGPT_MOCK_TEXT", tt.text)
+			t.Setenv("PRGPT_MOCK_APPROVE", strconv.FormatBool(tt.approve))
+			output, _ := runPrgpt(t, append([]string{"-diff", "testdata/replay/change.patch", "-raw"}, tt.args...)...)
+			if want := tt.text + "\n\nApproved: " + strconv.FormatBool(tt.approve); output != want {
+				t.Errorf("output = %q, want the response %q", output, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	mdHeader     = regexp.MustCompile(`^\s{0,3}#{1,6}\s+(.*?)\s*#*\s*$`)
+	mdBullet     = regexp.MustCompile(`^(\s*)[*+-]\s+`)
+	mdRule       = regexp.MustCompile(`^\s{0,3}([-*_])(\s*[-*_]){2,}\s*$`)
+	mdQuote      = regexp.MustCompile(`^\s*>\s?`)
+	mdImage      = regexp.MustCompile(`!\[([^\]]*)\]\(([^)]*)\)`)
+	mdLink       = regexp.MustCompile(`\[([^\]]+)\]\(([^)]*)\)`)
+	mdStrong     = regexp.MustCompile(`\*\*(.+?)\*\*|__(.+?)__`)
+	mdEmphasis   = regexp.MustCompile(`\*([^*\s](?:[^*]*[^*\s])?)\*`)
+	mdUnderscore = regexp.MustCompile(`(^|[^\w])_([^_\s](?:[^_]*[^_\s])?)_([^\w]|$)`)
+	mdCode       = regexp.MustCompile("`([^`]+)`")
+)
+
+// markdownToText flattens the Markdown the model returns into plain text:
+// headers become uppercase lines, bullets become dashes, code fences are
+// dropped (keeping their content) and inline formatting is removed.
+func markdownToText(md string) string {
+	var out []string
+	inFence := false
+
+	for _, line := range strings.Split(md, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			out = append(out, line)
+			continue
+		}
+
+		if mdRule.MatchString(line) {
+			out = append(out, "")
+			continue
+		}
+		if m := mdHeader.FindStringSubmatch(line); m != nil {
+			out = append(out, strings.ToUpper(stripInlineMarkdown(m[1])))
+			continue
+		}
+
+		line = mdQuote.ReplaceAllString(line, "  ")
+		line = mdBullet.ReplaceAllString(line, "$1- ")
+		out = append(out, stripInlineMarkdown(line))
+	}
+
+	return strings.Join(out, "\n")
+}
+
+func stripInlineMarkdown(s string) string {
+	s = mdImage.ReplaceAllString(s, "$1 ($2)")
+	s = mdLink.ReplaceAllString(s, "$1 ($2)")
+	s = mdCode.ReplaceAllString(s, "$1")
+	s = mdStrong.ReplaceAllString(s, "$1$2")
+	s = mdEmphasis.ReplaceAllString(s, "$1")
+	s = mdUnderscore.ReplaceAllString(s, "$1$2$3")
+	return s
+}
@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestMarkdownToText(t *testing.T) {
+	tests := []struct {
+		name string
+		md   string
+		want string
+	}{
+		{"header", "## Final consideration", "FINAL CONSIDERATION"},
+		{"header with closing hashes", "### Risks ###", "RISKS"},
+		{"bullets", "* one\n+ two\n  - nested", "- one\n- two\n  - nested"},
+		{"code fence", "```go\nx := 1\n```", "x := 1"},
+		{"inline formatting", "**Bold**, *italic*, _under_ and `code`", "Bold, italic, under and code"},
+		{"link", "see [the docs](https://example.com)", "see the docs (https://example.com)"},
+		{"quote", "> quoted", "  quoted"},
+		{"rule", "---", ""},
+		{"snake_case kept", "call read_file_fast now", "call read_file_fast now"},
+		{"verdict kept", "Approved: true", "Approved: true"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := markdownToText(tt.md); got != tt.want {
+				t.Errorf("markdownToText(%q) = %q, want %q", tt.md, got, tt.want)
+			}
+		})
+	}
+}
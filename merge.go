@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/loadfms/prgpt/pkg/prgpt"
+)
+
+const mergeInstruction = "The diff above is the conflict resolution of a merge commit, shown as a combined diff with one marker column per parent. Focus only on whether the conflicts were resolved correctly: changes lost from either side, code duplicated from both sides, or logic broken by combining them."
+
+// getMergeResolutionDiff returns the part of a merge commit that resolved
+// conflicts: the hunks where the merge result differs from every parent.
+func getMergeResolutionDiff(ctx context.Context, sha string) (string, error) {
+	output, err := prgpt.Command(ctx, "git", "rev-list", "--parents", "-n", "1", sha).Output()
+	if err != nil {
+		return "", fmt.Errorf("error resolving commit %s: %v", sha, err)
+	}
+	if len(strings.Fields(string(output))) < 3 {
+		return "", fmt.Errorf("%s is not a merge commit", sha)
+	}
+
+	output, err = prgpt.Command(ctx, "git", "show", "--cc", "--format=", sha).Output()
+	if err != nil {
+		return "", fmt.Errorf("error running git show --cc: %v", err)
+	}
+
+	resolution := resolutionHunks(string(output))
+	if strings.TrimSpace(resolution) == "" {
+		return "", fmt.Errorf("merge commit %s has no conflict resolution to review", sha)
+	}
+	return resolution, nil
+}
+
+// resolutionHunks keeps the hunks of a combined diff that contain a line
+// changed against all parents, i.e. a line written while resolving the
+// conflict rather than taken from one side. Files left without such hunks
+// are dropped.
+func resolutionHunks(combined string) string {
+	var out strings.Builder
+	for _, file := range splitDiffFiles(combined) {
+		var header strings.Builder
+		var hunks []string
+		var hunk strings.Builder
+		resolved := false
+		parents := 0
+
+		flush := func() {
+			if hunk.Len() > 0 && resolved {
+				hunks = append(hunks, hunk.String())
+			}
+			hunk.Reset()
+			resolved = false
+		}
+
+		for _, line := range strings.SplitAfter(file.Text, "\n") {
+			if strings.HasPrefix(line, "@@@") {
+				flush()
+				parents = strings.Index(line[1:], " ") // "@@@" has one "@" per parent plus one
+				hunk.WriteString(line)
+				continue
+			}
+			if parents == 0 {
+				header.WriteString(line)
+				continue
+			}
+			hunk.WriteString(line)
+			if len(line) >= parents {
+				marks := line[:parents]
+				if strings.Trim(marks, "+") == "" || strings.Trim(marks, "-") == "" {
+					resolved = true
+				}
+			}
+		}
+		flush()
+
+		if len(hunks) > 0 {
+			out.WriteString(header.String())
+			out.WriteString(strings.Join(hunks, ""))
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestResolutionHunks(t *testing.T) {
+	const resolved = "@@@ -1,3 -1,3 +1,3 @@@\n  one\n- two-main\n -two-side\n++two-both\n  three\n"
+	const takenFromSides = "@@@ -10,2 -10,2 +10,3 @@@\n  ten\n+ eleven-side\n +eleven-main\n"
+	tests := []struct {
+		name     string
+		combined string
+		want     string
+	}{
+		{
+			name:     "resolved hunk",
+			combined: "diff --cc a.txt\nindex 1,2..3\n--- a/a.txt\n+++ b/a.txt\n" + resolved,
+			want:     "diff --cc a.txt\nindex 1,2..3\n--- a/a.txt\n+++ b/a.txt\n" + resolved,
+		},
+		{
+			name:     "hunk taken from the sides is dropped",
+			combined: "diff --cc a.txt\n--- a/a.txt\n+++ b/a.txt\n" + resolved + takenFromSides,
+			want:     "diff --cc a.txt\n--- a/a.txt\n+++ b/a.txt\n" + resolved,
+		},
+		{
+			name:     "file without a resolution is dropped",
+			combined: "diff --cc b.txt\n--- a/b.txt\n+++ b/b.txt\n" + takenFromSides + "diff --cc a.txt\n--- a/a.txt\n+++ b/a.txt\n" + resolved,
+			want:     "diff --cc a.txt\n--- a/a.txt\n+++ b/a.txt\n" + resolved,
+		},
+		{
+			name:     "line removed against both parents",
+			combined: "diff --cc a.txt\n--- a/a.txt\n+++ b/a.txt\n@@@ -1,2 -1,2 +1,1 @@@\n  one\n--two\n",
+			want:     "diff --cc a.txt\n--- a/a.txt\n+++ b/a.txt\n@@@ -1,2 -1,2 +1,1 @@@\n  one\n--two\n",
+		},
+		{
+			name:     "octopus merge",
+			combined: "diff --cc a.txt\n--- a/a.txt\n+++ b/a.txt\n@@@@ -1,1 -1,1 -1,1 +1,1 @@@@\n- x\n - y\n  -z\n+++w\n" + "@@@@ -9,1 -9,1 -9,1 +9,2 @@@@\n   nine\n++ ten\n",
+			want:     "diff --cc a.txt\n--- a/a.txt\n+++ b/a.txt\n@@@@ -1,1 -1,1 -1,1 +1,1 @@@@\n- x\n - y\n  -z\n+++w\n",
+		},
+		{"no files", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resolutionHunks(tt.combined); got != tt.want {
+				t.Errorf("resolutionHunks =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+// gitRepo creates a repository in a temporary directory and changes into
+// it, returning a function that runs git there.
+func gitRepo(t *testing.T) func(args ...string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	chdir(t, t.TempDir())
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	for _, v := range []string{"GIT_AUTHOR_NAME", "GIT_COMMITTER_NAME"} {
+		t.Setenv(v, "prgpt")
+	}
+	for _, v := range []string{"GIT_AUTHOR_EMAIL", "GIT_COMMITTER_EMAIL"} {
+		t.Setenv(v, "prgpt@example.com")
+	}
+	git := func(args ...string) string {
+		t.Helper()
+		output, err := exec.Command("git", args...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, output)
+		}
+		return strings.TrimSpace(string(output))
+	}
+	git("init", "-q", "-b", "main")
+	return git
+}
+
+func writeFile(t *testing.T, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetMergeResolutionDiff(t *testing.T) {
+	tests := []struct {
+		name       string
+		resolution string
+		want       []string
+		wantErr    string
+	}{
+		{"conflict resolved by hand", "one\ntwo-both\nthree\n", []string{"diff --cc a.txt", "++two-both"}, ""},
+		{"conflict resolved with one side", "one\ntwo-main\nthree\n", nil, "has no conflict resolution to review"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			git := gitRepo(t)
+			writeFile(t, "a.txt", "one\ntwo\nthree\n")
+			writeFile(t, "b.txt", "x\n")
+			git("add", ".")
+			git("commit", "-q", "-m", "base")
+
+			git("checkout", "-q", "-b", "side")
+			writeFile(t, "a.txt", "one\ntwo-side\nthree\n")
+			writeFile(t, "c.txt", "side only\n")
+			git("add", ".")
+			git("commit", "-q", "-m", "side")
+
+			git("checkout", "-q", "main")
+			writeFile(t, "a.txt", "one\ntwo-main\nthree\n")
+			writeFile(t, "b.txt", "y\n")
+			git("commit", "-q", "-am", "main")
+
+			// The merge stops at the conflict in a.txt.
+			if err := exec.Command("git", "merge", "-q", "side").Run(); err == nil {
+				t.Fatal("merge did not conflict")
+			}
+			writeFile(t, "a.txt", tt.resolution)
+			git("add", ".")
+			git("commit", "-q", "--no-edit")
+			sha := git("rev-parse", "HEAD")
+
+			diff, err := getMergeResolutionDiff(context.Background(), sha)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range tt.want {
+				if !strings.Contains(diff, s) {
+					t.Errorf("diff lacks %q:\n%s", s, diff)
+				}
+			}
+			// The sides' own changes are not part of the resolution.
+			for _, s := range []string{"b.txt", "c.txt", "side only"} {
+				if strings.Contains(diff, s) {
+					t.Errorf("diff contains %q from a side:\n%s", s, diff)
+				}
+			}
+		})
+	}
+
+	t.Run("not a merge commit", func(t *testing.T) {
+		git := gitRepo(t)
+		writeFile(t, "a.txt", "one\n")
+		git("add", ".")
+		git("commit", "-q", "-m", "base")
+		if _, err := getMergeResolutionDiff(context.Background(), git("rev-parse", "HEAD")); err == nil || !strings.Contains(err.Error(), "is not a merge commit") {
+			t.Errorf("err = %v, want a not a merge commit error", err)
+		}
+	})
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/loadfms/prgpt/pkg/prgpt"
+)
+
+const (
+	mockApproveText = "## Final consideration\nThis is a canned review from the mock backend; no model was called.\n\n## Findings\n- [nit] mock.go:1 - canned finding from the mock backend"
+	mockRejectText  = "## Final consideration\nThis is a canned review from the mock backend; no model was called.\n\n## Findings\n- [blocker] mock.go:1 - canned blocking finding from the mock backend"
+)
+
+// mockProvider returns the same canned review for any prompt, so the whole
+// pipeline can be exercised offline and without an API key.
+type mockProvider struct {
+	approve bool
+	text    string
+}
+
+func (p *mockProvider) Complete(ctx context.Context, req prgpt.CompletionRequest) (prgpt.Completion, error) {
+	text := p.text
+	if text == "" {
+		text = mockRejectText
+		if p.approve {
+			text = mockApproveText
+		}
+	}
+
+	content := fmt.Sprintf("%s\n\nApproved: %t", text, p.approve)
+	if req.ResponseFormat != nil {
+		// The findings of the canned text are given as data, as -structured
+		// asks for.
+		parsed := parseFindings(text, nil)
+		findings := []map[string]any{}
+		for _, f := range parsed {
+			confidence := 1.0
+			if f.Confidence != nil {
+				confidence = *f.Confidence
+			}
+			findings = append(findings, map[string]any{"file": f.File, "line": f.Line, "severity": f.Severity, "rationale": f.Message, "confidence": confidence})
+		}
+		summary, _, _ := strings.Cut(removeFindings(text, parsed), "## Findings")
+		data, err := json.Marshal(map[string]any{"approved": p.approve, "summary": strings.TrimSpace(summary), "findings": findings})
+		if err != nil {
+			return prgpt.Completion{}, err
+		}
+		content = string(data)
+	}
+
+	if req.OnDelta != nil {
+		req.OnDelta(content)
+	}
+	in, out := estimateTokens(req.Prompt), estimateTokens(content)
+	return prgpt.Completion{Content: content, Tokens: in + out, InputTokens: in, OutputTokens: out}, nil
+}
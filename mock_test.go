@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMockProvider(t *testing.T) {
+	configs := []struct {
+		name    string
+		config  string
+		code    int
+		summary string
+		finding string
+	}{
+		{"canned approval", "approve = true\n", exitApproved, "This is a canned review from the mock backend", "canned finding from the mock backend"},
+		{"canned rejection", "approve = false\n", exitRejected, "This is a canned review from the mock backend", "canned blocking finding from the mock backend"},
+		{"configured approval", "approve = true\ntext = \"Ship it.\"\n", exitApproved, "Ship it.", ""},
+		{"configured rejection", "approve = false\ntext = \"Needs tests.\\n\\n- [major] store.go:3 - no test for Put\"\n", exitRejected, "Needs tests.", "no test for Put"},
+	}
+	formats := []struct {
+		format string
+		check  func(t *testing.T, output string, approved bool, summary, finding string)
+	}{
+		{"markdown", func(t *testing.T, output string, approved bool, summary, finding string) {
+			if !strings.Contains(output, summary) || !strings.Contains(output, finding) {
+				t.Errorf("output lacks %q or %q:\n%s", summary, finding, output)
+			}
+		}},
+		{"text", func(t *testing.T, output string, approved bool, summary, finding string) {
+			if !strings.Contains(output, summary) || !strings.Contains(output, finding) || strings.Contains(output, "## ") {
+				t.Errorf("output is not the plain text of %q and %q:\n%s", summary, finding, output)
+			}
+		}},
+		{"json", func(t *testing.T, output string, approved bool, summary, finding string) {
+			var report jsonReport
+			if err := json.Unmarshal([]byte(output), &report); err != nil {
+				t.Fatalf("output is not JSON: %v\n%s", err, output)
+			}
+			if report.Approved != approved || !strings.Contains(report.Summary, summary) {
+				t.Errorf("report = %+v, want approved %t and a summary with %q", report, approved, summary)
+			}
+			if finding != "" && (len(report.Issues) != 1 || report.Issues[0].Message != finding) {
+				t.Errorf("issues = %+v, want %q", report.Issues, finding)
+			}
+		}},
+		{"gitlab-codequality", func(t *testing.T, output string, approved bool, summary, finding string) {
+			var issues []codeQualityIssue
+			if err := json.Unmarshal([]byte(output), &issues); err != nil {
+				t.Fatalf("output is not a report: %v\n%s", err, output)
+			}
+			var descriptions []string
+			for _, issue := range issues {
+				descriptions = append(descriptions, issue.Description)
+			}
+			if strings.Join(descriptions, "\n") != finding {
+				t.Errorf("issues = %q, want %q", descriptions, finding)
+			}
+		}},
+	}
+	for _, c := range configs {
+		for _, f := range formats {
+			t.Run(fmt.Sprintf("%s/%s", c.name, f.format), func(t *testing.T) {
+				useConfig(t, "provider = \"mock\"\n[mock]\n"+c.config)
+				output, code := runPrgpt(t, "-diff", "testdata/replay/change.patch", "-output", f.format)
+				if code != c.code {
+					t.Fatalf("exit code = %d, want %d; output:\n%s", code, c.code, output)
+				}
+				f.check(t, output, c.code == exitApproved, c.summary, c.finding)
+			})
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// newHTTPClient builds the client used for API calls, going through
+// [network] proxy_url or the proxy of the environment, and loading a custom
+// CA pool and a client certificate for mutual TLS when the config asks for
+// them. Rate-limited and transiently failing calls are retried, and every
+// attempt is logged.
+func newHTTPClient(cfg FileConfig) (*http.Client, error) {
+	transport, err := newTransport(cfg.Network)
+	if err != nil {
+		return nil, err
+	}
+	logging := &loggingTransport{next: transport}
+	for name := range cfg.OpenAI.Headers {
+		logging.redact = append(logging.redact, name)
+	}
+	return &http.Client{Transport: newRetryTransport(logging, cfg.Network.MaxAttempts)}, nil
+}
+
+// newWebhookClient is the client of the chat webhooks. Their URLs hold
+// their secret, so their calls go through the proxy and TLS settings of
+// [network] but are not logged.
+func newWebhookClient(cfg FileConfig) (*http.Client, error) {
+	transport, err := newTransport(cfg.Network)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+func newTransport(network networkConfig) (*http.Transport, error) {
+	proxy, err := proxyFunc(network.ProxyURL)
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxy
+	if network.CACert == "" && network.ClientCert == "" && network.ClientKey == "" {
+		return transport, nil
+	}
+
+	tlsConfig := &tls.Config{}
+
+	if network.CACert != "" {
+		pem, err := os.ReadFile(network.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("error reading CA certificate: %v", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no valid certificates found in %s", network.CACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if network.ClientCert != "" || network.ClientKey != "" {
+		if network.ClientCert == "" || network.ClientKey == "" {
+			return nil, fmt.Errorf("both [network] client_cert and client_key must be set for mutual TLS")
+		}
+		cert, err := tls.LoadX509KeyPair(network.ClientCert, network.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("error loading client certificate/key pair: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
+
+// proxyFunc picks the proxy of each request: proxyURL, an http, https or
+// socks5 URL, for every host but those NO_PROXY names, or when it is empty
+// the one HTTPS_PROXY, HTTP_PROXY and NO_PROXY give.
+func proxyFunc(proxyURL string) (func(*http.Request) (*url.URL, error), error) {
+	if proxyURL == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+	// The URL may hold credentials, so it is left out of errors.
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid [network] proxy_url")
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("invalid [network] proxy_url: the scheme must be http, https or socks5, not %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid [network] proxy_url: no host")
+	}
+
+	// The loopback interface and the hosts of NO_PROXY go direct, as they
+	// do with the proxy of the environment.
+	proxy := (&httpproxy.Config{
+		HTTPProxy:  proxyURL,
+		HTTPSProxy: proxyURL,
+		NoProxy:    httpproxy.FromEnvironment().NoProxy,
+	}).ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxy(req.URL)
+	}, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeClientCert writes a self-signed client certificate and its key,
+// returning their paths.
+func writeClientCert(t *testing.T) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "prgpt test client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile, keyFile = filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestNewTransportClientCert(t *testing.T) {
+	certFile, keyFile := writeClientCert(t)
+	tests := []struct {
+		name    string
+		network networkConfig
+		wantErr bool
+	}{
+		{"cert and key", networkConfig{ClientCert: certFile, ClientKey: keyFile}, false},
+		{"cert without key", networkConfig{ClientCert: certFile}, true},
+		{"key without cert", networkConfig{ClientKey: keyFile}, true},
+		{"key as cert", networkConfig{ClientCert: keyFile, ClientKey: keyFile}, true},
+		{"missing files", networkConfig{ClientCert: certFile + ".missing", ClientKey: keyFile}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport, err := newTransport(tt.network)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("newTransport succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if transport.TLSClientConfig == nil || len(transport.TLSClientConfig.Certificates) != 1 {
+				t.Errorf("TLSClientConfig = %+v, want the client certificate", transport.TLSClientConfig)
+			}
+		})
+	}
+}
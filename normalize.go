@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	defaultMaxLineLength      = 500
+	defaultMaxGeneratedLines  = 50
+	generatedMarker           = "DO NOT EDIT"
+	truncatedLineMarker       = " [line truncated]"
+	collapsedGeneratedMarkerf = "[%d lines of generated code collapsed]\n"
+)
+
+// generatedFiles are the usual paths of generated files, which rarely carry
+// a generated-code header.
+var generatedFiles = []string{"*.pb.go", "*_generated.*", "*.gen.*", "*.min.js", "*.min.css", "*.lock", "package-lock.json", "go.sum", "*.snap"}
+
+// normalizeStats counts what normalizeDiff removed.
+type normalizeStats struct {
+	Binary     []string
+	Generated  int
+	Whitespace int
+	LongLines  int
+}
+
+func (s normalizeStats) String() string {
+	var parts []string
+	if len(s.Binary) > 0 {
+		parts = append(parts, fmt.Sprintf("dropped %d binary file(s) (%s)", len(s.Binary), strings.Join(s.Binary, ", ")))
+	}
+	if s.Generated > 0 {
+		parts = append(parts, fmt.Sprintf("collapsed %d generated hunk(s)", s.Generated))
+	}
+	if s.Whitespace > 0 {
+		parts = append(parts, fmt.Sprintf("ignored %d whitespace-only line change(s)", s.Whitespace))
+	}
+	if s.LongLines > 0 {
+		parts = append(parts, fmt.Sprintf("truncated %d long line(s)", s.LongLines))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// normalizeDiff strips the noise from the file diffs: binary files are
+// dropped, hunks of generated code longer than maxGenerated lines are
+// collapsed, changes of only whitespace become context as with git diff -w,
+// and lines longer than maxLineLength are cut.
+func normalizeDiff(files []fileDiff, maxLineLength, maxGenerated int) ([]fileDiff, normalizeStats) {
+	var stats normalizeStats
+	var kept []fileDiff
+	for _, f := range files {
+		if isBinaryDiff(f.Text) {
+			stats.Binary = append(stats.Binary, f.Path)
+			continue
+		}
+		header, hunks := splitHunks(f)
+		generated := matchesAny(generatedFiles, f.Path)
+		var b strings.Builder
+		b.WriteString(header)
+		for _, hunk := range hunks {
+			if !generated && strings.Contains(hunk, generatedMarker) {
+				generated = true
+			}
+			if generated && maxGenerated > 0 {
+				if lines := strings.Count(hunk, "\n") - 1; lines > maxGenerated {
+					head, _, _ := strings.Cut(hunk, "\n")
+					fmt.Fprintf(&b, "%s\n"+collapsedGeneratedMarkerf, head, lines)
+					stats.Generated++
+					continue
+				}
+			}
+			hunk, n := ignoreWhitespaceChanges(hunk)
+			stats.Whitespace += n
+			if !hasChanges(hunk) {
+				continue
+			}
+			hunk, n = truncateLongLines(hunk, maxLineLength)
+			stats.LongLines += n
+			b.WriteString(hunk)
+		}
+		f.Text = b.String()
+		kept = append(kept, f)
+	}
+	return kept, stats
+}
+
+func isBinaryDiff(text string) bool {
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(line, "@@") {
+			return false
+		}
+		if strings.HasPrefix(line, "GIT binary patch") || strings.HasPrefix(line, "Binary files ") {
+			return true
+		}
+	}
+	return false
+}
+
+// ignoreWhitespaceChanges turns every block of removed lines followed by as
+// many added lines that differ from them only in whitespace into context, so
+// the hunk's line counts stay right. It returns how many lines it turned.
+func ignoreWhitespaceChanges(hunk string) (string, int) {
+	lines := strings.SplitAfter(hunk, "\n")
+	changed := 0
+	for i := 0; i < len(lines); {
+		if !strings.HasPrefix(lines[i], "-") {
+			i++
+			continue
+		}
+		removed := i
+		for i < len(lines) && strings.HasPrefix(lines[i], "-") {
+			i++
+		}
+		added := i
+		for i < len(lines) && strings.HasPrefix(lines[i], "+") {
+			i++
+		}
+		if added-removed != i-added || !sameIgnoringSpace(lines[removed:added], lines[added:i]) {
+			continue
+		}
+		for j := added; j < i; j++ {
+			lines[j] = " " + lines[j][1:]
+		}
+		copy(lines[removed:], lines[added:])
+		lines = lines[:len(lines)-(added-removed)]
+		changed += i - added
+		i -= added - removed
+	}
+	return strings.Join(lines, ""), changed
+}
+
+func sameIgnoringSpace(removed, added []string) bool {
+	for i := range removed {
+		if withoutSpace(removed[i][1:]) != withoutSpace(added[i][1:]) {
+			return false
+		}
+	}
+	return true
+}
+
+func withoutSpace(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+func hasChanges(hunk string) bool {
+	for _, line := range strings.Split(hunk, "\n")[1:] {
+		if strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-") {
+			return true
+		}
+	}
+	return false
+}
+
+// truncateLongLines cuts the lines of the hunk longer than maxLength bytes,
+// at a character boundary.
+func truncateLongLines(hunk string, maxLength int) (string, int) {
+	if maxLength <= 0 {
+		return hunk, 0
+	}
+	lines := strings.SplitAfter(hunk, "\n")
+	n := 0
+	for i, line := range lines {
+		text, newline := strings.CutSuffix(line, "\n")
+		if len(text) <= maxLength {
+			continue
+		}
+		cut := maxLength
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		lines[i] = text[:cut] + truncatedLineMarker
+		if newline {
+			lines[i] += "\n"
+		}
+		n++
+	}
+	return strings.Join(lines, ""), n
+}
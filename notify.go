@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxNotifySummary bounds how much of the review a notification quotes.
+const maxNotifySummary = 500
+
+// reviewNotice is what a chat notification says about a review.
+type reviewNotice struct {
+	PR       string
+	Approved bool
+	Findings []Finding
+	Summary  string
+}
+
+// outcome is the verdict and the count of findings.
+func (n reviewNotice) outcome(labels severityLabels) string {
+	verdict := "not approved"
+	if n.Approved {
+		verdict = "approved"
+	}
+	return fmt.Sprintf("%s, %d finding(s), %d %s", verdict, len(n.Findings), countSeverity(n.Findings, "blocker"), labels.label("blocker"))
+}
+
+// prName is the short org/repo#1 form of a PR URL.
+func prName(prURL string) string {
+	org, repo, number, err := parsePRURL(prURL)
+	if err != nil {
+		return prURL
+	}
+	return org + "/" + repo + "#" + number
+}
+
+// summary is the start of the review's prose, without its headings.
+func (n reviewNotice) summary() string {
+	var lines []string
+	for _, line := range strings.Split(n.Summary, "\n") {
+		if !mdHeader.MatchString(line) {
+			lines = append(lines, line)
+		}
+	}
+	summary := strings.TrimSpace(strings.Join(lines, "\n"))
+	if len(summary) > maxNotifySummary {
+		// Cut at the start of a character, not within one.
+		end := maxNotifySummary
+		for end > 0 && !utf8.RuneStart(summary[end]) {
+			end--
+		}
+		summary = strings.TrimSpace(summary[:end]) + "…"
+	}
+	return summary
+}
+
+// slackEscaper escapes the characters Slack's mrkdwn gives a meaning to.
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func slackPayload(n reviewNotice, labels severityLabels) map[string]any {
+	icon := ":x:"
+	if n.Approved {
+		icon = ":white_check_mark:"
+	}
+	text := fmt.Sprintf("%s prgpt reviewed <%s|%s>: %s", icon, n.PR, prName(n.PR), n.outcome(labels))
+	if summary := n.summary(); summary != "" {
+		text += "\n>" + strings.ReplaceAll(slackEscaper.Replace(summary), "\n", "\n>")
+	}
+	return map[string]any{"text": text}
+}
+
+func teamsPayload(n reviewNotice, labels severityLabels) map[string]any {
+	color := "E01E5A"
+	if n.Approved {
+		color = "2EB67D"
+	}
+	headline := prName(n.PR) + ": " + n.outcome(labels)
+	return map[string]any{
+		"@type":      "MessageCard",
+		"@context":   "https://schema.org/extensions",
+		"summary":    headline,
+		"themeColor": color,
+		"title":      "prgpt reviewed " + headline,
+		"text":       n.summary(),
+		"potentialAction": []any{map[string]any{
+			"@type":   "OpenUri",
+			"name":    "Open PR",
+			"targets": []any{map[string]string{"os": "default", "uri": n.PR}},
+		}},
+	}
+}
+
+func postWebhook(ctx context.Context, client *http.Client, url string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("error marshaling notification: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("error creating notification request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		// The error would quote the URL, and with it the secret.
+		var urlErr *neturl.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("error sending notification: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("notification webhook returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// notifyReview posts the notice to the Slack and Teams webhooks configured
+// in [notify], returning the first error.
+func notifyReview(ctx context.Context, cfg FileConfig, n reviewNotice, labels severityLabels) error {
+	if cfg.Notify.OnlyRejected && n.Approved {
+		return nil
+	}
+	client, err := newWebhookClient(cfg)
+	if err != nil {
+		return err
+	}
+	if cfg.Notify.SlackWebhook != "" {
+		if err := postWebhook(ctx, client, cfg.Notify.SlackWebhook, slackPayload(n, labels)); err != nil {
+			return fmt.Errorf("Slack: %v", err)
+		}
+	}
+	if cfg.Notify.TeamsWebhook != "" {
+		if err := postWebhook(ctx, client, cfg.Notify.TeamsWebhook, teamsPayload(n, labels)); err != nil {
+			return fmt.Errorf("Teams: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// paceFileEnv names the file through which the reviews of a batch, a range
+// or prgpt serve, which each run in their own process, share one pacer, so
+// that -pace spaces the calls of all of them rather than of each.
+const paceFileEnv = "PRGPT_PACE_FILE"
+
+// pacer enforces a minimum interval between the starts of API calls. Each
+// caller reserves the next free slot, so it also spaces concurrent calls.
+type pacer struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+	// path, when set, is the file keeping the next free slot in place of
+	// next, locked like the rate limit buckets.
+	path string
+}
+
+// wait blocks until the caller's slot, or until ctx ends.
+func (p *pacer) wait(ctx context.Context) error {
+	if p == nil {
+		return nil
+	}
+
+	var start time.Time
+	err := p.update(ctx, func(next time.Time) time.Time {
+		start = next
+		if now := time.Now(); start.Before(now) {
+			start = now
+		}
+		return start.Add(p.interval)
+	})
+	if err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(time.Until(start))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// holdOff delays the next call until at least d from now.
+func (p *pacer) holdOff(d time.Duration) {
+	if p == nil {
+		return
+	}
+
+	err := p.update(context.Background(), func(next time.Time) time.Time {
+		if until := time.Now().Add(d); until.After(next) {
+			return until
+		}
+		return next
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Warning:", err)
+	}
+}
+
+// update replaces the next free slot with what f makes of it.
+func (p *pacer) update(ctx context.Context, f func(next time.Time) time.Time) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.path == "" {
+		p.next = f(p.next)
+		return nil
+	}
+
+	unlock, err := lockFile(ctx, p.path+".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// A slot that cannot be read is free.
+	var next time.Time
+	if data, err := os.ReadFile(p.path); err == nil {
+		next.UnmarshalText(bytes.TrimSpace(data))
+	}
+	data, err := f(next).MarshalText()
+	if err != nil {
+		return fmt.Errorf("error marshaling pace state: %v", err)
+	}
+	if err := os.WriteFile(p.path, data, 0o600); err != nil {
+		return fmt.Errorf("error writing pace state: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPacerWait(t *testing.T) {
+	const interval = 100 * time.Millisecond
+	tests := []struct {
+		name       string
+		concurrent bool
+		holdOff    time.Duration
+		first      time.Duration
+	}{
+		{"sequential calls", false, 0, 0},
+		{"concurrent calls", true, 0, 0},
+		{"after a rate-limit reset", false, 250 * time.Millisecond, 250 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &pacer{interval: interval}
+			p.holdOff(tt.holdOff)
+			begin := time.Now()
+
+			var mu sync.Mutex
+			var starts []time.Duration
+			call := func() {
+				if err := p.wait(context.Background()); err != nil {
+					t.Error(err)
+				}
+				mu.Lock()
+				starts = append(starts, time.Since(begin))
+				mu.Unlock()
+			}
+			var wg sync.WaitGroup
+			for i := 0; i < 3; i++ {
+				if !tt.concurrent {
+					call()
+					continue
+				}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					call()
+				}()
+			}
+			wg.Wait()
+
+			sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+			for i, start := range starts {
+				if want := tt.first + time.Duration(i)*interval; start < want {
+					t.Errorf("call %d started after %v, want at least %v", i+1, start, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPacerWaitCancelled(t *testing.T) {
+	p := &pacer{interval: time.Hour}
+	if err := p.wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := p.wait(ctx); err != context.Canceled {
+		t.Errorf("wait = %v, want %v", err, context.Canceled)
+	}
+
+	var none *pacer
+	if err := none.wait(ctx); err != nil {
+		t.Errorf("nil pacer: wait = %v, want nil", err)
+	}
+}
+
+func TestPaceAcrossReviews(t *testing.T) {
+	const pace = 500 * time.Millisecond
+	diff, err := os.ReadFile("testdata/replay/change.patch")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var calls []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v1/chat/completions":
+			mu.Lock()
+			calls = append(calls, time.Now())
+			mu.Unlock()
+			fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"Fine.\n\nApproved: true"}}],"usage":{"prompt_tokens":10,"completion_tokens":5}}`)
+		case strings.HasPrefix(r.URL.Path, "/api/v3/repos/o/r/pulls/") && strings.Contains(r.Header.Get("Accept"), "diff"):
+			w.Write(diff)
+		case strings.HasPrefix(r.URL.Path, "/api/v3/repos/o/r/pulls/"):
+			fmt.Fprint(w, `{"title":"t","head":{"sha":"1111111"}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name string
+		args func(t *testing.T) []string
+	}{
+		{"batch", func(t *testing.T) []string {
+			return []string{"-pr", server.URL + "/o/r/pull/1", "-pr", server.URL + "/o/r/pull/2", "-workers", "2", "-pace", pace.String(), "-no-cache"}
+		}},
+		{"range", func(t *testing.T) []string {
+			git := gitRepo(t)
+			writeFile(t, "a.txt", "one\n")
+			git("add", ".")
+			git("commit", "-q", "-m", "base")
+			for _, line := range []string{"two", "three"} {
+				writeFile(t, "a.txt", "one\n"+line+"\n")
+				git("commit", "-q", "-am", line)
+			}
+			return []string{"range", "HEAD~2..HEAD", "-workers", "2", "--", "-pace", pace.String(), "-no-cache"}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useConfig(t, fmt.Sprintf("provider = \"openai\"\n[openai]\nbase_url = %q\n", server.URL+"/v1"))
+			t.Setenv("GITHUB_TOKEN", "t")
+			// The reviews run as processes of the test binary.
+			t.Setenv("PRGPT_TEST_MAIN", "1")
+			mu.Lock()
+			calls = nil
+			mu.Unlock()
+			args := tt.args(t)
+
+			output, code := runPrgpt(t, args...)
+			if code != exitApproved {
+				t.Fatalf("exit code = %d; output:\n%s", code, output)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if len(calls) != 2 {
+				t.Fatalf("got %d API calls, want 2", len(calls))
+			}
+			sort.Slice(calls, func(i, j int) bool { return calls[i].Before(calls[j]) })
+			// Allow for the first request taking longer to arrive.
+			if gap := calls[1].Sub(calls[0]); gap < pace-100*time.Millisecond {
+				t.Errorf("API calls %v apart, want about %v", gap, pace)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// reviewPass is one focused look at the diff in a -multi-pass review.
+type reviewPass struct {
+	Name  string
+	Focus string
+}
+
+var reviewPasses = []reviewPass{
+	{"correctness", "Review this PR only for correctness: bugs, logic errors, unhandled edge cases and error handling. Ignore security and style."},
+	{"security", "Review this PR only for security: injection, unsafe input handling, leaked secrets, authentication and authorization mistakes. Ignore other kinds of issues."},
+	{"style", "Review this PR only for style: naming, readability, dead code and consistency with the surrounding code. Ignore bugs and security."},
+}
+
+// passFinding is a finding of a -multi-pass review and the passes that
+// reported it.
+type passFinding struct {
+	Finding
+	passes []string
+}
+
+// reviewMultiPass runs one review per pass, each with its own focus, with
+// review, which reviews the diff as a whole or in chunks, and merges their
+// findings grouped by severity. The verdict is derived from the merged
+// findings, failing on failOn or worse.
+func (r *reviewer) reviewMultiPass(review func(instruction string) (string, error), labels severityLabels, failOn string) (string, error) {
+	// The passes are not shown as they are; only the merged review is.
+	stream := r.stream
+	r.stream = nil
+	defer func() { r.stream = stream }()
+
+	var findings []passFinding
+	for _, pass := range reviewPasses {
+		content, err := review(pass.Focus + " " + labels.findingsInstruction() + " Reply with the findings only; do not give a verdict.")
+		if err != nil {
+			return "", fmt.Errorf("error in the %s pass: %w", pass.Name, err)
+		}
+		for _, f := range parseFindings(content, labels) {
+			findings = append(findings, passFinding{Finding: f, passes: []string{pass.Name}})
+		}
+	}
+
+	return formatMultiPass(mergeFindings(findings), labels, failOn), nil
+}
+
+// mergeFindings merges the findings reported at the same place with the
+// same message by several passes, keeping the most severe, and orders them
+// from the most to the least severe. Each message ends with the passes that
+// reported it.
+func mergeFindings(findings []passFinding) []Finding {
+	sort.SliceStable(findings, func(i, j int) bool {
+		return severityRank[findings[i].Severity] > severityRank[findings[j].Severity]
+	})
+
+	index := map[string]int{}
+	var merged []passFinding
+	for _, f := range findings {
+		key := fmt.Sprintf("%s:%d:%s", f.File, f.Line, strings.ToLower(f.Message))
+		if i, ok := index[key]; ok {
+			merged[i].passes = append(merged[i].passes, f.passes...)
+			continue
+		}
+		index[key] = len(merged)
+		merged = append(merged, f)
+	}
+
+	result := make([]Finding, len(merged))
+	for i, f := range merged {
+		result[i] = f.Finding
+		result[i].Message += " (" + strings.Join(f.passes, ", ") + ")"
+	}
+	return result
+}
+
+func formatMultiPass(findings []Finding, labels severityLabels, failOn string) string {
+	var names []string
+	for _, pass := range reviewPasses {
+		names = append(names, pass.Name)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "## Findings\n\n_Reviewed in %d passes: %s._\n", len(reviewPasses), strings.Join(names, ", "))
+	if len(findings) == 0 {
+		b.WriteString("\nNo issues found.\n")
+	}
+	for _, severity := range canonicalSeverities {
+		if countSeverity(findings, severity) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n### %s\n", labels.label(severity))
+		for _, f := range findings {
+			if f.Severity != severity {
+				continue
+			}
+			location := f.File
+			if f.Line > 0 {
+				location = fmt.Sprintf("%s:%d", f.File, f.Line)
+			}
+			if location != "" {
+				location += " - "
+			}
+			fmt.Fprintf(&b, "- [%s] %s%s\n", labels.label(severity), location, f.Message)
+		}
+	}
+
+	approved := verdictFromFindings(findings, failOn)
+	fmt.Fprintf(&b, "\n_Verdict derived from the findings, failing on %s or worse._\n\nApproved: %t\n", labels.label(failOn), approved)
+	return b.String()
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeFindings(t *testing.T) {
+	found := func(severity, file string, line int, message, pass string) passFinding {
+		return passFinding{Finding: Finding{Severity: severity, File: file, Line: line, Message: message}, passes: []string{pass}}
+	}
+	tests := []struct {
+		name     string
+		findings []passFinding
+		want     []string
+	}{
+		{
+			name: "same finding in two passes",
+			findings: []passFinding{
+				found("minor", "a.go", 3, "Nil map write", "correctness"),
+				found("major", "a.go", 3, "nil map write", "security"),
+			},
+			want: []string{"nil map write (security, correctness)"},
+		},
+		{
+			name: "messages differing after a parenthesis",
+			findings: []passFinding{
+				found("major", "a.go", 3, "unchecked error (os.Open)", "correctness"),
+				found("major", "a.go", 3, "unchecked error (io.Copy)", "correctness"),
+			},
+			want: []string{"unchecked error (os.Open) (correctness)", "unchecked error (io.Copy) (correctness)"},
+		},
+		{
+			name: "ordered by severity",
+			findings: []passFinding{
+				found("nit", "b.go", 1, "typo", "style"),
+				found("blocker", "b.go", 9, "SQL injection", "security"),
+			},
+			want: []string{"SQL injection (security)", "typo (style)"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, f := range mergeFindings(tt.findings) {
+				got = append(got, f.Message)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// builtinPersonas are the reviewer personas shipped with prgpt, one
+// PromptTemplate per file.
+//
+//go:embed personas/*.toml
+var builtinPersonas embed.FS
+
+// personaDir holds the user's own personas, which take precedence over the
+// built-in ones of the same name.
+func personaDir() (string, error) {
+	path, err := configPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(path), "prompts"), nil
+}
+
+// loadPersona reads the persona named name from the user's prompts
+// directory or from the built-in ones.
+func loadPersona(name string) (PromptTemplate, error) {
+	var persona PromptTemplate
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return persona, fmt.Errorf("invalid persona name %q", name)
+	}
+
+	data, source, err := readPersona(name + ".toml")
+	if errors.Is(err, fs.ErrNotExist) {
+		return persona, fmt.Errorf("no persona named %q; available: %s", name, strings.Join(personaNames(), ", "))
+	}
+	if err != nil {
+		return persona, fmt.Errorf("error reading persona %s: %v", name, err)
+	}
+
+	decoder := toml.NewDecoder(strings.NewReader(string(data)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&persona); err != nil {
+		return persona, fmt.Errorf("error parsing persona %s: %v", source, err)
+	}
+	if persona.System == "" && persona.User == "" {
+		return persona, fmt.Errorf("persona %s sets neither system nor user", source)
+	}
+	return persona, nil
+}
+
+func readPersona(file string) (data []byte, source string, err error) {
+	if dir, err := personaDir(); err == nil {
+		path := filepath.Join(dir, file)
+		data, err := os.ReadFile(path)
+		if !errors.Is(err, fs.ErrNotExist) {
+			return data, path, err
+		}
+	}
+	data, err = builtinPersonas.ReadFile("personas/" + file)
+	return data, "built-in " + file, err
+}
+
+// personaNames lists the built-in personas and the user's own.
+func personaNames() []string {
+	seen := map[string]bool{}
+	add := func(entries []fs.DirEntry) {
+		for _, e := range entries {
+			if name, ok := strings.CutSuffix(e.Name(), ".toml"); ok && !e.IsDir() {
+				seen[name] = true
+			}
+		}
+	}
+	entries, _ := builtinPersonas.ReadDir("personas")
+	add(entries)
+	if dir, err := personaDir(); err == nil {
+		entries, _ := os.ReadDir(dir)
+		add(entries)
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// over applies the persona to the prompt template t: its system prompt
+// replaces t's, and so does its user template when it has one.
+func (p PromptTemplate) over(t PromptTemplate) PromptTemplate {
+	if p.System != "" {
+		t.System = p.System
+	}
+	if p.User != "" {
+		t.User = p.User
+	}
+	return t
+}
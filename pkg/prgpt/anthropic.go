@@ -0,0 +1,203 @@
+package prgpt
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	anthropicMessagesURL = "https://api.anthropic.com/v1/messages"
+	anthropicVersion     = "2023-06-01"
+	AnthropicModel       = "claude-3-5-sonnet-latest"
+
+	// defaultAnthropicMaxTokens caps the response length when [model]
+	// max_tokens is unset, as the Messages API requires a cap.
+	defaultAnthropicMaxTokens = 4096
+)
+
+type AnthropicRequest struct {
+	Model       string    `json:"model"`
+	MaxTokens   int       `json:"max_tokens"`
+	System      string    `json:"system,omitempty"`
+	Messages    []Message `json:"messages"`
+	Temperature float64   `json:"temperature"`
+	TopP        float64   `json:"top_p,omitempty"`
+	Stream      bool      `json:"stream,omitempty"`
+}
+
+type AnthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+type AnthropicResponse struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	Usage AnthropicUsage `json:"usage"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type anthropicStreamEvent struct {
+	Type    string `json:"type"`
+	Message struct {
+		Usage AnthropicUsage `json:"usage"`
+	} `json:"message"`
+	Delta struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"delta"`
+	Usage AnthropicUsage `json:"usage"`
+}
+
+// Anthropic calls Anthropic's Messages API.
+type Anthropic struct {
+	Client *http.Client
+	APIKey string
+}
+
+func (p *Anthropic) Complete(ctx context.Context, r CompletionRequest) (Completion, error) {
+	maxTokens := r.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = defaultAnthropicMaxTokens
+	}
+
+	// The Messages API has no response_format; ask for the JSON in the
+	// prompt instead and let checkSchema validate the answer.
+	prompt := r.Prompt
+	if r.ResponseFormat != nil && r.ResponseFormat.JSONSchema != nil {
+		schema, err := json.Marshal(r.ResponseFormat.JSONSchema.Schema)
+		if err != nil {
+			return Completion{}, fmt.Errorf("error marshaling JSON schema: %v", err)
+		}
+		prompt += "\n\nRespond only with a JSON object matching this JSON schema, without any other text:\n" + string(schema)
+	}
+
+	// System messages go in their own field rather than in the conversation.
+	var system []string
+	if r.System != "" {
+		system = append(system, r.System)
+	}
+	var messages []Message
+	for _, m := range r.History {
+		if m.Role == "system" {
+			system = append(system, m.Content)
+			continue
+		}
+		messages = append(messages, m)
+	}
+	messages = append(messages, Message{Role: "user", Content: prompt})
+
+	reqBody, err := json.Marshal(AnthropicRequest{
+		Model:       r.Model,
+		MaxTokens:   maxTokens,
+		System:      strings.Join(system, "\n\n"),
+		Messages:    messages,
+		Temperature: r.Temperature,
+		TopP:        r.TopP,
+		Stream:      r.OnDelta != nil,
+	})
+	if err != nil {
+		return Completion{}, fmt.Errorf("error marshaling Anthropic request: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", anthropicMessagesURL, bytes.NewBuffer(reqBody))
+	if err != nil {
+		return Completion{}, fmt.Errorf("error creating request to Anthropic API: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", p.APIKey)
+	req.Header.Set("anthropic-version", anthropicVersion)
+
+	resp, err := httpClient(p.Client).Do(req)
+	if err != nil {
+		return Completion{}, fmt.Errorf("error making request to Anthropic API: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if r.OnDelta != nil && resp.StatusCode == http.StatusOK {
+		return readAnthropicStream(resp, r.OnDelta)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Completion{}, fmt.Errorf("error reading response from Anthropic API: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Completion{}, anthropicError(resp.Status, body)
+	}
+
+	var anthropicResp AnthropicResponse
+	if err := json.Unmarshal(body, &anthropicResp); err != nil {
+		return Completion{}, fmt.Errorf("error unmarshaling Anthropic response: %v", err)
+	}
+
+	var content strings.Builder
+	for _, block := range anthropicResp.Content {
+		if block.Type == "text" {
+			content.WriteString(block.Text)
+		}
+	}
+	if content.Len() == 0 {
+		return Completion{}, fmt.Errorf("no response received from Anthropic API")
+	}
+
+	return Completion{
+		Content:        content.String(),
+		Tokens:         anthropicResp.Usage.InputTokens + anthropicResp.Usage.OutputTokens,
+		InputTokens:    anthropicResp.Usage.InputTokens,
+		OutputTokens:   anthropicResp.Usage.OutputTokens,
+		RateLimitReset: rateLimitReset(resp.Header),
+	}, nil
+}
+
+// readAnthropicStream consumes a server-sent events response of the Messages
+// API, passing every text delta to onDelta as it arrives.
+func readAnthropicStream(resp *http.Response, onDelta func(string)) (Completion, error) {
+	var content strings.Builder
+	var usage AnthropicUsage
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+
+		var event anthropicStreamEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return Completion{}, fmt.Errorf("error unmarshaling Anthropic stream event: %v", err)
+		}
+		switch event.Type {
+		case "message_start":
+			usage.InputTokens = event.Message.Usage.InputTokens
+		case "content_block_delta":
+			if event.Delta.Type == "text_delta" && event.Delta.Text != "" {
+				content.WriteString(event.Delta.Text)
+				onDelta(event.Delta.Text)
+			}
+		case "message_delta":
+			usage.OutputTokens = event.Usage.OutputTokens
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Completion{}, fmt.Errorf("error reading Anthropic stream: %v", err)
+	}
+
+	if content.Len() == 0 {
+		return Completion{}, fmt.Errorf("no response received from Anthropic API")
+	}
+
+	return Completion{Content: content.String(), Tokens: usage.InputTokens + usage.OutputTokens, InputTokens: usage.InputTokens, OutputTokens: usage.OutputTokens, RateLimitReset: rateLimitReset(resp.Header)}, nil
+}
@@ -0,0 +1,198 @@
+package prgpt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// DiffSource provides the unified diff to review.
+type DiffSource interface {
+	Diff(ctx context.Context) (string, error)
+}
+
+// StaticDiff is a diff the caller already has.
+type StaticDiff string
+
+func (d StaticDiff) Diff(ctx context.Context) (string, error) {
+	return string(d), nil
+}
+
+// PatchFile is a patch read from the file at Path, or from standard input
+// when Path is "-": a git diff, the output of git format-patch or a patch
+// from an email.
+type PatchFile struct {
+	Path string
+}
+
+func (d PatchFile) Diff(ctx context.Context) (string, error) {
+	var data []byte
+	var err error
+	if d.Path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(d.Path)
+	}
+	if err != nil {
+		return "", fmt.Errorf("error reading patch: %v", err)
+	}
+	if strings.TrimSpace(string(data)) == "" {
+		return "", fmt.Errorf("no changes to review")
+	}
+	return string(data), nil
+}
+
+// LocalDiff is the changes of the git repository in Dir, or in the current
+// directory: everything not yet committed, new files that are not ignored
+// included, only the staged changes, or the commits of the current branch
+// since it forked from Base.
+type LocalDiff struct {
+	Dir    string
+	Staged bool
+	Base   string
+}
+
+func (d LocalDiff) Diff(ctx context.Context) (string, error) {
+	args := []string{"diff", "HEAD"}
+	switch {
+	case d.Base != "" && d.Staged:
+		return "", fmt.Errorf("the staged changes cannot be diffed against a base branch")
+	case d.Base != "":
+		args = []string{"diff", d.Base + "...HEAD"}
+	case d.Staged:
+		args = []string{"diff", "--staged"}
+	}
+
+	cmd := Command(ctx, "git", args...)
+	cmd.Dir = d.Dir
+	output, err := readOutput(cmd)
+	if err != nil {
+		return "", fmt.Errorf("error running git %s: %v", strings.Join(args, " "), err)
+	}
+	if d.Base == "" && !d.Staged {
+		untracked, err := d.untracked(ctx)
+		if err != nil {
+			return "", err
+		}
+		output += untracked
+	}
+	if strings.TrimSpace(output) == "" {
+		return "", fmt.Errorf("no changes to review")
+	}
+
+	return output, nil
+}
+
+// untracked diffs the files git does not track yet against nothing, without
+// adding them to the index.
+func (d LocalDiff) untracked(ctx context.Context) (string, error) {
+	cmd := Command(ctx, "git", "ls-files", "--others", "--exclude-standard", "-z")
+	cmd.Dir = d.Dir
+	files, err := readOutput(cmd)
+	if err != nil {
+		return "", fmt.Errorf("error running git ls-files: %v", err)
+	}
+
+	var diff strings.Builder
+	for _, file := range strings.Split(files, "\x00") {
+		if file == "" {
+			continue
+		}
+		cmd := Command(ctx, "git", "diff", "--no-index", "--", os.DevNull, file)
+		cmd.Dir = d.Dir
+		output, err := readOutput(cmd)
+		// git diff --no-index exits with 1 when the files differ.
+		var exitErr *exec.ExitError
+		if err != nil && !(errors.As(err, &exitErr) && exitErr.ExitCode() == 1) {
+			return "", fmt.Errorf("error diffing untracked file %s: %v", file, err)
+		}
+		diff.WriteString(output)
+	}
+	return diff.String(), nil
+}
+
+// GitHubPR is the diff of a GitHub pull request, fetched with the gh CLI.
+type GitHubPR struct {
+	URL string
+	// Timeout bounds how long gh may take; MaxBytes how large the diff may
+	// be before it is rejected. Both are unlimited when zero.
+	Timeout  time.Duration
+	MaxBytes int64
+}
+
+// ErrDiffTooLarge is returned for a diff over the size limit.
+var ErrDiffTooLarge = errors.New("diff too large")
+
+func (d GitHubPR) Diff(ctx context.Context) (string, error) {
+	if d.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.Timeout)
+		defer cancel()
+	}
+
+	// gh explains what went wrong, e.g. that it is not logged in, only on
+	// stderr.
+	var stderr strings.Builder
+	cmd := Command(ctx, "gh", "pr", "diff", d.URL)
+	cmd.Stderr = &stderr
+	output, err := readLimitedOutput(cmd, d.MaxBytes)
+	switch {
+	case errors.Is(err, ErrDiffTooLarge):
+		return "", fmt.Errorf("the diff of %s is larger than %d bytes: %w", d.URL, d.MaxBytes, err)
+	case err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) && d.Timeout > 0:
+		return "", fmt.Errorf("gh pr diff did not finish within %v", d.Timeout)
+	case err != nil && strings.TrimSpace(stderr.String()) != "":
+		return "", fmt.Errorf("error running gh pr diff: %v: %s", err, strings.TrimSpace(stderr.String()))
+	case err != nil:
+		return "", fmt.Errorf("error running gh pr diff: %v", err)
+	}
+	return output, nil
+}
+
+// readOutput runs cmd and returns its standard output, even when it fails.
+func readOutput(cmd *exec.Cmd) (string, error) {
+	return readLimitedOutput(cmd, 0)
+}
+
+// readLimitedOutput is readOutput that stops cmd and returns ErrDiffTooLarge
+// once the output exceeds maxBytes, unless maxBytes is zero, so an oversized
+// diff is rejected without reading the rest of it.
+func readLimitedOutput(cmd *exec.Cmd, maxBytes int64) (string, error) {
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", err
+	}
+	if err := cmd.Start(); err != nil {
+		return "", err
+	}
+	var output strings.Builder
+	var in io.Reader = stdout
+	if maxBytes > 0 {
+		in = io.LimitReader(stdout, maxBytes+1)
+	}
+	n, copyErr := io.Copy(&output, in)
+	if maxBytes > 0 && n > maxBytes {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return "", ErrDiffTooLarge
+	}
+	if err := cmd.Wait(); err != nil {
+		return output.String(), err
+	}
+	return output.String(), copyErr
+}
+
+// Command is exec.CommandContext that also stops waiting for the output of
+// a cancelled command whose children keep its pipes open.
+func Command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	slog.Debug("running", "command", name, "args", args)
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.WaitDelay = time.Second
+	return cmd
+}
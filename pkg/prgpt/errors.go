@@ -0,0 +1,59 @@
+package prgpt
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// APIError is a failed call that the provider explained in its response.
+type APIError struct {
+	Provider string
+	Status   string
+	// Code is the most specific reason given, such as OpenAI's
+	// "insufficient_quota", "context_length_exceeded" or "invalid_api_key",
+	// or Anthropic's error type.
+	Code    string
+	Message string
+}
+
+func (e *APIError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("%s API returned %s: %s (%s)", e.Provider, e.Status, e.Message, e.Code)
+	}
+	return fmt.Sprintf("%s API returned %s: %s", e.Provider, e.Status, e.Message)
+}
+
+// openAIError reads the error object of an OpenAI or Azure OpenAI
+// response, falling back to the raw body for anything else. provider names
+// the API in the error.
+func openAIError(provider, status string, body []byte) error {
+	var resp struct {
+		Error *struct {
+			Message string `json:"message"`
+			Type    string `json:"type"`
+			Code    any    `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil || resp.Error == nil || resp.Error.Message == "" {
+		return fmt.Errorf("%s API returned %s: %s", provider, status, strings.TrimSpace(string(body)))
+	}
+
+	// The code is a string, or a number or null on some Azure errors; the
+	// type is more telling than a numeric code.
+	code, _ := resp.Error.Code.(string)
+	if code == "" {
+		code = resp.Error.Type
+	}
+	return &APIError{Provider: provider, Status: status, Code: code, Message: resp.Error.Message}
+}
+
+// anthropicError reads the error object of an Anthropic response, falling
+// back to the raw body for anything else, such as a proxy's HTML page.
+func anthropicError(status string, body []byte) error {
+	var resp AnthropicResponse
+	if err := json.Unmarshal(body, &resp); err != nil || resp.Error == nil || resp.Error.Message == "" {
+		return fmt.Errorf("Anthropic API returned %s: %s", status, strings.TrimSpace(string(body)))
+	}
+	return &APIError{Provider: "Anthropic", Status: status, Code: resp.Error.Type, Message: resp.Error.Message}
+}
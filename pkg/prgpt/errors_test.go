@@ -0,0 +1,27 @@
+package prgpt
+
+import "testing"
+
+func TestProviderErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"azure", openAIError("Azure OpenAI", "404 Not Found", []byte(`{"error":{"code":"DeploymentNotFound","message":"no such deployment"}}`)),
+			"Azure OpenAI API returned 404 Not Found: no such deployment (DeploymentNotFound)"},
+		{"openai body not JSON", openAIError("OpenAI", "502 Bad Gateway", []byte("<html>bad gateway</html>\n")),
+			"OpenAI API returned 502 Bad Gateway: <html>bad gateway</html>"},
+		{"anthropic", anthropicError("529 Overloaded", []byte(`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`)),
+			"Anthropic API returned 529 Overloaded: Overloaded (overloaded_error)"},
+		{"anthropic body not JSON", anthropicError("502 Bad Gateway", []byte("<html>bad gateway</html>")),
+			"Anthropic API returned 502 Bad Gateway: <html>bad gateway</html>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.Error(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package prgpt
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	DefaultOllamaURL = "http://localhost:11434"
+	OllamaModel      = "llama3"
+)
+
+type OllamaRequest struct {
+	Model    string        `json:"model"`
+	Messages []Message     `json:"messages"`
+	Stream   bool          `json:"stream"`
+	Format   any           `json:"format,omitempty"`
+	Options  OllamaOptions `json:"options"`
+}
+
+type OllamaOptions struct {
+	Temperature float64 `json:"temperature"`
+	TopP        float64 `json:"top_p,omitempty"`
+	NumPredict  int     `json:"num_predict,omitempty"`
+}
+
+// OllamaResponse is a whole response, or one line of a streamed one.
+type OllamaResponse struct {
+	Message struct {
+		Content string `json:"content"`
+	} `json:"message"`
+	Done            bool   `json:"done"`
+	PromptEvalCount int    `json:"prompt_eval_count"`
+	EvalCount       int    `json:"eval_count"`
+	Error           string `json:"error"`
+}
+
+// Ollama talks to a local Ollama server, which needs no API key.
+type Ollama struct {
+	Client *http.Client
+	// BaseURL defaults to DefaultOllamaURL.
+	BaseURL string
+}
+
+func (p *Ollama) Complete(ctx context.Context, r CompletionRequest) (Completion, error) {
+	var messages []Message
+	if r.System != "" {
+		messages = append(messages, Message{Role: "system", Content: r.System})
+	}
+	messages = append(messages, r.History...)
+	messages = append(messages, Message{Role: "user", Content: r.Prompt})
+
+	ollamaReq := OllamaRequest{
+		Model:    r.Model,
+		Messages: messages,
+		Stream:   r.OnDelta != nil,
+		Options:  OllamaOptions{Temperature: r.Temperature, TopP: r.TopP, NumPredict: r.MaxTokens},
+	}
+	if r.ResponseFormat != nil && r.ResponseFormat.JSONSchema != nil {
+		ollamaReq.Format = r.ResponseFormat.JSONSchema.Schema
+	}
+
+	reqBody, err := json.Marshal(ollamaReq)
+	if err != nil {
+		return Completion{}, fmt.Errorf("error marshaling Ollama request: %v", err)
+	}
+
+	baseURL := p.BaseURL
+	if baseURL == "" {
+		baseURL = DefaultOllamaURL
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", strings.TrimSuffix(baseURL, "/")+"/api/chat", bytes.NewBuffer(reqBody))
+	if err != nil {
+		return Completion{}, fmt.Errorf("error creating request to Ollama: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient(p.Client).Do(req)
+	if err != nil {
+		return Completion{}, fmt.Errorf("error making request to Ollama: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return Completion{}, fmt.Errorf("Ollama returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	// Both a whole and a streamed response are newline-delimited JSON.
+	var content strings.Builder
+	var completion Completion
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var chunk OllamaResponse
+		if err := json.Unmarshal(scanner.Bytes(), &chunk); err != nil {
+			return Completion{}, fmt.Errorf("error unmarshaling Ollama response: %v", err)
+		}
+		if chunk.Error != "" {
+			return Completion{}, fmt.Errorf("error from Ollama: %s", chunk.Error)
+		}
+		if chunk.Message.Content != "" {
+			content.WriteString(chunk.Message.Content)
+			if r.OnDelta != nil {
+				r.OnDelta(chunk.Message.Content)
+			}
+		}
+		if chunk.Done {
+			completion.InputTokens = chunk.PromptEvalCount
+			completion.OutputTokens = chunk.EvalCount
+			completion.Tokens = chunk.PromptEvalCount + chunk.EvalCount
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Completion{}, fmt.Errorf("error reading response from Ollama: %v", err)
+	}
+
+	if content.Len() == 0 {
+		return Completion{}, fmt.Errorf("no response received from Ollama")
+	}
+
+	completion.Content = content.String()
+	return completion, nil
+}
@@ -0,0 +1,169 @@
+package prgpt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	OpenAICompletionURL = "https://api.openai.com/v1/chat/completions"
+	OpenAIModel         = "gpt-3.5-turbo-1106"
+	DefaultAzureVersion = "2024-06-01"
+)
+
+type OpenAIRequest struct {
+	Model          string               `json:"model"`
+	Messages       []Message            `json:"messages"`
+	Temperature    float64              `json:"temperature"`
+	MaxTokens      int                  `json:"max_tokens,omitempty"`
+	TopP           float64              `json:"top_p,omitempty"`
+	ResponseFormat *ResponseFormat      `json:"response_format,omitempty"`
+	Stream         bool                 `json:"stream,omitempty"`
+	StreamOptions  *OpenAIStreamOptions `json:"stream_options,omitempty"`
+}
+
+type OpenAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type OpenAIReponse struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int    `json:"created"`
+	Model   string `json:"model"`
+	Usage   struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+		TotalTokens      int `json:"total_tokens"`
+	} `json:"usage"`
+	Choices []struct {
+		Message struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"message"`
+		FinishReason string `json:"finish_reason"`
+		Index        int    `json:"index"`
+	} `json:"choices"`
+}
+
+// OpenAI calls the chat completions API of OpenAI, of an Azure OpenAI
+// deployment, or of an OpenAI-compatible server such as vLLM, LM Studio or
+// OpenRouter.
+type OpenAI struct {
+	Client *http.Client
+	// URL is OpenAICompletionURL, an AzureCompletionURL or a
+	// CompatibleCompletionURL.
+	URL string
+	// APIKey may be empty for servers that need none.
+	APIKey string
+	// Headers are sent with every request.
+	Headers map[string]string
+
+	// Azure sends the key in Azure OpenAI's api-key header instead of as a
+	// bearer token.
+	Azure bool
+}
+
+// AzureCompletionURL is the chat completions endpoint of an Azure OpenAI
+// deployment.
+func AzureCompletionURL(endpoint, deployment, apiVersion string) string {
+	return strings.TrimSuffix(endpoint, "/") + "/openai/deployments/" + url.PathEscape(deployment) +
+		"/chat/completions?api-version=" + url.QueryEscape(apiVersion)
+}
+
+// CompatibleCompletionURL is the chat completions endpoint of the
+// OpenAI-compatible API at baseURL, e.g. http://localhost:8000/v1.
+func CompatibleCompletionURL(baseURL string) string {
+	return strings.TrimSuffix(baseURL, "/") + "/chat/completions"
+}
+
+// name is the API's in errors.
+func (p *OpenAI) name() string {
+	if p.Azure {
+		return "Azure OpenAI"
+	}
+	return "OpenAI"
+}
+
+func (p *OpenAI) Complete(ctx context.Context, r CompletionRequest) (Completion, error) {
+	message := Message{
+		Role:    "user",
+		Content: r.Prompt,
+	}
+
+	openAIReq := OpenAIRequest{
+		Model:          r.Model,
+		Temperature:    r.Temperature,
+		MaxTokens:      r.MaxTokens,
+		TopP:           r.TopP,
+		Messages:       append(r.History[:len(r.History):len(r.History)], message),
+		ResponseFormat: r.ResponseFormat,
+	}
+	if r.System != "" {
+		openAIReq.Messages = append([]Message{{Role: "system", Content: r.System}}, openAIReq.Messages...)
+	}
+	if r.OnDelta != nil {
+		openAIReq.Stream = true
+		openAIReq.StreamOptions = &OpenAIStreamOptions{IncludeUsage: true}
+	}
+
+	reqBody, err := json.Marshal(openAIReq)
+	if err != nil {
+		return Completion{}, fmt.Errorf("error marshaling %s request: %v", p.name(), err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.URL, bytes.NewBuffer(reqBody))
+	if err != nil {
+		return Completion{}, fmt.Errorf("error creating request to %s API: %v", p.name(), err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.Azure {
+		req.Header.Set("api-key", p.APIKey)
+	} else if p.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.APIKey)
+	}
+	for k, v := range p.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := httpClient(p.Client).Do(req)
+	if err != nil {
+		return Completion{}, fmt.Errorf("error making request to %s API: %v", p.name(), err)
+	}
+	defer resp.Body.Close()
+
+	if r.OnDelta != nil && resp.StatusCode == http.StatusOK {
+		return readOpenAIStream(resp, r.OnDelta)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Completion{}, fmt.Errorf("error reading response from %s API: %v", p.name(), err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Completion{}, openAIError(p.name(), resp.Status, body)
+	}
+
+	var openAIResp OpenAIReponse
+	if err := json.Unmarshal(body, &openAIResp); err != nil {
+		return Completion{}, fmt.Errorf("error unmarshaling OpenAI response: %v", err)
+	}
+
+	if len(openAIResp.Choices) == 0 {
+		return Completion{}, fmt.Errorf("no response received from %s API", p.name())
+	}
+
+	return Completion{
+		Content:        openAIResp.Choices[0].Message.Content,
+		Tokens:         openAIResp.Usage.TotalTokens,
+		InputTokens:    openAIResp.Usage.PromptTokens,
+		OutputTokens:   openAIResp.Usage.CompletionTokens,
+		RateLimitReset: rateLimitReset(resp.Header),
+	}, nil
+}
@@ -0,0 +1,88 @@
+package prgpt
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Provider sends a prompt to a model and returns its reply.
+type Provider interface {
+	Complete(ctx context.Context, req CompletionRequest) (Completion, error)
+}
+
+type CompletionRequest struct {
+	Model string
+	// System is sent as the system message when set.
+	System string
+	// History holds earlier turns of the conversation, sent before Prompt.
+	History     []Message
+	Prompt      string
+	Temperature float64
+	// MaxTokens and TopP are left to the provider's defaults when zero.
+	MaxTokens      int
+	TopP           float64
+	ResponseFormat *ResponseFormat
+
+	// OnDelta, when set, asks for a streamed response and receives each
+	// piece of content as it arrives. Providers that cannot stream call it
+	// once with the whole content.
+	OnDelta func(delta string)
+}
+
+type Completion struct {
+	Content string
+	// Tokens is the total of InputTokens, the prompt, and OutputTokens,
+	// the reply, as reported by the provider.
+	Tokens       int
+	InputTokens  int
+	OutputTokens int
+
+	// RateLimitReset is how long to hold off further calls because the
+	// provider reported the request quota as exhausted.
+	RateLimitReset time.Duration
+}
+
+// Message is one turn of a conversation.
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// ResponseFormat asks for a reply following a JSON Schema.
+type ResponseFormat struct {
+	Type       string      `json:"type"`
+	JSONSchema *JSONSchema `json:"json_schema,omitempty"`
+}
+
+type JSONSchema struct {
+	Name   string         `json:"name"`
+	Schema map[string]any `json:"schema"`
+}
+
+func httpClient(c *http.Client) *http.Client {
+	if c != nil {
+		return c
+	}
+	return http.DefaultClient
+}
+
+// rateLimitReset reads OpenAI's or Anthropic's rate-limit headers and
+// returns how long to wait when no requests are left in the current window.
+func rateLimitReset(h http.Header) time.Duration {
+	if h.Get("anthropic-ratelimit-requests-remaining") == "0" {
+		reset, err := time.Parse(time.RFC3339, h.Get("anthropic-ratelimit-requests-reset"))
+		if err != nil {
+			return 0
+		}
+		return max(time.Until(reset), 0)
+	}
+	if h.Get("x-ratelimit-remaining-requests") != "0" {
+		return 0
+	}
+	d, err := time.ParseDuration(h.Get("x-ratelimit-reset-requests"))
+	if err != nil {
+		return 0
+	}
+	return d
+}
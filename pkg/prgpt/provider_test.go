@@ -0,0 +1,41 @@
+package prgpt
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRateLimitReset(t *testing.T) {
+	tests := []struct {
+		name   string
+		header map[string]string
+		want   time.Duration
+	}{
+		{"quota left", map[string]string{"x-ratelimit-remaining-requests": "3", "x-ratelimit-reset-requests": "2s"}, 0},
+		{"openai exhausted", map[string]string{"x-ratelimit-remaining-requests": "0", "x-ratelimit-reset-requests": "1.5s"}, 1500 * time.Millisecond},
+		{"openai unparsable reset", map[string]string{"x-ratelimit-remaining-requests": "0", "x-ratelimit-reset-requests": "soon"}, 0},
+		{"anthropic reset in the past", map[string]string{"anthropic-ratelimit-requests-remaining": "0", "anthropic-ratelimit-requests-reset": "2000-01-01T00:00:00Z"}, 0},
+		{"no headers", nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			for k, v := range tt.header {
+				h.Set(k, v)
+			}
+			if got := rateLimitReset(h); got != tt.want {
+				t.Errorf("rateLimitReset = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("anthropic reset ahead", func(t *testing.T) {
+		h := http.Header{}
+		h.Set("anthropic-ratelimit-requests-remaining", "0")
+		h.Set("anthropic-ratelimit-requests-reset", time.Now().Add(time.Minute).UTC().Format(time.RFC3339))
+		if got := rateLimitReset(h); got <= 58*time.Second || got > time.Minute {
+			t.Errorf("rateLimitReset = %v, want about a minute", got)
+		}
+	})
+}
@@ -0,0 +1,114 @@
+// Package prgpt reviews diffs with a language model. It is the library
+// behind the prgpt command, for programs that want to embed reviews rather
+// than run the CLI:
+//
+//	r := &prgpt.Reviewer{Provider: &prgpt.OpenAI{URL: prgpt.OpenAICompletionURL, APIKey: key}, Model: prgpt.OpenAIModel}
+//	review, err := r.Review(ctx, prgpt.GitHubPR{URL: "https://github.com/org/repo/pull/1"})
+package prgpt
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	ReviewIntro        = "Please provide a final consideration for this PR in Markdown format, focusing only on potential issues and ensuring the application's stability."
+	VerdictInstruction = "Include an 'Approved: true/false' statement at the end for easy decision-making.Thank you!"
+
+	// DefaultInstruction is what a Reviewer asks for when its Instruction
+	// is empty.
+	DefaultInstruction = ReviewIntro + " " + VerdictInstruction
+
+	DefaultTemperature = 0.5
+)
+
+// Reviewer reviews diffs with a model of its Provider.
+type Reviewer struct {
+	Provider Provider
+	Model    string
+	// System is sent as the system message when set.
+	System string
+	// History is the earlier turns of the conversation, sent before the
+	// prompt.
+	History []Message
+	// Instruction follows the diff in the prompt; DefaultInstruction when
+	// empty.
+	Instruction string
+	// Temperature defaults to DefaultTemperature when nil.
+	Temperature *float64
+	// MaxTokens and TopP are left to the provider's defaults when zero.
+	MaxTokens int
+	TopP      float64
+}
+
+// Review is the outcome of reviewing a diff.
+type Review struct {
+	Text string
+	// Approved is the verdict the review ends with; VerdictFound reports
+	// whether it had one at all.
+	Approved     bool
+	VerdictFound bool
+	Tokens       int
+}
+
+// Review fetches the diff from src and reviews it in a single call.
+func (r *Reviewer) Review(ctx context.Context, src DiffSource) (Review, error) {
+	diff, err := src.Diff(ctx)
+	if err != nil {
+		return Review{}, err
+	}
+
+	instruction := r.Instruction
+	if instruction == "" {
+		instruction = DefaultInstruction
+	}
+
+	resp, err := r.Complete(ctx, diff+"\n"+instruction, nil, nil)
+	if err != nil {
+		return Review{}, fmt.Errorf("error reviewing diff: %v", err)
+	}
+
+	approved, found := ParseVerdict(resp.Content)
+	return Review{Text: resp.Content, Approved: approved, VerdictFound: found, Tokens: resp.Tokens}, nil
+}
+
+// Complete sends a prompt the caller built, such as a review with more
+// context than Review gives, with the Reviewer's model and settings.
+// format asks for a structured response when set, and onDelta receives the
+// response as it is generated when set.
+func (r *Reviewer) Complete(ctx context.Context, prompt string, format *ResponseFormat, onDelta func(string)) (Completion, error) {
+	temperature := DefaultTemperature
+	if r.Temperature != nil {
+		temperature = *r.Temperature
+	}
+	return r.Provider.Complete(ctx, CompletionRequest{
+		Model:          r.Model,
+		System:         r.System,
+		History:        r.History,
+		Prompt:         prompt,
+		Temperature:    temperature,
+		MaxTokens:      r.MaxTokens,
+		TopP:           r.TopP,
+		ResponseFormat: format,
+		OnDelta:        onDelta,
+	})
+}
+
+var verdictLine = regexp.MustCompile(`(?i)approved\W{0,4}\s*(true|false|yes|no)\b`)
+
+// ParseVerdict reads the 'Approved: true/false' statement the prompt asks
+// the model to end its review with. The last statement wins.
+func ParseVerdict(review string) (approved bool, found bool) {
+	matches := verdictLine.FindAllStringSubmatch(review, -1)
+	if len(matches) == 0 {
+		return false, false
+	}
+
+	switch strings.ToLower(matches[len(matches)-1][1]) {
+	case "true", "yes":
+		return true, true
+	}
+	return false, true
+}
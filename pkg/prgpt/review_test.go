@@ -0,0 +1,53 @@
+package prgpt
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+type recordingProvider struct {
+	req     CompletionRequest
+	content string
+}
+
+func (p *recordingProvider) Complete(ctx context.Context, req CompletionRequest) (Completion, error) {
+	p.req = req
+	return Completion{Content: p.content, Tokens: 7}, nil
+}
+
+func TestReviewerReview(t *testing.T) {
+	temperature := 0.0
+	tests := []struct {
+		name            string
+		reviewer        Reviewer
+		content         string
+		wantApproved    bool
+		wantFound       bool
+		wantTemperature float64
+		wantPrompt      string
+	}{
+		{"defaults", Reviewer{Model: "m"}, "Looks good.\n\nApproved: true", true, true, DefaultTemperature, DefaultInstruction},
+		{"settings", Reviewer{Model: "m", Instruction: "Find bugs.", Temperature: &temperature}, "Approved: no", false, true, 0, "Find bugs."},
+		{"no verdict", Reviewer{Model: "m"}, "Hmm.", false, false, DefaultTemperature, DefaultInstruction},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &recordingProvider{content: tt.content}
+			tt.reviewer.Provider = p
+			review, err := tt.reviewer.Review(context.Background(), StaticDiff("diff --git a/x b/x\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if review.Approved != tt.wantApproved || review.VerdictFound != tt.wantFound || review.Tokens != 7 {
+				t.Errorf("review = %+v", review)
+			}
+			if p.req.Temperature != tt.wantTemperature || p.req.Model != "m" {
+				t.Errorf("request = %+v", p.req)
+			}
+			if !strings.HasPrefix(p.req.Prompt, "diff --git") || !strings.HasSuffix(p.req.Prompt, tt.wantPrompt) {
+				t.Errorf("prompt = %q", p.req.Prompt)
+			}
+		})
+	}
+}
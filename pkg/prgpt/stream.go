@@ -0,0 +1,68 @@
+package prgpt
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type openAIStreamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+		TotalTokens      int `json:"total_tokens"`
+	} `json:"usage"`
+}
+
+// readOpenAIStream consumes a server-sent events response of the chat
+// completions API, passing every content delta to onDelta as it arrives.
+func readOpenAIStream(resp *http.Response, onDelta func(string)) (Completion, error) {
+	var content strings.Builder
+	var completion Completion
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk openAIStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return Completion{}, fmt.Errorf("error unmarshaling OpenAI stream chunk: %v", err)
+		}
+		if chunk.Usage != nil {
+			completion.Tokens = chunk.Usage.TotalTokens
+			completion.InputTokens = chunk.Usage.PromptTokens
+			completion.OutputTokens = chunk.Usage.CompletionTokens
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Delta.Content != "" {
+				content.WriteString(choice.Delta.Content)
+				onDelta(choice.Delta.Content)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Completion{}, fmt.Errorf("error reading OpenAI stream: %v", err)
+	}
+
+	if content.Len() == 0 {
+		return Completion{}, fmt.Errorf("no response received from OpenAI API")
+	}
+
+	completion.Content = content.String()
+	completion.RateLimitReset = rateLimitReset(resp.Header)
+	return completion, nil
+}
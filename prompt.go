@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// PromptTemplate replaces the built-in prompt. User is a text/template
+// rendered with promptData; System, when set, is sent as the system message.
+type PromptTemplate struct {
+	System string `toml:"system"`
+	User   string `toml:"user"`
+}
+
+// promptData is what prompt templates can refer to.
+type promptData struct {
+	Diff  string
+	Repo  string
+	Title string
+	// Description holds the PR's description, labels and linked issues.
+	Description string
+	Context     string
+	Instruction string
+}
+
+// promptBuilder renders the prompts of a run, through the configured
+// template if there is one.
+type promptBuilder struct {
+	tmpl *template.Template
+	// usesInstruction tells whether the template places the instruction
+	// itself; otherwise it is appended so the verdict can still be parsed.
+	usesInstruction bool
+	data            promptData
+}
+
+// selectPrompt picks the template named by -prompt, or [prompt] custom and
+// system when no name is given.
+func selectPrompt(cfg FileConfig, name string) (PromptTemplate, error) {
+	if name == "" {
+		return PromptTemplate{System: cfg.Prompt.System, User: cfg.Prompt.Custom}, nil
+	}
+	t, ok := cfg.Prompt.Templates[name]
+	if !ok {
+		return t, fmt.Errorf("no prompt template named %q in [prompt.templates]", name)
+	}
+	if t.System == "" {
+		t.System = cfg.Prompt.System
+	}
+	return t, nil
+}
+
+// newPromptBuilder prepares the template user, filling in the PR-wide
+// fields of data; the rest are set per prompt.
+func newPromptBuilder(user string, data promptData) (*promptBuilder, error) {
+	b := &promptBuilder{data: data}
+	if user == "" {
+		return b, nil
+	}
+
+	tmpl, err := template.New("prompt").Option("missingkey=error").Parse(user)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing prompt template: %v", err)
+	}
+	b.tmpl = tmpl
+	b.usesInstruction = strings.Contains(user, ".Instruction")
+	return b, nil
+}
+
+func (b *promptBuilder) build(diff, repoContext, instruction string) (string, error) {
+	if b == nil {
+		return buildPrompt(diff, repoContext, "", instruction), nil
+	}
+	if b.tmpl == nil {
+		return buildPrompt(diff, repoContext, b.data.Description, instruction), nil
+	}
+
+	data := b.data
+	data.Diff, data.Context, data.Instruction = diff, repoContext, instruction
+	var out strings.Builder
+	err := b.tmpl.Execute(&out, data)
+	if err != nil {
+		return "", fmt.Errorf("error rendering prompt template: %v", err)
+	}
+	if !b.usesInstruction {
+		out.WriteString("\n" + instruction)
+	}
+	return out.String(), nil
+}
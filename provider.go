@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/loadfms/prgpt/pkg/prgpt"
+)
+
+const defaultProvider = "openai"
+
+// defaultModels is the model used by each provider when none is configured.
+var defaultModels = map[string]string{
+	"openai":    prgpt.OpenAIModel,
+	"anthropic": prgpt.AnthropicModel,
+	"azure":     prgpt.OpenAIModel,
+	"ollama":    prgpt.OllamaModel,
+	"mock":      "mock",
+}
+
+// newProvider returns the named provider, waiting for its [rate_limit]
+// before every call when one is configured.
+func newProvider(name string, cfg FileConfig, client *http.Client) (prgpt.Provider, error) {
+	base, err := newBaseProvider(name, cfg, client)
+	if err != nil {
+		return nil, err
+	}
+	var p prgpt.Provider = retriedProvider{base}
+	limit, ok := cfg.RateLimit[name]
+	if !ok || limit.RequestsPerMinute <= 0 && limit.TokensPerMinute <= 0 {
+		return p, nil
+	}
+	limiter, err := newRateLimiter(name, providerAccount(name, cfg), limit)
+	if err != nil {
+		return nil, fmt.Errorf("error setting up [rate_limit.%s]: %v", name, err)
+	}
+	return limitedProvider{Provider: p, limiter: limiter}, nil
+}
+
+// providerAccount identifies what the provider's calls are billed to, so
+// profiles sharing a key and endpoint share a rate limit and others do not.
+func providerAccount(name string, cfg FileConfig) string {
+	switch name {
+	case "openai":
+		if cfg.OpenAI.BaseURL != "" {
+			return cfg.OpenAI.BaseURL + "\n" + cfg.OpenAI.Key
+		}
+		return cfg.ApiKey.Key
+	case "azure":
+		return cfg.Azure.Endpoint + "\n" + cfg.Azure.Key
+	case "anthropic":
+		return cfg.Anthropic.Key
+	case "ollama":
+		return cfg.Ollama.BaseURL
+	}
+	return ""
+}
+
+func newBaseProvider(name string, cfg FileConfig, client *http.Client) (prgpt.Provider, error) {
+	switch name {
+	case "openai":
+		// Self-hosted servers often need no key, and never get the OpenAI
+		// one.
+		if cfg.OpenAI.BaseURL != "" {
+			u := prgpt.CompatibleCompletionURL(cfg.OpenAI.BaseURL)
+			return &prgpt.OpenAI{Client: client, URL: u, APIKey: cfg.OpenAI.Key, Headers: cfg.OpenAI.Headers}, nil
+		}
+		if cfg.ApiKey.Key == "" {
+			return nil, fmt.Errorf("missing [apikey] key; run \"prgpt init\" or set OPENAI_API_KEY")
+		}
+		return &prgpt.OpenAI{Client: client, URL: prgpt.OpenAICompletionURL, APIKey: cfg.ApiKey.Key, Headers: cfg.OpenAI.Headers}, nil
+	case "azure":
+		if cfg.Azure.Endpoint == "" {
+			return nil, fmt.Errorf("provider azure needs [azure] endpoint; run \"prgpt init\" or set PRGPT_AZURE_ENDPOINT")
+		}
+		if cfg.Azure.Key == "" {
+			return nil, fmt.Errorf("missing [azure] key; run \"prgpt init\" or set AZURE_OPENAI_API_KEY")
+		}
+		deployment := cfg.Azure.Deployment
+		if deployment == "" {
+			deployment = cfg.Model.Name
+		}
+		apiVersion := cfg.Azure.APIVersion
+		if apiVersion == "" {
+			apiVersion = prgpt.DefaultAzureVersion
+		}
+		u := prgpt.AzureCompletionURL(cfg.Azure.Endpoint, deployment, apiVersion)
+		return &prgpt.OpenAI{Client: client, URL: u, APIKey: cfg.Azure.Key, Azure: true}, nil
+	case "anthropic":
+		if cfg.Anthropic.Key == "" {
+			return nil, fmt.Errorf("missing [anthropic] key; run \"prgpt init\" or set ANTHROPIC_API_KEY")
+		}
+		return &prgpt.Anthropic{Client: client, APIKey: cfg.Anthropic.Key}, nil
+	case "ollama":
+		return &prgpt.Ollama{Client: client, BaseURL: cfg.Ollama.BaseURL}, nil
+	case "mock":
+		return &mockProvider{approve: cfg.Mock.Approve, text: cfg.Mock.Text}, nil
+	}
+	return nil, fmt.Errorf("unknown provider %q, expected openai, azure, anthropic, ollama or mock", name)
+}
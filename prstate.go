@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// prState is what prgpt remembers about a PR between runs.
+type prState struct {
+	HeadSHA string `json:"head_sha"`
+}
+
+// prCachePath returns the per-PR file of the given kind in the cache.
+func prCachePath(kind, prURL string) (string, error) {
+	org, repo, prNumber, err := parsePRURL(prURL)
+	if err != nil {
+		return "", err
+	}
+
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("error locating cache directory: %v", err)
+	}
+
+	parts := []string{org, repo, prNumber}
+	// Self-hosted instances may have repositories of the same name.
+	if u, err := url.Parse(prURL); err == nil && u.Host != "github.com" && u.Host != "gitlab.com" {
+		parts = append([]string{u.Host}, parts...)
+	}
+	name := sanitizeFilename(strings.Join(parts, "_")) + ".json"
+	return filepath.Join(base, "prgpt", kind, name), nil
+}
+
+func prStatePath(prURL string) (string, error) {
+	return prCachePath("prs", prURL)
+}
+
+func loadPRState(prURL string) (prState, error) {
+	var state prState
+
+	path, err := prStatePath(prURL)
+	if err != nil {
+		return state, err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return state, fmt.Errorf("error reading PR state: %v", err)
+	}
+
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, fmt.Errorf("error parsing PR state: %v", err)
+	}
+	return state, nil
+}
+
+func savePRState(prURL string, state prState) error {
+	path, err := prStatePath(prURL)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("error creating PR state directory: %v", err)
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("error marshaling PR state: %v", err)
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// detectForcePush compares the PR's current head with the one recorded by
+// the previous run and reports whether history was rewritten in between.
+func detectForcePush(ctx context.Context, fg forge, prURL string) (headSHA string, previousSHA string, forced bool) {
+	headSHA, err := fg.HeadSHA(ctx, prURL)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Warning: could not determine PR head:", err)
+		return "", "", false
+	}
+
+	state, err := loadPRState(prURL)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Warning:", err)
+		return headSHA, "", false
+	}
+
+	previousSHA = state.HeadSHA
+	if previousSHA == "" || previousSHA == headSHA {
+		return headSHA, previousSHA, false
+	}
+	return headSHA, previousSHA, !fg.IsFastForward(ctx, prURL, previousSHA, headSHA)
+}
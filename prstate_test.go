@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestForcePushBypassesCache(t *testing.T) {
+	diff, err := os.ReadFile("testdata/replay/change.patch")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	head, calls := "", 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.URL.Path == "/v1/chat/completions":
+			calls++
+			fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"Looks fine.\n\nApproved: true"}}],"usage":{"prompt_tokens":10,"completion_tokens":5}}`)
+		case r.URL.Path == "/api/v3/repos/o/r/pulls/1" && strings.Contains(r.Header.Get("Accept"), "diff"):
+			w.Write(diff)
+		case r.URL.Path == "/api/v3/repos/o/r/pulls/1":
+			json.NewEncoder(w).Encode(map[string]any{"title": "t", "head": map[string]string{"sha": head}})
+		case strings.HasPrefix(r.URL.Path, "/api/v3/repos/o/r/compare/"):
+			// Every new head rewrote history.
+			fmt.Fprint(w, `{"status":"diverged"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	useConfig(t, fmt.Sprintf("provider = \"openai\"\n[openai]\nbase_url = %q\n", server.URL+"/v1"))
+	t.Setenv("GITHUB_TOKEN", "t")
+
+	tests := []struct {
+		name        string
+		head        string
+		wantCalls   bool
+		forcePushed bool
+	}{
+		{"first review", "1111111", true, false},
+		{"same head", "1111111", false, false},
+		{"force-pushed", "2222222", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mu.Lock()
+			head, calls = tt.head, 0
+			mu.Unlock()
+			output, code := runPrgpt(t, "-pr", server.URL+"/o/r/pull/1", "-chunked")
+			if code != exitApproved {
+				t.Fatalf("exit code = %d; output:\n%s", code, output)
+			}
+			if (calls > 0) != tt.wantCalls {
+				t.Errorf("%d API calls, want calls: %t", calls, tt.wantCalls)
+			}
+			if got := strings.Contains(output, "force-pushed since the last review (1111111 -> 2222222)"); got != tt.forcePushed {
+				t.Errorf("force-push noted: %t, want %t; output:\n%s", got, tt.forcePushed, output)
+			}
+		})
+	}
+}
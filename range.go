@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/loadfms/prgpt/pkg/prgpt"
+)
+
+// rangeCommit is a commit reviewed by prgpt range.
+type rangeCommit struct {
+	sha, title string
+}
+
+// rangeCommits lists the commits of from..to, oldest first, leaving out
+// merges, whose changes are their parents'.
+func rangeCommits(ctx context.Context, from, to string) ([]rangeCommit, error) {
+	output, err := prgpt.Command(ctx, "git", "log", "--reverse", "--no-merges", "--format=%H %h %s", from+".."+to).Output()
+	if err != nil {
+		return nil, fmt.Errorf("error running git log: %v", err)
+	}
+	var commits []rangeCommit
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		sha, title, ok := strings.Cut(line, " ")
+		if ok {
+			commits = append(commits, rangeCommit{sha: sha, title: title})
+		}
+	}
+	return commits, nil
+}
+
+// runRange implements "prgpt range FROM..TO": a review of every commit of
+// the range on its own, e.g. to clean up a branch before opening its PR.
+// Each commit is reviewed as the patch git format-patch makes of it, with
+// its message, by its own prgpt process given the flags after --, like the
+// PRs of a batch.
+func runRange(args []string) int {
+	fs := newCommandFlagSet("range")
+	workers := fs.Int("workers", defaultWorkers, "How many commits to review at the same time")
+	summary := fs.Bool("summary", false, "Print a table of the verdicts instead of every review")
+	if err := fs.Parse(args); err != nil {
+		return parseExit(err)
+	}
+	// The range may come before the flags.
+	commitRange := fs.Arg(0)
+	if fs.NArg() > 0 {
+		if err := fs.Parse(fs.Args()[1:]); err != nil {
+			return parseExit(err)
+		}
+	}
+	from, to, ok := strings.Cut(commitRange, "..")
+	to = strings.TrimPrefix(to, ".")
+	if !ok || from == "" || to == "" {
+		fs.SetOutput(os.Stdout)
+		fs.Usage()
+		return exitError
+	}
+	reviewArgs := fs.Args()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	commits, err := rangeCommits(ctx, from, to)
+	if err != nil {
+		fmt.Println(err)
+		return exitError
+	}
+	if len(commits) == 0 {
+		fmt.Printf("No commits between %s and %s; nothing to review.\n", from, to)
+		return exitApproved
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Println("Error locating prgpt executable:", err)
+		return exitError
+	}
+	dir, err := os.MkdirTemp("", "prgpt-range-")
+	if err != nil {
+		fmt.Println("Error creating temporary directory:", err)
+		return exitError
+	}
+	defer os.RemoveAll(dir)
+
+	results := make([]batchResult, len(commits))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(max(*workers, 1), len(commits)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = reviewCommit(ctx, exe, dir, commits[i], reviewArgs)
+			}
+		}()
+	}
+	for i := range commits {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return printBatch(results, *summary, "Commit")
+}
+
+func reviewCommit(ctx context.Context, exe, dir string, c rangeCommit, args []string) batchResult {
+	name := c.title
+	patch, err := prgpt.Command(ctx, "git", "format-patch", "-1", "--stdout", "--no-signature", c.sha).Output()
+	if err != nil {
+		return batchResult{name: name, output: fmt.Sprintf("Error running git format-patch: %v\n", err), code: exitError}
+	}
+	path := filepath.Join(dir, c.sha+".patch")
+	if err := os.WriteFile(path, patch, 0o600); err != nil {
+		return batchResult{name: name, output: fmt.Sprintf("Error writing patch: %v\n", err), code: exitError}
+	}
+	return runReviewProcess(ctx, exe, name, append(append([]string{}, args...), "-diff="+path), filepath.Join(dir, "pace"))
+}
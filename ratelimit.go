@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/loadfms/prgpt/pkg/prgpt"
+)
+
+const rateLockPoll = 20 * time.Millisecond
+
+// RateLimit caps the calls to a provider of every prgpt process of the user
+// together, such as the reviews run by a batch or by prgpt serve, which each
+// run in their own process.
+type RateLimit struct {
+	RequestsPerMinute int `toml:"requests_per_minute"`
+	TokensPerMinute   int `toml:"tokens_per_minute"`
+}
+
+// rateBucket is the state of a token bucket, kept in the cache so every
+// process draws from the same one.
+type rateBucket struct {
+	Requests float64   `json:"requests"`
+	Tokens   float64   `json:"tokens"`
+	Updated  time.Time `json:"updated"`
+}
+
+// rateLimiter is a token bucket for requests and one for tokens, each
+// refilling at its rate per minute and holding at most a minute's worth.
+type rateLimiter struct {
+	path  string
+	limit RateLimit
+}
+
+// newRateLimiter returns the limiter of the provider's calls billed to
+// account, which names the bucket only by its hash since it holds the key.
+func newRateLimiter(provider, account string, limit RateLimit) (*rateLimiter, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return nil, fmt.Errorf("error locating cache directory: %v", err)
+	}
+	dir := filepath.Join(base, "prgpt", "ratelimit")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("error creating rate limit directory: %v", err)
+	}
+	sum := sha256.Sum256([]byte(account))
+	name := sanitizeFilename(provider) + "-" + hex.EncodeToString(sum[:6])
+	return &rateLimiter{path: filepath.Join(dir, name+".json"), limit: limit}, nil
+}
+
+// wait blocks until a request of the given tokens fits in the buckets, or
+// until ctx ends.
+func (l *rateLimiter) wait(ctx context.Context, tokens int) error {
+	for {
+		delay, err := l.take(ctx, tokens)
+		if err != nil || delay == 0 {
+			return err
+		}
+		slog.Debug("rate limited", "bucket", l.path, "tokens", tokens, "delay", delay)
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// take draws a request and its tokens from the buckets when they hold
+// enough, or returns how long to wait until they will. A request larger than
+// a full bucket of tokens is let through when the bucket is full.
+func (l *rateLimiter) take(ctx context.Context, tokens int) (time.Duration, error) {
+	unlock, err := lockFile(ctx, l.path+".lock")
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	rpm, tpm := float64(l.limit.RequestsPerMinute), float64(l.limit.TokensPerMinute)
+	now := time.Now()
+	bucket := rateBucket{Requests: rpm, Tokens: tpm, Updated: now}
+	// A bucket that cannot be read starts out full.
+	var saved rateBucket
+	if data, err := os.ReadFile(l.path); err == nil && json.Unmarshal(data, &saved) == nil {
+		elapsed := now.Sub(saved.Updated).Minutes()
+		bucket.Requests = min(saved.Requests+elapsed*rpm, rpm)
+		bucket.Tokens = min(saved.Tokens+elapsed*tpm, tpm)
+	}
+
+	need := min(float64(tokens), tpm)
+	var wait float64
+	if rpm > 0 && bucket.Requests < 1 {
+		wait = (1 - bucket.Requests) / rpm
+	}
+	if tpm > 0 && bucket.Tokens < need {
+		wait = max(wait, (need-bucket.Tokens)/tpm)
+	}
+	if wait == 0 && rpm > 0 {
+		bucket.Requests--
+	}
+	if wait == 0 && tpm > 0 {
+		bucket.Tokens -= float64(tokens)
+	}
+
+	data, err := json.Marshal(bucket)
+	if err != nil {
+		return 0, fmt.Errorf("error marshaling rate limit state: %v", err)
+	}
+	if err := os.WriteFile(l.path, data, 0o600); err != nil {
+		return 0, fmt.Errorf("error writing rate limit state: %v", err)
+	}
+	return time.Duration(wait * float64(time.Minute)), nil
+}
+
+// lockFile takes an exclusive lock on path, creating it, and returns the
+// function releasing it. The operating system releases the lock of a
+// process that crashes, so the file is never removed.
+func lockFile(ctx context.Context, path string) (func(), error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("error locking %s: %v", path, err)
+	}
+	for {
+		locked, err := tryLock(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("error locking %s: %v", path, err)
+		}
+		if locked {
+			return func() { f.Close() }, nil
+		}
+		timer := time.NewTimer(rateLockPoll)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			f.Close()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// limitedProvider waits for the rate limiter before every call.
+type limitedProvider struct {
+	prgpt.Provider
+	limiter *rateLimiter
+}
+
+func (p limitedProvider) Complete(ctx context.Context, req prgpt.CompletionRequest) (prgpt.Completion, error) {
+	if err := p.limiter.wait(ctx, requestTokens(req)); err != nil {
+		return prgpt.Completion{}, err
+	}
+	return p.Provider.Complete(ctx, req)
+}
+
+// requestTokens estimates the tokens a call counts against a limit: its
+// prompt and the longest reply it allows.
+func requestTokens(req prgpt.CompletionRequest) int {
+	n := estimateTokens(req.System) + estimateTokens(req.Prompt) + req.MaxTokens
+	for _, m := range req.History {
+		n += estimateTokens(m.Content)
+	}
+	return n
+}
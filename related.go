@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/loadfms/prgpt/pkg/prgpt"
+)
+
+const (
+	relatedPRCandidates = 30
+	relatedPRBodyChars  = 400
+)
+
+type relatedPR struct {
+	Number int             `json:"number"`
+	Title  string          `json:"title"`
+	Body   string          `json:"body"`
+	Files  []relatedPRFile `json:"files"`
+}
+
+type relatedPRFile struct {
+	Path string `json:"path"`
+}
+
+// fetchRecentMergedPRs lists the most recently merged PRs of the repository
+// with gh.
+func fetchRecentMergedPRs(ctx context.Context, prURL string) ([]relatedPR, error) {
+	repo, err := ghRepo(prURL)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := prgpt.Command(ctx, "gh", "pr", "list", "-R", repo, "--state", "merged",
+		"--limit", strconv.Itoa(relatedPRCandidates), "--json", "number,title,body,files")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("error running gh pr list: %v", err)
+	}
+
+	var prs []relatedPR
+	if err := json.Unmarshal(output, &prs); err != nil {
+		return nil, fmt.Errorf("error parsing gh pr list output: %v", err)
+	}
+	return prs, nil
+}
+
+// relatedPRContext summarizes up to limit of the candidate PRs that touched
+// any of the given files, for the model to check the new PR against.
+func relatedPRContext(candidates []relatedPR, files []fileDiff, limit int) string {
+	changed := map[string]bool{}
+	for _, f := range files {
+		changed[f.Path] = true
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, pr := range candidates {
+		if n == limit {
+			break
+		}
+
+		var common []string
+		for _, f := range pr.Files {
+			if changed[f.Path] {
+				common = append(common, f.Path)
+			}
+		}
+		if len(common) == 0 {
+			continue
+		}
+
+		body := strings.Join(strings.Fields(pr.Body), " ")
+		if len(body) > relatedPRBodyChars {
+			// Cut at the start of a character, not within one.
+			end := relatedPRBodyChars
+			for end > 0 && !utf8.RuneStart(body[end]) {
+				end--
+			}
+			body = body[:end] + "..."
+		}
+		fmt.Fprintf(&b, "- #%d %s (also touched %s)", pr.Number, pr.Title, strings.Join(common, ", "))
+		if body != "" {
+			b.WriteString(": " + body)
+		}
+		b.WriteString("\n")
+		n++
+	}
+
+	if n == 0 {
+		return ""
+	}
+	return "Recently merged PRs that touched the same files. Check that this PR follows the patterns they established:\n" + b.String()
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRelatedPRContext(t *testing.T) {
+	files := []fileDiff{{Path: "store.go"}, {Path: "cache.go"}}
+	pr := func(number int, body string, paths ...string) relatedPR {
+		p := relatedPR{Number: number, Title: fmt.Sprintf("PR %d", number), Body: body}
+		for _, path := range paths {
+			p.Files = append(p.Files, relatedPRFile{Path: path})
+		}
+		return p
+	}
+	tests := []struct {
+		name       string
+		candidates []relatedPR
+		limit      int
+		want       []string
+		absent     []string
+	}{
+		{
+			name:       "only PRs touching the same files",
+			candidates: []relatedPR{pr(3, "Adds locking.", "store.go", "lock.go"), pr(2, "", "README.md"), pr(1, "", "cache.go")},
+			limit:      5,
+			want:       []string{"- #3 PR 3 (also touched store.go): Adds locking.\n", "- #1 PR 1 (also touched cache.go)\n"},
+			absent:     []string{"#2"},
+		},
+		{
+			name:       "up to the limit",
+			candidates: []relatedPR{pr(3, "", "store.go"), pr(2, "", "store.go"), pr(1, "", "store.go")},
+			limit:      2,
+			want:       []string{"#3", "#2"},
+			absent:     []string{"#1"},
+		},
+		{
+			name:       "long body",
+			candidates: []relatedPR{pr(1, strings.Repeat("a", relatedPRBodyChars+10), "store.go")},
+			limit:      1,
+			want:       []string{": " + strings.Repeat("a", relatedPRBodyChars) + "...\n"},
+		},
+		{
+			name:       "long body cut between characters",
+			candidates: []relatedPR{pr(1, "a"+strings.Repeat("é", relatedPRBodyChars), "store.go")},
+			limit:      1,
+			want:       []string{": a" + strings.Repeat("é", relatedPRBodyChars/2-1) + "...\n"},
+		},
+		{
+			name:       "body whitespace collapsed",
+			candidates: []relatedPR{pr(1, "Line one.\n\n  Line two.", "store.go")},
+			limit:      1,
+			want:       []string{": Line one. Line two.\n"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := relatedPRContext(tt.candidates, files, tt.limit)
+			if !strings.HasPrefix(got, "Recently merged PRs that touched the same files.") {
+				t.Errorf("context lacks its heading:\n%s", got)
+			}
+			for _, s := range tt.want {
+				if !strings.Contains(got, s) {
+					t.Errorf("context lacks %q:\n%s", s, got)
+				}
+			}
+			for _, s := range tt.absent {
+				if strings.Contains(got, s) {
+					t.Errorf("context contains %q:\n%s", s, got)
+				}
+			}
+		})
+	}
+
+	if got := relatedPRContext([]relatedPR{pr(1, "", "README.md")}, files, 5); got != "" {
+		t.Errorf("context without related PRs = %q, want none", got)
+	}
+}
+
+func TestRelatedPRsInPrompt(t *testing.T) {
+	diff, err := os.ReadFile("testdata/replay/change.patch")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var prompt, graphql string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.URL.Path == "/v1/chat/completions":
+			var req struct {
+				Messages []struct {
+					Content string `json:"content"`
+				} `json:"messages"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			prompt = req.Messages[len(req.Messages)-1].Content
+			fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"Fine.\n\nApproved: true"}}],"usage":{"prompt_tokens":10,"completion_tokens":5}}`)
+		case r.URL.Path == "/api/graphql":
+			var req struct {
+				Variables map[string]any `json:"variables"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			graphql = fmt.Sprint(req.Variables["owner"], "/", req.Variables["name"])
+			fmt.Fprint(w, `{"data":{"repository":{"pullRequests":{"nodes":[
+				{"number":7,"title":"Guard the store with a mutex","body":"All writes go through Put.","files":{"nodes":[{"path":"store.go"}]}},
+				{"number":6,"title":"Docs","body":"","files":{"nodes":[{"path":"README.md"}]}}]}}}}`)
+		case r.URL.Path == "/api/v3/repos/o/r/pulls/1" && strings.Contains(r.Header.Get("Accept"), "diff"):
+			w.Write(diff)
+		case r.URL.Path == "/api/v3/repos/o/r/pulls/1":
+			fmt.Fprint(w, `{"title":"t","head":{"sha":"1111111"}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	useConfig(t, fmt.Sprintf("provider = \"openai\"\n[openai]\nbase_url = %q\n", server.URL+"/v1"))
+	t.Setenv("GITHUB_TOKEN", "t")
+
+	tests := []struct {
+		name    string
+		args    []string
+		listed  string
+		related bool
+	}{
+		{"without -related-prs", nil, "", false},
+		{"with -related-prs", []string{"-related-prs", "3"}, "o/r", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mu.Lock()
+			prompt, graphql = "", ""
+			mu.Unlock()
+			output, code := runPrgpt(t, append([]string{"-pr", server.URL + "/o/r/pull/1", "-no-cache"}, tt.args...)...)
+			if code != exitApproved {
+				t.Fatalf("exit code = %d; output:\n%s", code, output)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if graphql != tt.listed {
+				t.Errorf("merged PRs listed for %q, want %q", graphql, tt.listed)
+			}
+			if got := strings.Contains(prompt, "- #7 Guard the store with a mutex (also touched store.go): All writes go through Put."); got != tt.related {
+				t.Errorf("related PR in the prompt: %t, want %t; prompt:\n%s", got, tt.related, prompt)
+			}
+			if strings.Contains(prompt, "#6 Docs") {
+				t.Errorf("a PR touching other files is in the prompt:\n%s", prompt)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+
+	"github.com/loadfms/prgpt/pkg/prgpt"
+)
+
+const releasePrompt = `Write the release notes for %s from the pull requests merged since the previous release, listed below. Group them under the Markdown headers "## Breaking changes", "## Features" and "## Fixes", leaving out a header with nothing under it, and put other noteworthy changes under "## Other changes". Write one bullet per change for the users of the project, not its developers, and end each with the PR number, e.g. "(#12)". Leave out changes that do not affect users, such as refactoring and CI. Reply with the release notes only.
+
+%s`
+
+// mergedPR matches the subject of the commits GitHub makes when merging
+// ("Merge pull request #12 from ...") or squashing ("Add x (#12)") a PR.
+var mergedPR = regexp.MustCompile(`^Merge pull request #(\d+)\b|\(#(\d+)\)\s*$`)
+
+// mergedPRNumbers lists the PRs the commit messages say were merged, in the
+// order of the commits.
+func mergedPRNumbers(messages []string) []string {
+	var numbers []string
+	seen := map[string]bool{}
+	for _, msg := range messages {
+		subject, _, _ := strings.Cut(msg, "\n")
+		m := mergedPR.FindStringSubmatch(strings.TrimSpace(subject))
+		if m == nil {
+			continue
+		}
+		number := m[1] + m[2]
+		if !seen[number] {
+			seen[number] = true
+			numbers = append(numbers, number)
+		}
+	}
+	return numbers
+}
+
+// githubComparison is the part of the compare API's response release uses.
+type githubComparison struct {
+	TotalCommits int `json:"total_commits"`
+	Commits      []struct {
+		Commit struct {
+			Message string `json:"message"`
+		} `json:"commit"`
+	} `json:"commits"`
+}
+
+// compareCommits fetches the commits between two refs of the repository of
+// repoURL, a URL of any of its PRs.
+func compareCommits(ctx context.Context, api *githubAPI, repoURL, from, to string) (githubComparison, error) {
+	var comparison githubComparison
+	var data []byte
+	var err error
+	if api != nil {
+		data, err = api.do(ctx, "GET", repoURL, "compare/"+from+"..."+to, "application/vnd.github+json", nil)
+	} else {
+		org, repo, _, perr := parsePRURL(repoURL)
+		if perr != nil {
+			return comparison, perr
+		}
+		data, err = ghAPI(ctx, repoURL, "repos/"+org+"/"+repo+"/compare/"+from+"..."+to).Output()
+	}
+	if err != nil {
+		return comparison, fmt.Errorf("error comparing %s...%s: %v", from, to, err)
+	}
+	if err := json.Unmarshal(data, &comparison); err != nil {
+		return comparison, fmt.Errorf("error parsing comparison: %v", err)
+	}
+	return comparison, nil
+}
+
+// currentRepo is the GitHub repository of the working directory, as
+// OWNER/REPO.
+func currentRepo(ctx context.Context) (string, error) {
+	output, err := prgpt.Command(ctx, "gh", "repo", "view", "--json", "nameWithOwner", "-q", ".nameWithOwner").Output()
+	if err != nil {
+		return "", fmt.Errorf("error running gh repo view: %v", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// runRelease implements "prgpt release FROM..TO": release notes from the
+// PRs merged between two tags.
+func runRelease(args []string) int {
+	fs := newCommandFlagSet("release")
+	repo := fs.String("repo", "", "GitHub repository as OWNER/REPO; defaults to the one of the working directory")
+	profile := fs.String("profile", "", "Use the settings of [profile.NAME] in config.toml over the others; PRGPT_PROFILE sets the default")
+	backend := fs.String("backend", "", "Provider to use (openai, azure, anthropic, ollama)")
+	model := fs.String("model", "", "Model to use")
+	lang := fs.String("lang", "", "Language to write the release notes in")
+	diffs := fs.Bool("diffs", true, "Show the model the diffs of the PRs, not only their descriptions")
+	if err := fs.Parse(args); err != nil {
+		return parseExit(err)
+	}
+	from, to, ok := strings.Cut(fs.Arg(0), "..")
+	if fs.NArg() != 1 || !ok || from == "" || to == "" {
+		fs.SetOutput(os.Stdout)
+		fs.Usage()
+		return exitError
+	}
+	to = strings.TrimPrefix(to, ".")
+
+	cfg, err := loadCommandConfig(*profile, *backend, *model, *lang)
+	if err != nil {
+		fmt.Println(err)
+		return exitError
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if *repo == "" {
+		if *repo, err = currentRepo(ctx); err != nil {
+			fmt.Println("Error finding the repository, pass -repo:", err)
+			return exitError
+		}
+	}
+	host := cfg.GitHub.Host
+	if host == "" {
+		host = "github.com"
+	}
+	prURL := func(number string) string {
+		return "https://" + host + "/" + strings.Trim(*repo, "/") + "/pull/" + number
+	}
+
+	client, err := newHTTPClient(cfg)
+	if err != nil {
+		fmt.Println("Error configuring HTTP client:", err)
+		return exitError
+	}
+	fg := newGitHubForge(cfg, client)
+
+	comparison, err := compareCommits(ctx, fg.api, prURL("0"), from, to)
+	if err != nil {
+		fmt.Println(err)
+		return exitError
+	}
+	if comparison.TotalCommits > len(comparison.Commits) {
+		fmt.Fprintf(os.Stderr, "Warning: only the first %d of %d commits are considered\n", len(comparison.Commits), comparison.TotalCommits)
+	}
+	var messages []string
+	for _, c := range comparison.Commits {
+		messages = append(messages, c.Commit.Message)
+	}
+	numbers := mergedPRNumbers(messages)
+	if len(numbers) == 0 {
+		fmt.Printf("No merged PRs found between %s and %s.\n", from, to)
+		return exitError
+	}
+
+	// Every PR gets an equal share of the context window for its diff.
+	budget := promptBudget(contextWindowFor(cfg.Model.Name, cfg.Model.ContextWindow)) - estimateTokens(releasePrompt)
+	var prs strings.Builder
+	for _, number := range numbers {
+		info, err := fg.Info(ctx, prURL(number))
+		if err != nil {
+			fmt.Println("Error fetching PR #"+number+":", err)
+			return exitError
+		}
+		entry := fmt.Sprintf("### #%s: %s\n", number, info.Title)
+		if len(info.Labels) > 0 {
+			entry += "Labels: " + strings.Join(info.Labels, ", ") + "\n"
+		}
+		if body := strings.TrimSpace(info.Body); body != "" {
+			entry += "\n" + body + "\n"
+		}
+		if *diffs {
+			diff, err := fg.Diff(ctx, prURL(number))
+			if err != nil {
+				fmt.Println("Error fetching diff of PR #"+number+":", err)
+				return exitError
+			}
+			if len(cfg.Filters.Exclude) > 0 {
+				files, _ := excludeFiles(splitDiffFiles(diff), cfg.Filters.Exclude)
+				diff = joinDiffFiles(files)
+			}
+			if diff, err = redactDiff(cfg, diff); err != nil {
+				fmt.Println(err)
+				return exitError
+			}
+			diff, _ = truncateToTokens(diff, max(budget/len(numbers)-estimateTokens(entry), 0))
+			if diff != "" {
+				entry += "\nDiff:\n" + diff + "\n"
+			}
+		}
+		prs.WriteString(entry + "\n")
+	}
+	notes, _ := truncateToTokens(prs.String(), budget)
+
+	reply, err := completeOnce(ctx, cfg, client, "release "+*repo+" "+from+".."+to, fmt.Sprintf(releasePrompt, to, notes))
+	if err != nil {
+		fmt.Println("Error generating release notes:", err)
+		if hint := apiErrorHint(err); hint != "" {
+			fmt.Println("Hint:", hint)
+		}
+		return exitError
+	}
+	fmt.Println(strings.TrimSpace(reply))
+	return exitApproved
+}
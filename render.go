@@ -0,0 +1,142 @@
+package main
+
+import (
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	ansiReset     = "\x1b[0m"
+	ansiBold      = "\x1b[1m"
+	ansiDim       = "\x1b[2m"
+	ansiItalic    = "\x1b[3m"
+	ansiUnderline = "\x1b[4m"
+	ansiRed       = "\x1b[31m"
+	ansiGreen     = "\x1b[32m"
+	ansiYellow    = "\x1b[33m"
+	ansiBlue      = "\x1b[34m"
+	ansiMagenta   = "\x1b[35m"
+	ansiCyan      = "\x1b[36m"
+)
+
+// severityColors is how the severity tags of findings are highlighted.
+var severityColors = map[string]string{
+	"blocker": ansiBold + ansiRed,
+	"major":   ansiRed,
+	"minor":   ansiYellow,
+	"nit":     ansiDim,
+}
+
+var (
+	mdSeverityTag = regexp.MustCompile(`^(\s*)[*+-]\s+\[([^\]]+)\]`)
+	// Unlike mdImage and mdLink these never match across the escape
+	// sequences already inserted, which contain "[".
+	mdRenderImage = regexp.MustCompile(`!\[([^\[\]]*)\]\(([^)]*)\)`)
+	mdRenderLink  = regexp.MustCompile(`\[([^\[\]]+)\]\(([^)]*)\)`)
+	mdVerdict     = regexp.MustCompile(`(?i)^\s*\**approved:?\**:?\s*(true|false)\**\s*$`)
+)
+
+// isTerminal tells whether f is a terminal rather than a pipe or a file.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// useColor tells whether Markdown output is rendered for the terminal:
+// only on one, unless -plain or NO_COLOR asks otherwise.
+func useColor(plain bool) bool {
+	return !plain && os.Getenv("NO_COLOR") == "" && isTerminal(os.Stdout)
+}
+
+// renderMarkdown renders the Markdown the model returns with ANSI colors:
+// bold headers, highlighted severity tags and verdict, and dimmed code
+// blocks and quotes.
+func renderMarkdown(md string, labels severityLabels) string {
+	var out []string
+	inFence := false
+
+	for _, line := range strings.Split(md, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+			if lang := strings.TrimLeft(trimmed, "`~"); inFence && lang != "" {
+				out = append(out, ansiDim+"  "+lang+ansiReset)
+			}
+			continue
+		}
+		if inFence {
+			out = append(out, "  "+ansiCyan+line+ansiReset)
+			continue
+		}
+
+		if mdRule.MatchString(line) {
+			out = append(out, ansiDim+strings.Repeat("─", 40)+ansiReset)
+			continue
+		}
+		if m := mdHeader.FindStringSubmatch(line); m != nil {
+			style := ansiBold + ansiBlue
+			if strings.HasPrefix(trimmed, "# ") {
+				style += ansiUnderline
+			}
+			out = append(out, style+renderInline(m[1], ansiBold+ansiBlue)+ansiReset)
+			continue
+		}
+		if m := mdVerdict.FindStringSubmatch(line); m != nil {
+			color := ansiRed
+			if strings.EqualFold(m[1], "true") {
+				color = ansiGreen
+			}
+			out = append(out, ansiBold+color+"Approved: "+strings.ToLower(m[1])+ansiReset)
+			continue
+		}
+
+		if mdQuote.MatchString(line) {
+			out = append(out, ansiDim+"│ "+ansiItalic+renderInline(mdQuote.ReplaceAllString(line, ""), ansiDim+ansiItalic)+ansiReset)
+			continue
+		}
+
+		if m := mdSeverityTag.FindStringSubmatch(line); m != nil {
+			severity, _ := labels.canonical(m[2])
+			if color, ok := severityColors[severity]; ok {
+				rest := renderInline(line[len(m[0]):], "")
+				out = append(out, m[1]+"• "+color+"["+m[2]+"]"+ansiReset+rest)
+				continue
+			}
+		}
+		line = mdBullet.ReplaceAllString(line, "$1• ")
+		out = append(out, renderInline(line, ""))
+	}
+
+	return strings.Join(out, "\n")
+}
+
+// renderInline styles code spans, bold, italics and links, restoring the
+// line's own style after each.
+func renderInline(s, base string) string {
+	restore := ansiReset + base
+	s = mdRenderImage.ReplaceAllString(s, ansiItalic+"$1"+restore+" "+ansiDim+"($2)"+restore)
+	s = mdRenderLink.ReplaceAllString(s, ansiUnderline+"$1"+restore+" "+ansiDim+"($2)"+restore)
+	s = mdCode.ReplaceAllString(s, ansiMagenta+"$1"+restore)
+	s = mdStrong.ReplaceAllString(s, ansiBold+"$1$2"+restore)
+	s = mdEmphasis.ReplaceAllString(s, ansiItalic+"$1"+restore)
+	s = mdUnderscore.ReplaceAllString(s, "$1"+ansiItalic+"$2"+restore+"$3")
+	return s
+}
+
+// streamTail is what the final review adds to the streamed one: its lines
+// that were not streamed, in order, such as notes on dropped findings,
+// without the verdict, which the caller prints last.
+func streamTail(streamed, final string) string {
+	seen := map[string]bool{}
+	for _, line := range strings.Split(streamed, "\n") {
+		seen[strings.TrimSpace(line)] = true
+	}
+	var tail []string
+	for _, line := range strings.Split(final, "\n") {
+		if l := strings.TrimSpace(line); l != "" && !seen[l] && !strings.HasPrefix(l, "Approved:") {
+			tail = append(tail, line)
+		}
+	}
+	return strings.Join(tail, "\n\n")
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// repoConfigName is a repository's own config, shared by everyone
+// reviewing its PRs.
+const repoConfigName = ".prgpt.toml"
+
+// RepoConfig is what a repository's .prgpt.toml may set: the team's review
+// conventions. Whoever can commit to the repository writes it, so it holds
+// no credentials, endpoints, provider or model, which would decide what
+// the user is billed for.
+type RepoConfig struct {
+	Prompt struct {
+		System    string                    `toml:"system"`
+		Custom    string                    `toml:"custom"`
+		Templates map[string]PromptTemplate `toml:"templates"`
+	} `toml:"prompt"`
+	Filters struct {
+		Exclude []string `toml:"exclude"`
+	} `toml:"filters"`
+	Checklist struct {
+		Items []string `toml:"items"`
+	} `toml:"checklist"`
+}
+
+// findRepoFile looks for the file at the relative path name from the
+// current directory up to the root of the git repository it is in.
+func findRepoFile(name string) (string, bool) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", false
+	}
+	for {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if _, err := os.Stat(path); err == nil {
+			return path, true
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return "", false
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// fetchRepoConfig fetches .prgpt.toml from the default branch of the PR's
+// repository, so a PR cannot change the conventions it is reviewed by.
+func fetchRepoConfig(ctx context.Context, github githubForge, prURL string) ([]byte, error) {
+	output, err := github.RepoFile(ctx, prURL, repoConfigName)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching %s: %v", repoConfigName, err)
+	}
+	return output, nil
+}
+
+// loadRepoConfig reads the .prgpt.toml of the GitHub PR's repository, or
+// for reviews of no PR the one found from the current directory. PRs on
+// other forges get none, since the current directory need not be their
+// repository. source names where it came from; it is empty when there is
+// none.
+func loadRepoConfig(ctx context.Context, github githubForge, prURL string) (repo RepoConfig, source string, err error) {
+	var data []byte
+	if prURL != "" && !isGitHubURL(prURL) {
+		return repo, "", nil
+	}
+	if prURL != "" {
+		data, err = fetchRepoConfig(ctx, github, prURL)
+		if err != nil || data == nil {
+			return repo, "", err
+		}
+		org, name, _, _ := parsePRURL(prURL)
+		source = org + "/" + name + ":" + repoConfigName
+	} else {
+		path, ok := findRepoFile(repoConfigName)
+		if !ok {
+			return repo, "", nil
+		}
+		data, err = os.ReadFile(path)
+		if err != nil {
+			return repo, "", fmt.Errorf("error opening %s: %v", path, err)
+		}
+		source = path
+	}
+
+	decoder := toml.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&repo); err != nil {
+		var missing *toml.StrictMissingError
+		if errors.As(err, &missing) {
+			return repo, source, fmt.Errorf("%s may only set [prompt], [filters] exclude and [checklist] items", source)
+		}
+		return repo, source, configError(source, data, err)
+	}
+	return repo, source, nil
+}
+
+// applyRepoConfig overrides the settings of the config file with the
+// repository's. Flags and the environment still win.
+func applyRepoConfig(cfg *FileConfig, origins configOrigins, repo RepoConfig) {
+	set := func(key string, apply func()) {
+		if origin := origins.of(key); origin == originFlag || origin == originEnv {
+			return
+		}
+		apply()
+		origins[key] = originRepoFile
+	}
+
+	if repo.Prompt.System != "" {
+		set("prompt.system", func() { cfg.Prompt.System = repo.Prompt.System })
+	}
+	if repo.Prompt.Custom != "" {
+		set("prompt.custom", func() { cfg.Prompt.Custom = repo.Prompt.Custom })
+	}
+	for name, tmpl := range repo.Prompt.Templates {
+		set("prompt.templates."+name, func() {
+			if cfg.Prompt.Templates == nil {
+				cfg.Prompt.Templates = map[string]PromptTemplate{}
+			}
+			cfg.Prompt.Templates[name] = tmpl
+		})
+	}
+	if len(repo.Filters.Exclude) > 0 {
+		set("filters.exclude", func() { cfg.Filters.Exclude = repo.Filters.Exclude })
+	}
+	if len(repo.Checklist.Items) > 0 {
+		set("checklist.items", func() { cfg.Checklist.Items = repo.Checklist.Items })
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/loadfms/prgpt/pkg/prgpt"
+)
+
+const (
+	defaultMaxAttempts = 3
+	retryBaseDelay     = time.Second
+	retryMaxDelay      = 30 * time.Second
+)
+
+var retryableStatus = map[int]bool{
+	http.StatusTooManyRequests:     true,
+	http.StatusInternalServerError: true,
+	http.StatusBadGateway:          true,
+	http.StatusServiceUnavailable:  true,
+	http.StatusGatewayTimeout:      true,
+}
+
+// retryTransport retries requests answered with a rate limit or a transient
+// server error, waiting as long as Retry-After asks or backing off
+// exponentially otherwise. Only GET and HEAD requests are retried, and those
+// made with a context from withRetries, since a retried POST could e.g.
+// post a comment twice when the first attempt went through.
+type retryTransport struct {
+	next        http.RoundTripper
+	maxAttempts int
+}
+
+func newRetryTransport(next http.RoundTripper, maxAttempts int) *retryTransport {
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+	return &retryTransport{next: next, maxAttempts: maxAttempts}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := t.next.RoundTrip(req)
+		if err != nil || !retryableStatus[resp.StatusCode] || attempt >= t.maxAttempts || !retryable(req) || req.GetBody == nil && req.Body != nil {
+			return resp, err
+		}
+
+		delay := retryDelay(resp.Header, attempt)
+		slog.Debug("retrying", "host", req.URL.Host, "status", resp.StatusCode, "delay", delay, "attempt", attempt+1, "max_attempts", t.maxAttempts)
+		resp.Body.Close()
+
+		if err := sleepContext(req, delay); err != nil {
+			return nil, err
+		}
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// retriesKey marks a context whose requests may be retried whatever their
+// method.
+type retriesKey struct{}
+
+// withRetries lets the requests made with ctx be retried although they are
+// not GET or HEAD requests, for calls without side effects such as
+// completions and GraphQL queries.
+func withRetries(ctx context.Context) context.Context {
+	return context.WithValue(ctx, retriesKey{}, true)
+}
+
+func retryable(req *http.Request) bool {
+	if req.Method == http.MethodGet || req.Method == http.MethodHead {
+		return true
+	}
+	ok, _ := req.Context().Value(retriesKey{}).(bool)
+	return ok
+}
+
+// retriedProvider lets the HTTP client retry its calls, which have no side
+// effects although they are POST requests.
+type retriedProvider struct {
+	prgpt.Provider
+}
+
+func (p retriedProvider) Complete(ctx context.Context, req prgpt.CompletionRequest) (prgpt.Completion, error) {
+	return p.Provider.Complete(withRetries(ctx), req)
+}
+
+// retryDelay honors a Retry-After header in seconds or as an HTTP date and
+// otherwise doubles the delay with every attempt.
+func retryDelay(h http.Header, attempt int) time.Duration {
+	if after := h.Get("Retry-After"); after != "" {
+		if seconds, err := strconv.Atoi(after); err == nil {
+			return min(time.Duration(seconds)*time.Second, retryMaxDelay)
+		}
+		if at, err := http.ParseTime(after); err == nil {
+			return min(max(time.Until(at), 0), retryMaxDelay)
+		}
+	}
+	return min(retryBaseDelay<<min(attempt-1, 10), retryMaxDelay)
+}
+
+// sleepContext waits for d unless the request's context ends first.
+func sleepContext(r *http.Request, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-r.Context().Done():
+		return r.Context().Err()
+	}
+}
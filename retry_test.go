@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRetryTransport(t *testing.T) {
+	var mu sync.Mutex
+	attempts := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts[r.URL.Path]++
+		switch {
+		case r.URL.Path == "/v1/chat/completions" && attempts[r.URL.Path] > 1:
+			fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"Fine.\n\nApproved: true"}}],"usage":{"prompt_tokens":10,"completion_tokens":5}}`)
+		default:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	client, err := newHTTPClient(FileConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		path     string
+		call     func(t *testing.T, path string)
+		attempts int
+	}{
+		{"GET", "/get", func(t *testing.T, path string) {
+			resp, err := client.Get(server.URL + path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+		}, defaultMaxAttempts},
+		{"POST", "/post", func(t *testing.T, path string) {
+			resp, err := client.Post(server.URL+path, "application/json", strings.NewReader("{}"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+		}, 1},
+		{"POST with retries", "/query", func(t *testing.T, path string) {
+			req, err := http.NewRequestWithContext(withRetries(context.Background()), "POST", server.URL+path, strings.NewReader("{}"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+		}, defaultMaxAttempts},
+		{"PR comment", "/api/v3/repos/o/r/issues/1/comments", func(t *testing.T, path string) {
+			api := &githubAPI{client: client, token: "t"}
+			if err := api.postComment(context.Background(), server.URL+"/o/r/pull/1", "Looks good."); err == nil {
+				t.Error("posting the comment did not fail")
+			}
+		}, 1},
+		{"completion", "/v1/chat/completions", func(t *testing.T, path string) {
+			useConfig(t, fmt.Sprintf("provider = \"openai\"\n[openai]\nbase_url = %q\n", server.URL+"/v1"))
+			if output, code := runPrgpt(t, "-diff", "testdata/replay/change.patch", "-no-cache"); code != exitApproved {
+				t.Errorf("exit code = %d; output:\n%s", code, output)
+			}
+		}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.call(t, tt.path)
+			mu.Lock()
+			defer mu.Unlock()
+			if got := attempts[tt.path]; got != tt.attempts {
+				t.Errorf("%d attempts, want %d", got, tt.attempts)
+			}
+		})
+	}
+}
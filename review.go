@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/loadfms/prgpt/pkg/prgpt"
+)
+
+const (
+	chunkInstruction   = "This is one file of a larger PR. List the potential issues in this file's changes in Markdown, focusing only on problems that could affect the application's stability. Be concise; do not give an overall verdict."
+	summaryInstruction = "Above are reviews of the individual files of a PR. Combine them into a single final consideration for the whole PR."
+)
+
+// reviewer sends prompts to the API and keeps track of the calls it made.
+type reviewer struct {
+	ctx      context.Context
+	provider prgpt.Provider
+	// providerName names provider in the keys of the per-file cache.
+	providerName string
+	model        string
+	temperature  float64
+	maxTokens    int
+	topP         float64
+	history      []prgpt.Message
+	system       string
+	prompts      *promptBuilder
+
+	// stream receives the final response as it arrives, for -stream.
+	stream  func(delta string)
+	timings *timings
+
+	// mu guards the counters below, as reviewChunked makes calls
+	// concurrently.
+	mu     sync.Mutex
+	calls  int
+	tokens int
+	// usage is what the calls used, by model, for the usage log.
+	usage map[string]tokenUsage
+
+	// concurrency is how many files reviewChunked reviews at the same
+	// time.
+	concurrency int
+
+	// security focuses the per-file reviews of reviewChunked on security,
+	// for -mode security.
+	security bool
+
+	// maxPromptTokens is how large a prompt may be for the model's context
+	// window; reviewChunked splits files that would exceed it.
+	maxPromptTokens int
+
+	// maxCalls caps the API calls of a whole invocation; 0 means no cap.
+	maxCalls int
+	pacer    *pacer
+}
+
+func (r *reviewer) complete(prompt string, responseFormat *prgpt.ResponseFormat) (string, error) {
+	r.mu.Lock()
+	if r.maxCalls > 0 && r.calls >= r.maxCalls {
+		defer r.mu.Unlock()
+		return "", fmt.Errorf("reached the limit of %d API calls (-max-api-calls); not starting another", r.maxCalls)
+	}
+	r.calls++
+	call := r.calls
+	r.mu.Unlock()
+
+	if err := r.pacer.wait(r.ctx); err != nil {
+		return "", err
+	}
+	start := time.Now()
+	lib := prgpt.Reviewer{Provider: r.provider, Model: r.model, System: r.system, History: r.history, Temperature: &r.temperature, MaxTokens: r.maxTokens, TopP: r.topP}
+	resp, err := lib.Complete(r.ctx, prompt, responseFormat, r.stream)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.timings.track(fmt.Sprintf("api call #%d", call), start)
+	if err == nil {
+		slog.Debug("completion", "call", call, "model", r.model, "input_tokens", resp.InputTokens, "output_tokens", resp.OutputTokens, "duration", time.Since(start))
+	}
+	r.tokens += resp.Tokens
+	if r.usage == nil {
+		r.usage = map[string]tokenUsage{}
+	}
+	u := r.usage[r.model]
+	u.Calls++
+	u.InputTokens += resp.InputTokens
+	u.OutputTokens += resp.OutputTokens
+	r.usage[r.model] = u
+	if resp.RateLimitReset > 0 {
+		slog.Debug("request quota exhausted, holding off", "duration", resp.RateLimitReset)
+		r.pacer.holdOff(resp.RateLimitReset)
+	}
+	return resp.Content, err
+}
+
+func (r *reviewer) chunkInstruction() string {
+	if r.security {
+		return securityChunkInstruction
+	}
+	return chunkInstruction
+}
+
+// chunkPrompts splits the files that would not fit in a prompt and builds
+// the prompt of every resulting chunk.
+func (r *reviewer) chunkPrompts(files []fileDiff, repoContext string) ([]fileDiff, []string, error) {
+	if r.maxPromptTokens > 0 {
+		empty, err := r.prompts.build("", repoContext, r.chunkInstruction())
+		if err != nil {
+			return nil, nil, err
+		}
+		overhead := estimateTokens(empty)
+		files = chunkDiff(files, max(r.maxPromptTokens-overhead, 1))
+	}
+
+	prompts := make([]string, len(files))
+	for i, f := range files {
+		prompt, err := r.prompts.build(f.Text, repoContext, r.chunkInstruction())
+		if err != nil {
+			return nil, nil, err
+		}
+		prompts[i] = prompt
+	}
+	return files, prompts, nil
+}
+
+// reviewChunked reviews every file of the diff on its own, up to
+// r.concurrency at a time, serving files whose diff did not change from the
+// cache, and then combines the per-file reviews into one final
+// consideration.
+func (r *reviewer) reviewChunked(files []fileDiff, repoContext, instruction string, responseFormat *prgpt.ResponseFormat, cache *reviewCache) (string, error) {
+	// Only the combined review is streamed.
+	stream := r.stream
+	r.stream = nil
+	defer func() { r.stream = stream }()
+
+	files, prompts, err := r.chunkPrompts(files, repoContext)
+	if err != nil {
+		return "", err
+	}
+
+	// The first failure cancels the reviews still running.
+	parent := r.ctx
+	ctx, cancel := context.WithCancel(parent)
+	r.ctx = ctx
+	defer func() {
+		cancel()
+		r.ctx = parent
+	}()
+
+	reviews := make([]string, len(files))
+	var mu sync.Mutex
+	var firstErr error
+	cached := 0
+
+	slots := make(chan struct{}, max(r.concurrency, 1))
+	var wg sync.WaitGroup
+	for i, f := range files {
+		wg.Add(1)
+		go func(i int, f fileDiff) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			if ctx.Err() != nil {
+				return
+			}
+
+			key := cacheKey(r.providerName, r.model, r.system, prompts[i], fmt.Sprint(r.temperature, r.maxTokens, r.topP))
+			review, ok := cache.get(key)
+			if !ok {
+				var err error
+				review, err = r.complete(prompts[i], nil)
+				if err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = fmt.Errorf("error reviewing %s: %w", f.Path, err)
+						cancel()
+					}
+					mu.Unlock()
+					return
+				}
+				if err := cache.put(key, review); err != nil {
+					fmt.Fprintln(os.Stderr, "Warning: could not cache review:", err)
+				}
+			}
+
+			mu.Lock()
+			if ok {
+				cached++
+			}
+			reviews[i] = "### " + f.Path + "\n" + strings.TrimSpace(review)
+			mu.Unlock()
+		}(i, f)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return "", firstErr
+	}
+	r.ctx = parent
+
+	if cache != nil {
+		fmt.Fprintf(os.Stderr, "Reused %d of %d cached file reviews\n", cached, len(files))
+	}
+
+	// The reviews of many files may not fit in one prompt together; each
+	// then gets an equal share of it.
+	request := "\n\n" + summaryInstruction + " " + instruction
+	summary := strings.Join(reviews, "\n\n")
+	if budget := r.maxPromptTokens - estimateTokens(request); r.maxPromptTokens > 0 && estimateTokens(summary) > budget {
+		fmt.Fprintln(os.Stderr, "Warning: the file reviews exceed the context window together; combining a truncated version of each")
+		for i := range reviews {
+			reviews[i], _ = truncateToTokens(reviews[i], max(budget/len(reviews)-1, 0))
+		}
+		summary = strings.Join(reviews, "\n\n")
+	}
+	r.stream = stream
+	return r.complete(summary+request, responseFormat)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestMaxAPICalls(t *testing.T) {
+	var diff strings.Builder
+	for _, path := range []string{"a.go", "b.go", "c.go"} {
+		fmt.Fprintf(&diff, "diff --git a/%s b/%s\n--- a/%s\n+++ b/%s\n@@ -1 +1 @@\n-package x\n+package y\n", path, path, path, path)
+	}
+	patch := filepath.Join(t.TempDir(), "change.patch")
+	if err := os.WriteFile(patch, []byte(diff.String()), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"Fine.\n\nApproved: true"}}],"usage":{"prompt_tokens":10,"completion_tokens":5}}`)
+	}))
+	defer server.Close()
+
+	// Three files and the combined review make four calls, made one at a
+	// time so that the cap cancels none in flight.
+	tests := []struct {
+		max   int
+		calls int32
+		code  int
+	}{
+		{0, 4, exitApproved},
+		{4, 4, exitApproved},
+		{2, 2, exitError},
+		{1, 1, exitError},
+	}
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.max), func(t *testing.T) {
+			useConfig(t, fmt.Sprintf("provider = \"openai\"\n[openai]\nbase_url = %q\n", server.URL+"/v1"))
+			calls.Store(0)
+			output, code := runPrgpt(t, "-diff", patch, "-chunked", "-concurrency", "1", "-max-api-calls", strconv.Itoa(tt.max))
+			if code != tt.code || calls.Load() != tt.calls {
+				t.Errorf("exit code %d after %d calls, want %d after %d; output:\n%s", code, calls.Load(), tt.code, tt.calls, output)
+			}
+			if want := fmt.Sprintf("reached the limit of %d API calls (-max-api-calls)", tt.max); tt.code == exitError && !strings.Contains(output, want) {
+				t.Errorf("output lacks %q:\n%s", want, output)
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+const structuredInstruction = "Review this PR, focusing only on potential issues and ensuring the application's stability. Respond only with JSON that matches the provided schema."
+
+var jsonSchemaTypes = map[string]bool{
+	"object": true, "array": true, "string": true, "number": true,
+	"integer": true, "boolean": true, "null": true,
+}
+
+// loadJSONSchema reads a JSON Schema file and checks that it is well formed
+// enough to be sent as a response_format and used to validate responses.
+func loadJSONSchema(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading JSON schema: %v", err)
+	}
+
+	var schema map[string]any
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("error parsing JSON schema %s: %v", path, err)
+	}
+
+	if err := checkSchema(schema, "#"); err != nil {
+		return nil, fmt.Errorf("invalid JSON schema %s: %v", path, err)
+	}
+
+	return schema, nil
+}
+
+func checkSchema(schema map[string]any, path string) error {
+	if t, ok := schema["type"]; ok {
+		types, err := schemaTypes(t)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		for _, name := range types {
+			if !jsonSchemaTypes[name] {
+				return fmt.Errorf("%s: unknown type %q", path, name)
+			}
+		}
+	}
+
+	if props, ok := schema["properties"]; ok {
+		propMap, ok := props.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s/properties: must be an object", path)
+		}
+		for name, prop := range propMap {
+			sub, ok := prop.(map[string]any)
+			if !ok {
+				return fmt.Errorf("%s/properties/%s: must be an object", path, name)
+			}
+			if err := checkSchema(sub, path+"/properties/"+name); err != nil {
+				return err
+			}
+		}
+	}
+
+	if required, ok := schema["required"]; ok {
+		list, ok := required.([]any)
+		if !ok {
+			return fmt.Errorf("%s/required: must be an array", path)
+		}
+		for _, r := range list {
+			if _, ok := r.(string); !ok {
+				return fmt.Errorf("%s/required: entries must be strings", path)
+			}
+		}
+	}
+
+	if items, ok := schema["items"]; ok {
+		sub, ok := items.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s/items: must be an object", path)
+		}
+		if err := checkSchema(sub, path+"/items"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func schemaTypes(t any) ([]string, error) {
+	switch v := t.(type) {
+	case string:
+		return []string{v}, nil
+	case []any:
+		var types []string
+		for _, e := range v {
+			s, ok := e.(string)
+			if !ok {
+				return nil, fmt.Errorf("type entries must be strings")
+			}
+			types = append(types, s)
+		}
+		return types, nil
+	}
+	return nil, fmt.Errorf("type must be a string or an array of strings")
+}
+
+// validateAgainstSchema checks the types and required properties of value
+// against schema. It covers the subset of JSON Schema checkSchema accepts.
+func validateAgainstSchema(schema map[string]any, value any, path string) error {
+	if t, ok := schema["type"]; ok {
+		types, _ := schemaTypes(t)
+		matched := false
+		for _, name := range types {
+			if jsonTypeMatches(name, value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%s: expected %s", path, strings.Join(types, " or "))
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		if required, ok := schema["required"].([]any); ok {
+			for _, r := range required {
+				if _, ok := v[r.(string)]; !ok {
+					return fmt.Errorf("%s: missing required property %q", path, r)
+				}
+			}
+		}
+		props, _ := schema["properties"].(map[string]any)
+		for name, sub := range props {
+			if field, ok := v[name]; ok {
+				if err := validateAgainstSchema(sub.(map[string]any), field, path+"."+name); err != nil {
+					return err
+				}
+			}
+		}
+	case []any:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range v {
+				if err := validateAgainstSchema(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func jsonTypeMatches(name string, value any) bool {
+	switch name {
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		f, ok := value.(float64)
+		return ok && f == float64(int64(f))
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	}
+	return false
+}
+
+// renderStructured validates a JSON response against the schema and renders
+// it as a generic Markdown outline.
+func renderStructured(schema map[string]any, content string) (string, error) {
+	var value any
+	if err := json.Unmarshal([]byte(content), &value); err != nil {
+		return "", fmt.Errorf("error parsing structured response: %v", err)
+	}
+	if err := validateAgainstSchema(schema, value, "response"); err != nil {
+		return "", fmt.Errorf("structured response does not match schema: %v", err)
+	}
+
+	var b strings.Builder
+	renderJSONValue(&b, value, "")
+	return strings.TrimRight(b.String(), "\n"), nil
+}
+
+func renderJSONValue(b *strings.Builder, value any, indent string) {
+	switch v := value.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if isScalar(v[k]) {
+				fmt.Fprintf(b, "%s- **%s**: %s\n", indent, k, scalarString(v[k]))
+				continue
+			}
+			fmt.Fprintf(b, "%s- **%s**:\n", indent, k)
+			renderJSONValue(b, v[k], indent+"  ")
+		}
+	case []any:
+		for i, item := range v {
+			if isScalar(item) {
+				fmt.Fprintf(b, "%s- %s\n", indent, scalarString(item))
+				continue
+			}
+			fmt.Fprintf(b, "%s- #%d\n", indent, i+1)
+			renderJSONValue(b, item, indent+"  ")
+		}
+	default:
+		fmt.Fprintf(b, "%s%s\n", indent, scalarString(v))
+	}
+}
+
+func isScalar(value any) bool {
+	switch value.(type) {
+	case map[string]any, []any:
+		return false
+	}
+	return true
+}
+
+func scalarString(value any) string {
+	if value == nil {
+		return "null"
+	}
+	return fmt.Sprint(value)
+}
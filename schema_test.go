@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCustomJSONSchema(t *testing.T) {
+	const schemaJSON = `{
+  "type": "object",
+  "properties": {
+    "risk": {"type": "string"},
+    "approved": {"type": "boolean"},
+    "files": {"type": "array", "items": {"type": "string"}}
+  },
+  "required": ["risk", "approved"]
+}`
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+		code    int
+	}{
+		{"matching", `{"risk":"low","approved":true,"files":["a.go"]}`, []string{"- **risk**: low", "- **files**:\n  - a.go"}, exitApproved},
+		{"not matching", `{"risk":"low"}`, []string{"does not match schema"}, exitError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent struct {
+				ResponseFormat struct {
+					Type       string `json:"type"`
+					JSONSchema struct {
+						Schema map[string]any `json:"schema"`
+					} `json:"json_schema"`
+				} `json:"response_format"`
+			}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := json.NewDecoder(r.Body).Decode(&sent); err != nil {
+					t.Error(err)
+				}
+				content, _ := json.Marshal(tt.content)
+				fmt.Fprintf(w, `{"choices":[{"message":{"role":"assistant","content":%s}}],"usage":{"prompt_tokens":10,"completion_tokens":5}}`, content)
+			}))
+			defer server.Close()
+			useConfig(t, fmt.Sprintf("provider = \"openai\"\n[openai]\nbase_url = %q\n", server.URL+"/v1"))
+
+			schemaFile := filepath.Join(t.TempDir(), "schema.json")
+			if err := os.WriteFile(schemaFile, []byte(schemaJSON), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			output, code := runPrgpt(t, "-diff", "testdata/replay/change.patch", "-no-cache", "-json-schema-file", schemaFile)
+
+			var want map[string]any
+			if err := json.Unmarshal([]byte(schemaJSON), &want); err != nil {
+				t.Fatal(err)
+			}
+			if sent.ResponseFormat.Type != "json_schema" || !reflect.DeepEqual(sent.ResponseFormat.JSONSchema.Schema, want) {
+				t.Errorf("sent response_format %+v, want the schema file", sent.ResponseFormat)
+			}
+			if code != tt.code {
+				t.Errorf("exit code = %d, want %d; output:\n%s", code, tt.code, output)
+			}
+			for _, s := range tt.want {
+				if !strings.Contains(output, s) {
+					t.Errorf("output does not contain %q:\n%s", s, output)
+				}
+			}
+		})
+	}
+}
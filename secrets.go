@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	// Strings at least this long, with letters and digits, and at least
+	// this many bits of entropy per character are taken to be secrets.
+	// Hex digests stay below the threshold, at 4 bits at most.
+	minSecretLength  = 32
+	minSecretEntropy = 4.2
+)
+
+// secretPattern is a kind of secret and the regular expression finding it.
+type secretPattern struct {
+	kind string
+	re   *regexp.Regexp
+}
+
+var secretPatterns = []secretPattern{
+	{"aws-access-key", regexp.MustCompile(`\b(?:AKIA|ASIA|AGPA|AIDA|AROA)[0-9A-Z]{16}\b`)},
+	{"aws-secret-key", regexp.MustCompile(`(?i)aws.{0,20}(?:secret|key).{0,20}?['"=:\s]([0-9a-zA-Z/+]{40})\b`)},
+	{"github-token", regexp.MustCompile(`\b(?:gh[pousr]_[0-9A-Za-z]{36}|github_pat_[0-9A-Za-z_]{60,})\b`)},
+	{"slack-token", regexp.MustCompile(`\bxox[abposr]-[0-9A-Za-z-]{10,}`)},
+	{"openai-key", regexp.MustCompile(`\bsk-(?:proj-|ant-)?[0-9A-Za-z_-]{20,}`)},
+	{"google-api-key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{"jwt", regexp.MustCompile(`\beyJ[0-9A-Za-z_-]{8,}\.eyJ[0-9A-Za-z_-]{8,}\.[0-9A-Za-z_-]{8,}`)},
+	{"password-in-url", regexp.MustCompile(`[a-z][a-z0-9+.-]*://[^\s:/@]+:([^\s@/]+)@`)},
+}
+
+// checksumFiles hold hashes that look random but are no secrets.
+var checksumFiles = []string{"go.sum", "*.lock", "package-lock.json", "pnpm-lock.yaml", "npm-shrinkwrap.json"}
+
+var (
+	privateKeyBegin = regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY( BLOCK)?-----`)
+	privateKeyEnd   = regexp.MustCompile(`-----END [A-Z ]*PRIVATE KEY( BLOCK)?-----`)
+	entropyToken    = regexp.MustCompile(`[0-9A-Za-z+/_-]{32,}={0,2}`)
+)
+
+// compileSecretPatterns compiles the [redact] patterns.
+func compileSecretPatterns(patterns []string) ([]secretPattern, error) {
+	var compiled []secretPattern
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid [redact] pattern %q: %v", p, err)
+		}
+		compiled = append(compiled, secretPattern{"custom", re})
+	}
+	return compiled, nil
+}
+
+// redaction is a secret redactSecrets removed.
+type redaction struct {
+	File string
+	// Line is in the new version of the file, or 0 for removed lines.
+	Line int
+	Kind string
+}
+
+func (r redaction) String() string {
+	if r.Line == 0 {
+		return fmt.Sprintf("%s in %s", r.Kind, r.File)
+	}
+	return fmt.Sprintf("%s in %s:%d", r.Kind, r.File, r.Line)
+}
+
+// redactDiff redacts the secrets of diff as [redact] says and tells what
+// it redacted on stderr. Every command sends its diffs through it before
+// they leave the machine.
+func redactDiff(cfg FileConfig, diff string) (string, error) {
+	if cfg.Redact.Disabled {
+		return diff, nil
+	}
+	patterns, err := compileSecretPatterns(cfg.Redact.Patterns)
+	if err != nil {
+		return "", fmt.Errorf("error in [redact] config: %v", err)
+	}
+	diff, found := redactSecrets(diff, patterns)
+	if len(found) > 0 {
+		fmt.Fprintf(os.Stderr, "Redacted %d secret(s) from the diff before sending it: %s\n", len(found), redactionSummary(found))
+	}
+	return diff, nil
+}
+
+// redactSecrets replaces the secrets found in the lines of the diff with
+// [REDACTED:<kind>] before the diff leaves the machine. Private keys are
+// redacted line by line, so the hunks keep their lengths.
+func redactSecrets(diff string, extra []secretPattern) (string, []redaction) {
+	patterns := append(append([]secretPattern{}, secretPatterns...), extra...)
+
+	var b strings.Builder
+	var found []redaction
+	file, line := "", 0
+	inKey, checksums := false, false
+	for _, l := range strings.SplitAfter(diff, "\n") {
+		if isFileHeader(l) {
+			file, line, inKey = diffHeaderPath(l), 0, false
+			checksums = matchesAny(checksumFiles, file)
+		}
+		if strings.HasPrefix(l, "@@") {
+			// The hunks of combined diffs have no single new line number.
+			line = -1
+			if m := hunkHeader.FindStringSubmatch(l); m != nil {
+				line, _ = strconv.Atoi(m[1])
+			}
+			b.WriteString(redactHunkContext(l, file, patterns, checksums, &found))
+			continue
+		}
+		if line == 0 || l == "" || !strings.ContainsRune("+- ", rune(l[0])) {
+			b.WriteString(l)
+			continue
+		}
+
+		at := max(line, 0)
+		if l[0] == '-' {
+			at = 0
+		} else if line > 0 {
+			line++
+		}
+		text, newline := strings.CutSuffix(l[1:], "\n")
+		redact := func(kind string) string {
+			found = append(found, redaction{File: file, Line: at, Kind: kind})
+			return "[REDACTED:" + kind + "]"
+		}
+
+		switch {
+		case privateKeyBegin.MatchString(text):
+			text = redact("private-key")
+			inKey = !privateKeyEnd.MatchString(l)
+		case inKey:
+			inKey = !privateKeyEnd.MatchString(text)
+			text = "[REDACTED:private-key]"
+		default:
+			text = redactText(text, patterns, checksums, redact)
+		}
+
+		b.WriteString(l[:1] + text)
+		if newline {
+			b.WriteString("\n")
+		}
+	}
+	return b.String(), found
+}
+
+// redactHunkContext redacts the line of source git quotes after the closing
+// @@ of a hunk header as the hunk's context, e.g. the enclosing function.
+func redactHunkContext(header, file string, patterns []secretPattern, checksums bool, found *[]redaction) string {
+	end := strings.Index(header[2:], "@@")
+	if end < 0 {
+		return header
+	}
+	// Combined diffs close their headers with as many @ as they open them.
+	end += 2
+	for end < len(header) && header[end] == '@' {
+		end++
+	}
+	redact := func(kind string) string {
+		*found = append(*found, redaction{File: file, Kind: kind})
+		return "[REDACTED:" + kind + "]"
+	}
+	return header[:end] + redactText(header[end:], patterns, checksums, redact)
+}
+
+// redactText replaces the secrets in text with what redact returns for
+// their kind. Random-looking strings are left alone in checksum files.
+func redactText(text string, patterns []secretPattern, checksums bool, redact func(kind string) string) string {
+	for _, p := range patterns {
+		text = replaceSecret(p.re, text, func() string { return redact(p.kind) })
+	}
+	return entropyToken.ReplaceAllStringFunc(text, func(s string) string {
+		if checksums || !looksRandom(s) {
+			return s
+		}
+		return redact("high-entropy-string")
+	})
+}
+
+// replaceSecret replaces the matches of re in s, or only their first group
+// when re has one, which then holds the secret within its context.
+func replaceSecret(re *regexp.Regexp, s string, replacement func() string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range re.FindAllStringSubmatchIndex(s, -1) {
+		start, end := m[0], m[1]
+		if len(m) > 2 && m[2] >= 0 {
+			start, end = m[2], m[3]
+		}
+		b.WriteString(s[last:start])
+		b.WriteString(replacement())
+		last = end
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// looksRandom tells whether s, mixing letters and digits, has the entropy
+// of a generated key rather than of a word or a hex digest.
+func looksRandom(s string) bool {
+	if len(s) < minSecretLength || !strings.ContainsFunc(s, unicode.IsDigit) || !strings.ContainsFunc(s, unicode.IsLetter) {
+		return false
+	}
+	counts := map[rune]float64{}
+	for _, r := range s {
+		counts[r]++
+	}
+	entropy := 0.0
+	for _, c := range counts {
+		p := c / float64(len(s))
+		entropy -= p * math.Log2(p)
+	}
+	return entropy >= minSecretEntropy
+}
+
+// redactionSummary lists the redactions for the report, e.g.
+// "jwt in a.go:3, aws-access-key in b.go:7".
+func redactionSummary(found []redaction) string {
+	var parts []string
+	for _, r := range found {
+		parts = append(parts, r.String())
+	}
+	return strings.Join(parts, ", ")
+}
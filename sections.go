@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/loadfms/prgpt/pkg/prgpt"
+)
+
+// sectionsInstruction asks for the review under the [output] sections, in
+// their order.
+func sectionsInstruction(sections []string) string {
+	var headings []string
+	for _, s := range sections {
+		headings = append(headings, "'## "+s+"'")
+	}
+	return fmt.Sprintf("Structure the review under these Markdown headings, in this order, and write something under every one of them, if only that it does not apply: %s.", strings.Join(headings, ", "))
+}
+
+// missingSections lists the required sections the review lacks or left
+// empty.
+func missingSections(review string, required []string) []string {
+	_, sections := markdownSections(review)
+	var missing []string
+	for _, s := range required {
+		if body, ok := sections[normalizeHeading(s)]; !ok || strings.TrimSpace(body) == "" {
+			missing = append(missing, s)
+		}
+	}
+	return missing
+}
+
+// ensureSections asks the model once more for the whole review when the
+// one it gave lacks required sections, continuing the conversation so the
+// diff need not be sent again. A review still lacking them is kept with a
+// warning.
+func (r *reviewer) ensureSections(prompt, review string, required []string) (string, error) {
+	missing := missingSections(review, required)
+	if len(missing) == 0 {
+		return review, nil
+	}
+	fmt.Fprintf(os.Stderr, "The review lacks the sections %s; asking again\n", strings.Join(missing, ", "))
+
+	history := r.history
+	defer func() { r.history = history }()
+	r.history = append(append([]prgpt.Message{}, history...),
+		prgpt.Message{Role: "user", Content: prompt},
+		prgpt.Message{Role: "assistant", Content: review})
+	retry := fmt.Sprintf("Your review lacks the sections %s. Reply with the complete review again, with every section: %s", strings.Join(missing, ", "), sectionsInstruction(required))
+	content, err := r.complete(retry, nil)
+	if err != nil {
+		return "", err
+	}
+	if missing := missingSections(content, required); len(missing) > 0 {
+		fmt.Fprintln(os.Stderr, "Warning: the review still lacks the sections", strings.Join(missing, ", "))
+	}
+	return content, nil
+}
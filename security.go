@@ -0,0 +1,20 @@
+package main
+
+import (
+	"regexp"
+
+	"github.com/loadfms/prgpt/pkg/prgpt"
+)
+
+const (
+	securityIntro            = "Review this PR as an application security engineer, looking only for security issues: injection (SQL, command, template, path traversal), broken authentication and authorization, hard-coded or leaked secrets, unsafe deserialization, SSRF, weak cryptography, and risky new or updated dependencies. Ignore style and bugs without security impact."
+	cweInstruction           = "Start every finding's message with the CWE identifier that fits it best, e.g. `- [major] db/user.go:42 - CWE-89: query built by string concatenation`."
+	securityChunkInstruction = "This is one file of a larger PR. List the security issues in this file's changes in Markdown, each starting with its CWE identifier. Be concise; do not give an overall verdict."
+)
+
+var cweID = regexp.MustCompile(`\bCWE-\d+\b`)
+
+// securityInstruction is the instruction of -mode security.
+func securityInstruction(labels severityLabels) string {
+	return securityIntro + " " + labels.findingsInstruction() + " " + cweInstruction + " " + prgpt.VerdictInstruction
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxWebhookBody is GitHub's own limit on webhook payloads.
+const maxWebhookBody = 25 << 20
+
+// reviewedActions are the pull_request webhook actions that get a review.
+var reviewedActions = map[string]bool{
+	"opened":           true,
+	"reopened":         true,
+	"synchronize":      true,
+	"ready_for_review": true,
+}
+
+type pullRequestEvent struct {
+	Action      string `json:"action"`
+	PullRequest struct {
+		HTMLURL string `json:"html_url"`
+		Draft   bool   `json:"draft"`
+	} `json:"pull_request"`
+}
+
+// verifySignature checks the X-Hub-Signature-256 header GitHub computes
+// over the payload with the webhook's secret.
+func verifySignature(secret, signature string, body []byte) bool {
+	got, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return false
+	}
+	decoded, err := hex.DecodeString(got)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(decoded, mac.Sum(nil))
+}
+
+var errQueueFull = errors.New("too many reviews waiting")
+
+// reviewQueue runs the reviews of the PRs webhooks asked for. A PR that is
+// already waiting is not queued twice.
+type reviewQueue struct {
+	mu      sync.Mutex
+	waiting map[string]bool
+	jobs    chan string
+}
+
+// add queues a review of the PR, reporting whether it was not waiting yet.
+func (q *reviewQueue) add(prURL string) (bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.waiting[prURL] {
+		return false, nil
+	}
+	select {
+	case q.jobs <- prURL:
+		q.waiting[prURL] = true
+		return true, nil
+	default:
+		return false, errQueueFull
+	}
+}
+
+func (q *reviewQueue) take(prURL string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.waiting, prURL)
+}
+
+// runServe implements "prgpt serve": an HTTP server receiving GitHub
+// pull_request webhooks and reviewing each opened or updated PR in its own
+// prgpt process, with the arguments given after -- (-post by default).
+func runServe(args []string) int {
+	fs := newCommandFlagSet("serve")
+	addr := fs.String("addr", ":8080", "Address to listen on")
+	path := fs.String("path", "/webhook", "URL path receiving the webhooks")
+	workers := fs.Int("workers", 2, "How many PRs to review at the same time")
+	if err := fs.Parse(args); err != nil {
+		return parseExit(err)
+	}
+	reviewArgs := fs.Args()
+	if len(reviewArgs) == 0 {
+		reviewArgs = []string{"-post"}
+	}
+
+	cfg, _, err := loadConfig()
+	if _, envErr := applyEnv(&cfg, configOrigins{}); envErr != nil {
+		fmt.Println("Error in environment:", envErr)
+		return exitError
+	}
+	if err != nil && !errors.Is(err, errNoConfigFile) {
+		fmt.Println("Error in config:", err)
+		return exitError
+	}
+	if cfg.Webhook.Secret == "" {
+		fmt.Println("prgpt serve needs [webhook] secret (or PRGPT_WEBHOOK_SECRET) to verify the webhooks")
+		return exitError
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Println("Error locating prgpt executable:", err)
+		return exitError
+	}
+
+	dir, err := os.MkdirTemp("", "prgpt-serve-")
+	if err != nil {
+		fmt.Println("Error creating temporary directory:", err)
+		return exitError
+	}
+	defer os.RemoveAll(dir)
+	paceFile := filepath.Join(dir, "pace")
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	queue := &reviewQueue{waiting: map[string]bool{}, jobs: make(chan string, 100)}
+	var wg sync.WaitGroup
+	for w := 0; w < max(*workers, 1); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for prURL := range queue.jobs {
+				queue.take(prURL)
+				fmt.Fprintln(os.Stderr, "Reviewing", prURL)
+				res := reviewInProcess(ctx, exe, prURL, reviewArgs, paceFile)
+				if res.code == exitError {
+					fmt.Fprintf(os.Stderr, "Review of %s failed: %s%s\n", prURL, res.output, res.stderr)
+					continue
+				}
+				fmt.Fprintf(os.Stderr, "Reviewed %s (exit code %d)\n", prURL, res.code)
+			}
+		}()
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(*path, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+		if err != nil {
+			http.Error(w, "error reading payload", http.StatusBadRequest)
+			return
+		}
+		if !verifySignature(cfg.Webhook.Secret, r.Header.Get("X-Hub-Signature-256"), body) {
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+
+		switch r.Header.Get("X-GitHub-Event") {
+		case "ping":
+			fmt.Fprintln(w, "pong")
+			return
+		case "pull_request":
+		default:
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		var event pullRequestEvent
+		if err := json.Unmarshal(body, &event); err != nil || event.PullRequest.HTMLURL == "" {
+			http.Error(w, "invalid pull_request payload", http.StatusBadRequest)
+			return
+		}
+		if !reviewedActions[event.Action] || event.PullRequest.Draft {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		added, err := queue.add(event.PullRequest.HTMLURL)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if !added {
+			fmt.Fprintln(w, "already queued")
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprintln(w, "queued")
+	})
+
+	srv := &http.Server{Addr: *addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdown)
+	}()
+
+	fmt.Fprintf(os.Stderr, "Listening for GitHub webhooks on %s%s\n", *addr, *path)
+	err = srv.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		// No handler may queue reviews once the queue is closed.
+		<-stopped
+	}
+	close(queue.jobs)
+	wg.Wait()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Println("Error serving webhooks:", err)
+		return exitError
+	}
+	return exitApproved
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// canonicalSeverities lists the severities used internally, most severe
+// first.
+var canonicalSeverities = []string{"blocker", "major", "minor", "nit"}
+
+// severityAliases are the usual names of other tools for the canonical
+// severities.
+var severityAliases = map[string]string{
+	"critical":   "blocker",
+	"error":      "major",
+	"warning":    "minor",
+	"info":       "nit",
+	"suggestion": "nit",
+}
+
+// severityLabels maps canonical severities to the labels a team uses for
+// them. Severities without a mapping keep their canonical name.
+type severityLabels map[string]string
+
+func newSeverityLabels(mapping map[string]string) (severityLabels, error) {
+	labels := severityLabels{}
+	seen := map[string]string{}
+	for canonical, label := range mapping {
+		if _, ok := severityRank[canonical]; !ok {
+			return nil, fmt.Errorf("unknown severity %q, expected one of %s", canonical, strings.Join(canonicalSeverities, ", "))
+		}
+		label = strings.TrimSpace(label)
+		if label == "" {
+			return nil, fmt.Errorf("empty label for severity %q", canonical)
+		}
+		if other, ok := seen[strings.ToLower(label)]; ok {
+			return nil, fmt.Errorf("label %q is used for both %q and %q", label, other, canonical)
+		}
+		seen[strings.ToLower(label)] = canonical
+		labels[canonical] = label
+	}
+	return labels, nil
+}
+
+func (l severityLabels) label(canonical string) string {
+	if label, ok := l[canonical]; ok {
+		return label
+	}
+	return canonical
+}
+
+// canonical maps a label back to its canonical severity. Canonical names
+// and their aliases are always understood, even when a team uses other
+// labels.
+func (l severityLabels) canonical(label string) (string, bool) {
+	label = strings.ToLower(strings.TrimSpace(label))
+	for canonical, l := range l {
+		if strings.ToLower(l) == label {
+			return canonical, true
+		}
+	}
+	if _, ok := severityRank[label]; ok {
+		return label, true
+	}
+	if canonical, ok := severityAliases[label]; ok {
+		return canonical, true
+	}
+	return "", false
+}
+
+func (l severityLabels) findingsInstruction() string {
+	var names []string
+	for _, s := range canonicalSeverities {
+		names = append(names, l.label(s))
+	}
+	return fmt.Sprintf("List every issue on its own line under a '## Findings' heading, formatted as `- [severity] path:line - message`, where severity is one of %s, from most to least severe.", strings.Join(names, ", "))
+}
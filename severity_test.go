@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+var teamMapping = map[string]string{"blocker": "P0", "major": "P1", "minor": "P2", "nit": "Polish"}
+
+func TestSeverityLabelsRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapping map[string]string
+	}{
+		{"default labels", nil},
+		{"team labels", teamMapping},
+		{"partial mapping", map[string]string{"blocker": "Must fix"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			labels, err := newSeverityLabels(tt.mapping)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, severity := range canonicalSeverities {
+				label := labels.label(severity)
+				if want, ok := tt.mapping[severity]; ok && label != want {
+					t.Errorf("label(%q) = %q, want %q", severity, label, want)
+				}
+				for _, l := range []string{label, strings.ToUpper(label), " " + label + " ", severity} {
+					if got, ok := labels.canonical(l); !ok || got != severity {
+						t.Errorf("canonical(%q) = %q, %t, want %q", l, got, ok, severity)
+					}
+				}
+			}
+			for alias, severity := range severityAliases {
+				if got, ok := labels.canonical(alias); !ok || got != severity {
+					t.Errorf("canonical(%q) = %q, %t, want %q", alias, got, ok, severity)
+				}
+			}
+			if got, ok := labels.canonical("urgent"); ok {
+				t.Errorf("canonical(\"urgent\") = %q, want no severity", got)
+			}
+		})
+	}
+}
+
+func TestNewSeverityLabelsErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapping map[string]string
+		want    string
+	}{
+		{"unknown severity", map[string]string{"critical": "P0"}, `unknown severity "critical"`},
+		{"empty label", map[string]string{"nit": " "}, `empty label for severity "nit"`},
+		{"label used twice", map[string]string{"major": "High", "minor": "high"}, "is used for both"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newSeverityLabels(tt.mapping); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindingsInstruction(t *testing.T) {
+	labels, err := newSeverityLabels(teamMapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := labels.findingsInstruction(); !strings.Contains(got, "where severity is one of P0, P1, P2, Polish, from most to least severe.") {
+		t.Errorf("findingsInstruction() = %q", got)
+	}
+}
+
+func TestSeverityMappingReview(t *testing.T) {
+	const review = "## Findings\n- [P1] store.go:12 - nil map write\n- [Polish] store.go:3 - typo\n- [critical] store.go:20 - data race\n- [urgent] store.go:30 - not a severity"
+	tests := []struct {
+		name   string
+		args   []string
+		code   int
+		checks func(t *testing.T, output string)
+	}{
+		{
+			name: "canonical severities in reports",
+			args: []string{"-output", "gitlab-codequality"},
+			code: exitApproved,
+			checks: func(t *testing.T, output string) {
+				var issues []codeQualityIssue
+				if err := json.Unmarshal([]byte(output), &issues); err != nil {
+					t.Fatalf("output is not a report: %v\n%s", err, output)
+				}
+				var got []string
+				for _, issue := range issues {
+					got = append(got, issue.Severity)
+				}
+				if strings.Join(got, ",") != "major,info,blocker" {
+					t.Errorf("severities = %q, want major, info and blocker", got)
+				}
+			},
+		},
+		{
+			name: "team labels in the verdict",
+			args: []string{"-fail-on", "P1"},
+			code: exitRejected,
+			checks: func(t *testing.T, output string) {
+				if !strings.Contains(output, "failing on P1 or worse") {
+					t.Errorf("output does not name the team's label:\n%s", output)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useConfig(t, "provider = \"mock\"\n[severity.mapping]\nblocker = \"P0\"\nmajor = \"P1\"\nminor = \"P2\"\nnit = \"Polish\"\n")
+			t.Setenv("PRGPT_MOCK_TEXT", review)
+			t.Setenv("PRGPT_MOCK_APPROVE", "true")
+			output, code := runPrgpt(t, append([]string{"-diff", "testdata/replay/change.patch"}, tt.args...)...)
+			if code != tt.code {
+				t.Fatalf("exit code = %d, want %d; output:\n%s", code, tt.code, output)
+			}
+			tt.checks(t, output)
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// runStatus is the machine-readable outcome written by -status-file.
+type runStatus struct {
+	Approved bool `json:"approved"`
+	Blockers int  `json:"blockers"`
+	Tokens   int  `json:"tokens"`
+}
+
+// writeStatusFile writes the status atomically, so wrapper scripts never
+// see a partially written file.
+func writeStatusFile(path string, status runStatus) error {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return fmt.Errorf("error marshaling status: %v", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".prgpt-status-*")
+	if err != nil {
+		return fmt.Errorf("error creating temporary status file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing status file: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing status file: %v", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("error renaming status file: %v", err)
+	}
+	return nil
+}
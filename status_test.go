@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestStatusFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		review  string
+		approve bool
+		want    runStatus
+		code    int
+	}{
+		{"approved", "Looks good.", true, runStatus{Approved: true}, exitApproved},
+		{"blockers", "- [blocker] a.go:1 - nil map write\n- [blocker] a.go:9 - data race\n- [nit] a.go:2 - typo", false, runStatus{Blockers: 2}, exitRejected},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useConfig(t, "provider = \"mock\"\n")
+			t.Setenv("PRGPT_MOCK_TEXT", tt.review)
+			t.Setenv("PRGPT_MOCK_APPROVE", strconv.FormatBool(tt.approve))
+			dir := t.TempDir()
+			path := filepath.Join(dir, "status.json")
+			output, code := runPrgpt(t, "-diff", "testdata/replay/change.patch", "-status-file", path)
+			if code != tt.code {
+				t.Fatalf("exit code = %d, want %d; output:\n%s", code, tt.code, output)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got runStatus
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("status file %q: %v", data, err)
+			}
+			if got.Approved != tt.want.Approved || got.Blockers != tt.want.Blockers || got.Tokens <= 0 {
+				t.Errorf("status = %+v, want %+v with the tokens used", got, tt.want)
+			}
+			if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+				t.Errorf("temporary files left in %s: %v", dir, entries)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// structuredReview is the reply -structured asks for: the findings as data
+// rather than Markdown lines to parse.
+type structuredReview struct {
+	Summary  string `json:"summary"`
+	Findings []struct {
+		File       string  `json:"file"`
+		Line       int     `json:"line"`
+		Severity   string  `json:"severity"`
+		Rationale  string  `json:"rationale"`
+		Confidence float64 `json:"confidence"`
+	} `json:"findings"`
+	Approved bool `json:"approved"`
+}
+
+// findingsSchema is the JSON Schema of structuredReview, with the severities
+// named by the team's labels.
+func findingsSchema(labels severityLabels) map[string]any {
+	var names []any
+	for _, s := range canonicalSeverities {
+		names = append(names, labels.label(s))
+	}
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"summary": map[string]any{"type": "string"},
+			"findings": map[string]any{
+				"type": "array",
+				"items": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"file":       map[string]any{"type": "string"},
+						"line":       map[string]any{"type": "integer"},
+						"severity":   map[string]any{"type": "string", "enum": names},
+						"rationale":  map[string]any{"type": "string"},
+						"confidence": map[string]any{"type": "number"},
+					},
+					"required":             []any{"file", "line", "severity", "rationale", "confidence"},
+					"additionalProperties": false,
+				},
+			},
+			"approved": map[string]any{"type": "boolean"},
+		},
+		"required":             []any{"summary", "findings", "approved"},
+		"additionalProperties": false,
+	}
+}
+
+func findingsSchemaInstruction(labels severityLabels) string {
+	var names []string
+	for _, s := range canonicalSeverities {
+		names = append(names, labels.label(s))
+	}
+	return fmt.Sprintf("Review this PR, focusing only on potential issues and ensuring the application's stability. Respond only with JSON that matches the provided schema: summary is a short overall assessment, findings lists every issue with the path of the file, the line in the new version of the file (0 when the issue is not about a line), its severity, one of %s from most to least severe, the rationale, and the confidence, from 0 to 1, that it is a real issue rather than a guess, and approved tells whether the PR can be merged as it is.", strings.Join(names, ", "))
+}
+
+// renderStructuredReview validates a -structured reply and renders it as a
+// Markdown review in the format ParseVerdict reads, so the rest of the review
+// is handled as usual. It returns the findings of the reply, which are taken
+// as they are rather than parsed back from the Markdown.
+func renderStructuredReview(content string, labels severityLabels) (string, []Finding, error) {
+	var value any
+	if err := json.Unmarshal([]byte(content), &value); err != nil {
+		return "", nil, fmt.Errorf("error parsing structured response: %v", err)
+	}
+	if err := validateAgainstSchema(findingsSchema(labels), value, "response"); err != nil {
+		return "", nil, fmt.Errorf("structured response does not match schema: %v", err)
+	}
+	var review structuredReview
+	if err := json.Unmarshal([]byte(content), &review); err != nil {
+		return "", nil, fmt.Errorf("error parsing structured response: %v", err)
+	}
+
+	var b strings.Builder
+	if summary := strings.TrimSpace(review.Summary); summary != "" {
+		b.WriteString(summary + "\n\n")
+	}
+	b.WriteString("## Findings\n")
+	if len(review.Findings) == 0 {
+		b.WriteString("None.\n")
+	}
+	var findings []Finding
+	for i, f := range review.Findings {
+		severity, ok := labels.canonical(f.Severity)
+		if !ok {
+			return "", nil, fmt.Errorf("structured response does not match schema: response.findings[%d].severity: unknown severity %q", i, f.Severity)
+		}
+		confidence := min(max(f.Confidence, 0), 1)
+		finding := Finding{Severity: severity, File: f.File, Line: f.Line, Message: strings.Join(strings.Fields(f.Rationale), " "), Confidence: &confidence}
+		finding.CWE = cweID.FindString(finding.Message)
+
+		location := f.File
+		if f.Line > 0 {
+			location += fmt.Sprintf(":%d", f.Line)
+		}
+		text := fmt.Sprintf("%s (confidence %s)", finding.Message, strconv.FormatFloat(confidence, 'f', -1, 64))
+		if location == "" {
+			finding.raw = fmt.Sprintf("- [%s] %s", labels.label(severity), text)
+		} else {
+			finding.raw = fmt.Sprintf("- [%s] %s - %s", labels.label(severity), location, text)
+		}
+		b.WriteString(finding.raw + "\n")
+		findings = append(findings, finding)
+	}
+	fmt.Fprintf(&b, "\nApproved: %t", review.Approved)
+	return b.String(), findings, nil
+}
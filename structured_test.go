@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderStructuredReview(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    Finding
+		line    string
+	}{
+		{
+			name:    "path without a dot or slash",
+			content: `{"summary":"s","findings":[{"file":"Makefile","line":3,"severity":"major","rationale":"clean deletes the sources","confidence":0.7}],"approved":false}`,
+			want:    Finding{Severity: "major", File: "Makefile", Line: 3, Message: "clean deletes the sources"},
+			line:    "- [major] Makefile:3 - clean deletes the sources (confidence 0.7)",
+		},
+		{
+			name:    "no location",
+			content: `{"summary":"s","findings":[{"file":"","line":0,"severity":"nit","rationale":"typo in the title","confidence":0}],"approved":true}`,
+			want:    Finding{Severity: "nit", Message: "typo in the title"},
+			line:    "- [nit] typo in the title (confidence 0)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			review, findings, err := renderStructuredReview(tt.content, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(findings) != 1 {
+				t.Fatalf("got %d findings, want 1", len(findings))
+			}
+			f := findings[0]
+			if f.Severity != tt.want.Severity || f.File != tt.want.File || f.Line != tt.want.Line || f.Message != tt.want.Message || f.Confidence == nil {
+				t.Errorf("finding = %+v, want %+v", f, tt.want)
+			}
+			if !strings.Contains(review, tt.line+"\n") {
+				t.Errorf("review does not contain %q:\n%s", tt.line, review)
+			}
+			if got := removeFindings(review, findings); strings.Contains(got, tt.line) {
+				t.Errorf("removeFindings left the finding in:\n%s", got)
+			}
+		})
+	}
+}
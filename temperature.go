@@ -0,0 +1,57 @@
+package main
+
+import "fmt"
+
+// TemperatureCurve maps diff size to temperature for -auto-temperature:
+// diffs of up to SmallLines changed lines get Max, diffs of LargeLines or
+// more get Min, and sizes in between are interpolated linearly.
+type TemperatureCurve struct {
+	Min        float64 `toml:"auto_min"`
+	Max        float64 `toml:"auto_max"`
+	SmallLines int     `toml:"small_lines"`
+	LargeLines int     `toml:"large_lines"`
+}
+
+// withDefaults fills in the settings left out, each on its own. A large_lines
+// left out is kept above small_lines.
+func (c TemperatureCurve) withDefaults() TemperatureCurve {
+	if c.Max == 0 {
+		c.Max = 0.7
+	}
+	if c.SmallLines == 0 {
+		c.SmallLines = 50
+	}
+	if c.LargeLines == 0 {
+		c.LargeLines = c.SmallLines + 950
+	}
+	return c
+}
+
+// validate rejects a curve that would raise the temperature of large diffs
+// or has no room to interpolate.
+func (c TemperatureCurve) validate() error {
+	c = c.withDefaults()
+	switch {
+	case c.Min < 0 || c.Max > 2:
+		return fmt.Errorf("auto_min and auto_max must be between 0 and 2")
+	case c.Min > c.Max:
+		return fmt.Errorf("auto_min %g is above auto_max %g", c.Min, c.Max)
+	case c.SmallLines < 0:
+		return fmt.Errorf("small_lines must be positive, not %d", c.SmallLines)
+	case c.LargeLines <= c.SmallLines:
+		return fmt.Errorf("large_lines %d must be above small_lines %d", c.LargeLines, c.SmallLines)
+	}
+	return nil
+}
+
+func (c TemperatureCurve) temperatureFor(changedLines int) float64 {
+	c = c.withDefaults()
+	switch {
+	case changedLines <= c.SmallLines:
+		return c.Max
+	case changedLines >= c.LargeLines:
+		return c.Min
+	}
+	frac := float64(changedLines-c.SmallLines) / float64(c.LargeLines-c.SmallLines)
+	return c.Max - frac*(c.Max-c.Min)
+}
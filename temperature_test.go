@@ -0,0 +1,55 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTemperatureFor(t *testing.T) {
+	tests := []struct {
+		name    string
+		curve   TemperatureCurve
+		changed int
+		want    float64
+	}{
+		{"small diff", TemperatureCurve{}, 10, 0.7},
+		{"at small_lines", TemperatureCurve{}, 50, 0.7},
+		{"halfway", TemperatureCurve{}, 525, 0.35},
+		{"at large_lines", TemperatureCurve{}, 1000, 0},
+		{"huge diff", TemperatureCurve{}, 50000, 0},
+		{"only auto_min set", TemperatureCurve{Min: 0.2}, 5000, 0.2},
+		{"only auto_min set, small diff", TemperatureCurve{Min: 0.2}, 1, 0.7},
+		{"custom curve", TemperatureCurve{Min: 0.1, Max: 0.9, SmallLines: 100, LargeLines: 300}, 200, 0.5},
+		{"only small_lines set", TemperatureCurve{SmallLines: 200}, 1150, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.curve.validate(); err != nil {
+				t.Fatalf("validate: %v", err)
+			}
+			if got := tt.curve.temperatureFor(tt.changed); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("temperatureFor(%d) = %g, want %g", tt.changed, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemperatureCurveValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		curve TemperatureCurve
+	}{
+		{"auto_min above the default auto_max", TemperatureCurve{Min: 0.9}},
+		{"auto_min above auto_max", TemperatureCurve{Min: 0.5, Max: 0.3}},
+		{"large_lines below small_lines", TemperatureCurve{SmallLines: 500, LargeLines: 100}},
+		{"large_lines equal to small_lines", TemperatureCurve{SmallLines: 100, LargeLines: 100}},
+		{"negative auto_min", TemperatureCurve{Min: -0.1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.curve.validate(); err == nil {
+				t.Errorf("validate(%+v) = nil, want an error", tt.curve)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var htmlComment = regexp.MustCompile(`(?s)<!--.*?-->`)
+
+// markdownSections splits a Markdown document into its headed sections,
+// keyed by normalized heading. Text before the first heading is ignored.
+func markdownSections(doc string) (headings []string, sections map[string]string) {
+	sections = map[string]string{}
+	current := ""
+	var body []string
+
+	flush := func() {
+		if current != "" {
+			sections[normalizeHeading(current)] = strings.Join(body, "\n")
+		}
+	}
+
+	inFence := false
+	for _, line := range strings.Split(doc, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+		}
+		if m := mdHeader.FindStringSubmatch(line); m != nil && !inFence {
+			flush()
+			current = m[1]
+			headings = append(headings, current)
+			body = nil
+			continue
+		}
+		body = append(body, line)
+	}
+	flush()
+
+	return headings, sections
+}
+
+func normalizeHeading(h string) string {
+	return strings.ToLower(strings.TrimSpace(strings.TrimSuffix(stripInlineMarkdown(h), ":")))
+}
+
+// checkPRTemplate reports required template sections that are missing from
+// the PR body or were left empty. When required is empty every heading of
+// the template is required. Placeholder text copied verbatim from the
+// template and HTML comments do not count as content.
+func checkPRTemplate(template, body string, required []string) []string {
+	templateHeadings, templateSections := markdownSections(template)
+	if len(required) == 0 {
+		required = templateHeadings
+	}
+
+	_, bodySections := markdownSections(strings.ReplaceAll(body, "\r\n", "\n"))
+
+	var problems []string
+	for _, section := range required {
+		key := normalizeHeading(section)
+		content, ok := bodySections[key]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("missing section %q", section))
+			continue
+		}
+
+		placeholder := map[string]bool{}
+		for _, line := range strings.Split(htmlComment.ReplaceAllString(templateSections[key], ""), "\n") {
+			placeholder[strings.TrimSpace(line)] = true
+		}
+
+		filled := false
+		for _, line := range strings.Split(htmlComment.ReplaceAllString(content, ""), "\n") {
+			line = strings.TrimSpace(line)
+			if line != "" && !placeholder[line] {
+				filled = true
+				break
+			}
+		}
+		if !filled {
+			problems = append(problems, fmt.Sprintf("section %q is empty", section))
+		}
+	}
+
+	return problems
+}
+
+func formatTemplateReport(problems []string) string {
+	if len(problems) == 0 {
+		return "## PR template check\nAll required template sections are filled in.\n"
+	}
+
+	var b strings.Builder
+	b.WriteString("## PR template check\n")
+	for _, p := range problems {
+		b.WriteString("- " + p + "\n")
+	}
+	return b.String()
+}
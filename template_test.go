@@ -0,0 +1,29 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCheckPRTemplate(t *testing.T) {
+	const template = "## Summary\n<!-- what and why -->\n\n## Testing\nDescribe how you tested it.\n\n## Notes\n"
+	tests := []struct {
+		name     string
+		body     string
+		required []string
+		want     []string
+	}{
+		{"filled in", "## Summary\nFix the parser.\n\n## Testing\nUnit tests.\n\n## Notes\nNone.\n", nil, nil},
+		{"missing section", "## Summary\nFix the parser.\n\n## Notes\nNone.\n", nil, []string{`missing section "Testing"`}},
+		{"placeholder left in", "## Summary\nFix the parser.\n\n## Testing\nDescribe how you tested it.\n\n## Notes\nNone.\n", nil, []string{`section "Testing" is empty`}},
+		{"only a comment", "## Summary\n<!-- what and why -->\n\n## Testing\nUnit tests.\n\n## Notes\nNone.\n", nil, []string{`section "Summary" is empty`}},
+		{"only required sections", "## Summary\nFix the parser.\r\n", []string{"summary"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkPRTemplate(template, tt.body, tt.required); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("checkPRTemplate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
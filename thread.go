@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/loadfms/prgpt/pkg/prgpt"
+)
+
+const (
+	defaultThreadMessages = 20
+
+	threadUpdatedNote = "The PR has changed since the earlier messages of this conversation; the diff below is its current version."
+)
+
+func loadThread(prURL string) ([]prgpt.Message, error) {
+	path, err := prCachePath("threads", prURL)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading thread: %v", err)
+	}
+
+	var messages []prgpt.Message
+	if err := json.Unmarshal(data, &messages); err != nil {
+		return nil, fmt.Errorf("error parsing thread: %v", err)
+	}
+	return messages, nil
+}
+
+// saveThread stores the conversation, keeping the first message, which
+// carries the diff, and the most recent ones up to maxMessages in total.
+func saveThread(prURL string, messages []prgpt.Message, maxMessages int) error {
+	if maxMessages <= 0 {
+		maxMessages = defaultThreadMessages
+	}
+	if len(messages) > maxMessages && maxMessages > 1 {
+		recent := messages[len(messages)-(maxMessages-1):]
+		messages = append([]prgpt.Message{messages[0]}, recent...)
+	}
+
+	path, err := prCachePath("threads", prURL)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("error creating thread directory: %v", err)
+	}
+
+	data, err := json.Marshal(messages)
+	if err != nil {
+		return fmt.Errorf("error marshaling thread: %v", err)
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// threadHasDiff tells whether the conversation already holds diff, so it
+// need not be sent again; it does not once the PR was pushed to.
+func threadHasDiff(messages []prgpt.Message, diff string) bool {
+	for _, m := range messages {
+		if m.Role == "user" && strings.Contains(m.Content, diff) {
+			return true
+		}
+	}
+	return false
+}
+
+func resetThread(prURL string) error {
+	path, err := prCachePath("threads", prURL)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error removing thread: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/loadfms/prgpt/pkg/prgpt"
+)
+
+func TestThread(t *testing.T) {
+	diff, err := os.ReadFile("testdata/replay/change.patch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const pushed = "diff --git a/pushed.go b/pushed.go\n--- a/pushed.go\n+++ b/pushed.go\n@@ -1 +1 @@\n-a\n+b\n"
+
+	var mu sync.Mutex
+	prDiff, calls := string(diff), 0
+	var sent []prgpt.Message
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.URL.Path == "/v1/chat/completions":
+			var req struct {
+				Messages []prgpt.Message `json:"messages"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			sent = req.Messages
+			calls++
+			fmt.Fprintf(w, `{"choices":[{"message":{"role":"assistant","content":"Reply %d.\n\nApproved: true"}}],"usage":{"prompt_tokens":10,"completion_tokens":5}}`, calls)
+		case r.URL.Path == "/api/v3/repos/o/r/pulls/1" && strings.Contains(r.Header.Get("Accept"), "diff"):
+			fmt.Fprint(w, prDiff)
+		case r.URL.Path == "/api/v3/repos/o/r/pulls/1":
+			fmt.Fprint(w, `{"title":"t","head":{"sha":"1111111"}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	useConfig(t, fmt.Sprintf("provider = \"openai\"\n[openai]\nbase_url = %q\n", server.URL+"/v1"))
+	t.Setenv("GITHUB_TOKEN", "t")
+	prURL := server.URL + "/o/r/pull/1"
+
+	// The steps share the remembered thread, so they run in order.
+	tests := []struct {
+		name     string
+		diff     string
+		args     []string
+		roles    []string
+		withDiff string
+		updated  bool
+	}{
+		{"first review", string(diff), []string{"-thread"}, []string{"user"}, string(diff), false},
+		{"follow-up", string(diff), []string{"-thread", "-ask", "Why?"}, []string{"user", "assistant", "user"}, "", false},
+		{"without -thread", string(diff), []string{"-ask", "Why?"}, []string{"user"}, string(diff), false},
+		{"after a push", pushed, []string{"-thread", "-ask", "And now?"}, []string{"user", "assistant", "user", "assistant", "user"}, pushed, true},
+		{"reset", pushed, []string{"-thread", "-reset-thread"}, []string{"user"}, pushed, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mu.Lock()
+			prDiff = tt.diff
+			mu.Unlock()
+			output, code := runPrgpt(t, append([]string{"-pr", prURL, "-no-cache"}, tt.args...)...)
+			if code != exitApproved && code != exitRejected {
+				t.Fatalf("exit code = %d; output:\n%s", code, output)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			var roles []string
+			for _, m := range sent {
+				roles = append(roles, m.Role)
+			}
+			if strings.Join(roles, ",") != strings.Join(tt.roles, ",") {
+				t.Fatalf("sent roles %q, want %q", roles, tt.roles)
+			}
+			last := sent[len(sent)-1].Content
+			if tt.withDiff != "" && !strings.Contains(last, tt.withDiff) {
+				t.Errorf("the last message lacks the diff:\n%s", last)
+			}
+			if tt.withDiff == "" && strings.Contains(last, "diff --git") {
+				t.Errorf("the diff was sent again:\n%s", last)
+			}
+			if got := strings.Contains(last, threadUpdatedNote); got != tt.updated {
+				t.Errorf("update note sent: %t, want %t", got, tt.updated)
+			}
+		})
+	}
+}
+
+func TestSaveThread(t *testing.T) {
+	message := func(i int) prgpt.Message {
+		return prgpt.Message{Role: "user", Content: fmt.Sprint(i)}
+	}
+	tests := []struct {
+		name        string
+		messages    int
+		maxMessages int
+		want        []string
+	}{
+		{"under the limit", 3, 5, []string{"0", "1", "2"}},
+		{"keeps the diff and the most recent", 6, 3, []string{"0", "4", "5"}},
+		{"default limit", defaultThreadMessages + 2, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_CACHE_HOME", t.TempDir())
+			var messages []prgpt.Message
+			for i := 0; i < tt.messages; i++ {
+				messages = append(messages, message(i))
+			}
+			if err := saveThread("https://github.com/o/r/pull/1", messages, tt.maxMessages); err != nil {
+				t.Fatal(err)
+			}
+			saved, err := loadThread("https://github.com/o/r/pull/1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, m := range saved {
+				got = append(got, m.Content)
+			}
+			if tt.want == nil {
+				if len(got) != defaultThreadMessages || got[0] != "0" {
+					t.Errorf("saved %q, want the first and the %d most recent", got, defaultThreadMessages-1)
+				}
+				return
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("saved %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
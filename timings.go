@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+type phaseTiming struct {
+	name     string
+	duration time.Duration
+}
+
+// timings collects how long each phase of a run took, in the order the
+// phases finished.
+type timings struct {
+	phases []phaseTiming
+}
+
+func (t *timings) track(name string, start time.Time) {
+	t.phases = append(t.phases, phaseTiming{name: name, duration: time.Since(start)})
+}
+
+func (t *timings) print(w io.Writer) {
+	var total time.Duration
+	fmt.Fprintln(w, "Timings:")
+	for _, p := range t.phases {
+		fmt.Fprintf(w, "  %-20s %v\n", p.name, p.duration.Round(time.Millisecond))
+		total += p.duration
+	}
+	fmt.Fprintf(w, "  %-20s %v\n", "total", total.Round(time.Millisecond))
+}
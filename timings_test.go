@@ -0,0 +1,34 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTimingsPhases(t *testing.T) {
+	useConfig(t, "provider = \"mock\"\n")
+
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{"single prompt", nil, []string{"config load", "diff fetch", "prompt build", "api call #1", "output rendering", "total"}},
+		{"multi-pass", []string{"-multi-pass"}, []string{"api call #1", "api call #2", "api call #3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"-diff", "testdata/replay/change.patch", "-no-cache", "-timings"}, tt.args...)
+			var code int
+			stderr := stderrOf(t, func() { _, code = runPrgpt(t, args...) })
+			if code == exitError {
+				t.Fatalf("exit code = %d; stderr:\n%s", code, stderr)
+			}
+			for _, phase := range tt.want {
+				if !strings.Contains(stderr, "  "+phase+" ") {
+					t.Errorf("timings lack %q:\n%s", phase, stderr)
+				}
+			}
+		})
+	}
+}
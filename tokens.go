@@ -0,0 +1,37 @@
+package main
+
+import (
+	"regexp"
+	"unicode/utf8"
+)
+
+// pretokenizer splits text the way the cl100k_base and o200k_base BPE
+// tokenizers do before merging: contractions, words with their leading
+// space or punctuation, numbers of up to three digits, punctuation runs
+// and whitespace.
+var pretokenizer = regexp.MustCompile(`(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+`)
+
+// estimateTokens approximates how many tokens a tiktoken-style tokenizer
+// produces for s. Pieces are split like the real tokenizer does; within a
+// piece, short ones are assumed to be a single token and longer ones to
+// merge into tokens of about four bytes. Exact counts would need the
+// tokenizer's vocabulary.
+func estimateTokens(s string) int {
+	n := 0
+	for _, piece := range pretokenizer.FindAllString(s, -1) {
+		n += pieceTokens(piece)
+	}
+	return n
+}
+
+func pieceTokens(piece string) int {
+	size := len(piece)
+	if size <= 4 {
+		return 1
+	}
+	// Text outside ASCII seldom merges beyond a character or two.
+	if runes := utf8.RuneCountInString(piece); runes < size {
+		return max(1, runes*2/3)
+	}
+	return (size + 3) / 4
+}
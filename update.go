@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+const (
+	releaseRepo   = "loadfms/prgpt"
+	checksumsFile = "checksums.txt"
+)
+
+// version is the release prgpt was built as, set with
+// -ldflags "-X main.version=v1.2.3"; builds from source are "dev".
+var version = "dev"
+
+// githubRelease is the part of the releases API's response update uses.
+type githubRelease struct {
+	TagName string `json:"tag_name"`
+	Assets  []struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+// parseVersion reads a release tag such as v1.2.3 or v1.2.3-rc.1.
+func parseVersion(tag string) (v [3]int, pre string, ok bool) {
+	core, pre, _ := strings.Cut(strings.TrimPrefix(tag, "v"), "-")
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 {
+		return v, "", false
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return v, "", false
+		}
+		v[i] = n
+	}
+	return v, pre, true
+}
+
+// compareVersions orders two release tags like semantic versions, a
+// pre-release before its release; ok is false unless both are versions.
+func compareVersions(a, b string) (cmp int, ok bool) {
+	va, preA, okA := parseVersion(a)
+	vb, preB, okB := parseVersion(b)
+	if !okA || !okB {
+		return 0, false
+	}
+	for i := range va {
+		if va[i] != vb[i] {
+			if va[i] < vb[i] {
+				return -1, true
+			}
+			return 1, true
+		}
+	}
+	switch {
+	case preA == preB:
+		return 0, true
+	case preA == "":
+		return 1, true
+	case preB == "":
+		return -1, true
+	case preA < preB:
+		return -1, true
+	}
+	return 1, true
+}
+
+func (r githubRelease) assetURL(name string) (string, bool) {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a.URL, true
+		}
+	}
+	return "", false
+}
+
+// releaseAssetName is the name of the release binary for this platform,
+// e.g. prgpt_linux_amd64 or prgpt_windows_amd64.exe.
+func releaseAssetName() string {
+	name := "prgpt_" + runtime.GOOS + "_" + runtime.GOARCH
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// httpGet fetches url, authenticating to GitHub with GITHUB_TOKEN when it is
+// set, which raises the API's rate limit.
+func httpGet(ctx context.Context, client *http.Client, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" && strings.HasPrefix(url, "https://api.github.com/") {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching %s: %v", url, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("error fetching %s: %s", url, resp.Status)
+	}
+	return resp, nil
+}
+
+func latestRelease(ctx context.Context, client *http.Client) (githubRelease, error) {
+	var release githubRelease
+	resp, err := httpGet(ctx, client, "https://api.github.com/repos/"+releaseRepo+"/releases/latest")
+	if err != nil {
+		return release, err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return release, fmt.Errorf("error parsing release: %v", err)
+	}
+	return release, nil
+}
+
+// releaseChecksum reads the SHA-256 of the asset name from the release's
+// checksums file, in the "<hex>  <name>" format of sha256sum.
+func releaseChecksum(ctx context.Context, client *http.Client, release githubRelease, name string) (string, error) {
+	url, ok := release.assetURL(checksumsFile)
+	if !ok {
+		return "", fmt.Errorf("release %s has no %s", release.TagName, checksumsFile)
+	}
+	resp, err := httpGet(ctx, client, url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("error reading %s: %v", checksumsFile, err)
+	}
+	return "", fmt.Errorf("%s of release %s has no checksum for %s", checksumsFile, release.TagName, name)
+}
+
+// replaceExecutable downloads the binary at url next to the running
+// executable, checks it against the SHA-256 checksum and moves it over the
+// executable.
+func replaceExecutable(ctx context.Context, client *http.Client, url, checksum string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("error finding the executable: %v", err)
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return fmt.Errorf("error finding the executable: %v", err)
+	}
+
+	resp, err := httpGet(ctx, client, url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// The new binary is written in the executable's directory, so the
+	// rename that installs it stays on one file system.
+	tmp, err := os.CreateTemp(filepath.Dir(exe), ".prgpt-update-*")
+	if err != nil {
+		return fmt.Errorf("error creating the new executable: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hash), resp.Body); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error downloading %s: %v", url, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing the new executable: %v", err)
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); sum != checksum {
+		return fmt.Errorf("checksum mismatch for %s: got %s, want %s", url, sum, checksum)
+	}
+	if err := os.Chmod(tmp.Name(), 0o755); err != nil {
+		return fmt.Errorf("error making the new executable executable: %v", err)
+	}
+
+	// Windows cannot replace a running executable, but can rename it.
+	old := exe + ".old"
+	os.Remove(old)
+	if err := os.Rename(exe, old); err != nil {
+		return fmt.Errorf("error replacing %s: %v", exe, err)
+	}
+	if err := os.Rename(tmp.Name(), exe); err != nil {
+		os.Rename(old, exe)
+		return fmt.Errorf("error replacing %s: %v", exe, err)
+	}
+	os.Remove(old)
+	return nil
+}
+
+// runUpdate implements "prgpt update": replacing the executable with the
+// binary of the latest GitHub release for this platform, if it is newer.
+func runUpdate(args []string) int {
+	fs := newCommandFlagSet("update")
+	check := fs.Bool("check", false, "Only report whether a newer release is available")
+	profile := fs.String("profile", "", "Use the settings of [profile.NAME] in config.toml over the others; PRGPT_PROFILE sets the default")
+	force := fs.Bool("force", false, "Install the latest release even if it is not newer, or this build is not a release")
+	if err := fs.Parse(args); err != nil {
+		return parseExit(err)
+	}
+
+	cfg, _, err := loadConfigProfile(profileName(*profile))
+	if err != nil && !errors.Is(err, errNoConfigFile) {
+		fmt.Println("Error in config:", err)
+		return exitError
+	}
+	client, err := newHTTPClient(cfg)
+	if err != nil {
+		fmt.Println("Error configuring HTTP client:", err)
+		return exitError
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	release, err := latestRelease(ctx, client)
+	if err != nil {
+		fmt.Println("Error checking for updates:", err)
+		return exitError
+	}
+	cmp, ok := compareVersions(release.TagName, version)
+	switch {
+	case !ok && *check:
+		fmt.Printf("The latest release is %s; this build is %s, whose age is unknown.\n", release.TagName, version)
+		return exitApproved
+	case !ok && !*force:
+		fmt.Printf("This build is %s rather than a release, so %s may be older than it; run prgpt update -force to install %s anyway.\n", version, release.TagName, release.TagName)
+		return exitError
+	case ok && cmp <= 0 && (*check || !*force):
+		fmt.Printf("prgpt %s is up to date; the latest release is %s.\n", version, release.TagName)
+		return exitApproved
+	case *check:
+		fmt.Printf("prgpt %s is available (this is %s); run prgpt update to install it.\n", release.TagName, version)
+		return exitApproved
+	}
+
+	name := releaseAssetName()
+	url, ok := release.assetURL(name)
+	if !ok {
+		fmt.Printf("Release %s has no binary for %s/%s (%s).\n", release.TagName, runtime.GOOS, runtime.GOARCH, name)
+		return exitError
+	}
+	checksum, err := releaseChecksum(ctx, client, release, name)
+	if err != nil {
+		fmt.Println("Error verifying the update:", err)
+		return exitError
+	}
+	if err := replaceExecutable(ctx, client, url, checksum); err != nil {
+		fmt.Println("Error updating prgpt:", err)
+		return exitError
+	}
+	fmt.Printf("Updated prgpt from %s to %s.\n", version, release.TagName)
+	return exitApproved
+}
@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		cmp  int
+		ok   bool
+	}{
+		{"v1.2.3", "v1.2.3", 0, true},
+		{"v1.10.0", "v1.9.0", 1, true},
+		{"v1.2.3", "v2.0.0", -1, true},
+		{"v1.3.0", "v1.3.0-rc.1", 1, true},
+		{"v1.3.0-rc.1", "v1.3.0-rc.2", -1, true},
+		{"1.2.3", "v1.2.3", 0, true},
+		{"v1.2.3", "dev", 0, false},
+		{"v1.2", "v1.2.0", 0, false},
+	}
+	for _, tt := range tests {
+		cmp, ok := compareVersions(tt.a, tt.b)
+		if cmp != tt.cmp || ok != tt.ok {
+			t.Errorf("compareVersions(%q, %q) = %d, %t, want %d, %t", tt.a, tt.b, cmp, ok, tt.cmp, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// tokenUsage is what the calls to one model used.
+type tokenUsage struct {
+	Calls        int `json:"calls"`
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// usageRecord is the spend of one run on one model in the usage log.
+type usageRecord struct {
+	Time     time.Time `json:"time"`
+	PR       string    `json:"pr,omitempty"`
+	Provider string    `json:"provider"`
+	Model    string    `json:"model"`
+	tokenUsage
+	// Cost is in US dollars, and only meaningful when the model's price
+	// is known.
+	Cost   float64 `json:"cost"`
+	Priced bool    `json:"priced"`
+}
+
+func usagePath() (string, error) {
+	dir, err := dataDir()
+	return filepath.Join(dir, "usage.jsonl"), err
+}
+
+// recordUsage appends the spend of a run to the usage log, a record per
+// model it called.
+func recordUsage(pr, provider string, usage map[string]tokenUsage, configured modelPrice) error {
+	path, err := usagePath()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	for model, u := range usage {
+		rec := usageRecord{Time: now, PR: pr, Provider: provider, Model: model, tokenUsage: u}
+		if price, ok := priceFor(model, configured); ok {
+			rec.Priced = true
+			rec.Cost = (float64(u.InputTokens)*price.Input + float64(u.OutputTokens)*price.Output) / 1e6
+		}
+		if err := appendJSONLine(path, rec); err != nil {
+			return fmt.Errorf("error writing usage log: %v", err)
+		}
+	}
+	return nil
+}
+
+func loadUsage() ([]usageRecord, error) {
+	path, err := usagePath()
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening usage log: %v", err)
+	}
+	defer file.Close()
+
+	var records []usageRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var rec usageRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			continue
+		}
+		records = append(records, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading usage log: %v", err)
+	}
+	return records, nil
+}
+
+// monthSpend is the known cost of the runs in the calendar month of now.
+func monthSpend(now time.Time) (float64, error) {
+	records, err := loadUsage()
+	if err != nil {
+		return 0, err
+	}
+	month := now.UTC().Format("2006-01")
+	var spent float64
+	for _, rec := range records {
+		if rec.Time.UTC().Format("2006-01") == month {
+			spent += rec.Cost
+		}
+	}
+	return spent, nil
+}
+
+// runUsage implements "prgpt usage": the tokens and cost of a month's
+// reviews per model.
+func runUsage(args []string) int {
+	fs := newCommandFlagSet("usage")
+	month := fs.String("month", time.Now().UTC().Format("2006-01"), "Month to report, as YYYY-MM, or \"all\"")
+	if err := fs.Parse(args); err != nil {
+		return parseExit(err)
+	}
+
+	records, err := loadUsage()
+	if err != nil {
+		fmt.Println(err)
+		return exitError
+	}
+
+	byModel := map[string]*usageRecord{}
+	var total usageRecord
+	total.Priced = true
+	for _, rec := range records {
+		if *month != "all" && rec.Time.UTC().Format("2006-01") != *month {
+			continue
+		}
+		sum, ok := byModel[rec.Model]
+		if !ok {
+			sum = &usageRecord{Model: rec.Model, Priced: true}
+			byModel[rec.Model] = sum
+		}
+		for _, s := range []*usageRecord{sum, &total} {
+			s.Calls += rec.Calls
+			s.InputTokens += rec.InputTokens
+			s.OutputTokens += rec.OutputTokens
+			s.Cost += rec.Cost
+			s.Priced = s.Priced && rec.Priced
+		}
+	}
+	if len(byModel) == 0 {
+		fmt.Println("No usage recorded for", *month)
+		return exitApproved
+	}
+
+	models := make([]string, 0, len(byModel))
+	for m := range byModel {
+		models = append(models, m)
+	}
+	sort.Strings(models)
+
+	fmt.Printf("Usage for %s\n\n", *month)
+	fmt.Printf("%-28s %7s %12s %12s %10s\n", "MODEL", "CALLS", "INPUT", "OUTPUT", "COST")
+	for _, m := range models {
+		printUsageRow(*byModel[m])
+	}
+	total.Model = "total"
+	printUsageRow(total)
+
+	cfg, _, err := loadConfig()
+	if err != nil && !errors.Is(err, errNoConfigFile) {
+		return exitApproved
+	}
+	if _, err := applyEnv(&cfg, configOrigins{}); err == nil && cfg.Budget.MonthlyUSD > 0 && *month == time.Now().UTC().Format("2006-01") {
+		fmt.Printf("\nMonthly budget: $%.2f of $%.2f spent\n", total.Cost, cfg.Budget.MonthlyUSD)
+	}
+	return exitApproved
+}
+
+func printUsageRow(rec usageRecord) {
+	cost := fmt.Sprintf("$%.4f", rec.Cost)
+	if !rec.Priced {
+		// Unpriced models count as free, so the cost is a lower bound.
+		cost = ">=" + cost
+	}
+	fmt.Printf("%-28s %7d %12d %12d %10s\n", rec.Model, rec.Calls, rec.InputTokens, rec.OutputTokens, cost)
+}
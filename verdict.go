@@ -0,0 +1,38 @@
+package main
+
+import (
+	"encoding/json"
+)
+
+// structuredVerdict reads a top-level "approved" boolean from a structured
+// response, if the schema has one.
+func structuredVerdict(content string) (approved bool, found bool) {
+	var value map[string]any
+	if err := json.Unmarshal([]byte(content), &value); err != nil {
+		return false, false
+	}
+	approved, found = value["approved"].(bool)
+	return approved, found
+}
+
+// severityRank orders the canonical severities from least to most severe.
+var severityRank = map[string]int{"nit": 0, "minor": 1, "major": 2, "blocker": 3}
+
+const defaultFailOn = "blocker"
+
+// blockingFindings counts the findings at least as severe as failOn.
+func blockingFindings(findings []Finding, failOn string) int {
+	n := 0
+	for _, f := range findings {
+		if severityRank[f.Severity] >= severityRank[failOn] {
+			n++
+		}
+	}
+	return n
+}
+
+// verdictFromFindings approves unless a finding is at least as severe as
+// failOn.
+func verdictFromFindings(findings []Finding, failOn string) bool {
+	return blockingFindings(findings, failOn) == 0
+}
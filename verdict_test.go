@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestVerdictFromFindings(t *testing.T) {
+	const suppressGen = "[[suppress.rules]]\npath = \"gen/**\"\n"
+	tests := []struct {
+		name       string
+		text       string
+		approve    bool
+		config     string
+		args       []string
+		code       int
+		derivation string
+	}{
+		{"model verdict by default", "- [blocker] a.go:1 - nil map write", true, "", nil, exitApproved, ""},
+		{"blocker rejects despite the model", "- [blocker] a.go:1 - nil map write", true, "", []string{"-verdict-from-findings"}, exitRejected, "failing on blocker or worse"},
+		{"minor approves despite the model", "- [minor] a.go:1 - unclear name", false, "", []string{"-verdict-from-findings"}, exitApproved, "failing on blocker or worse"},
+		{"no findings", "Looks good.", false, "", []string{"-verdict-from-findings"}, exitApproved, "failing on blocker or worse"},
+		{"[verdict] fail_on", "- [minor] a.go:1 - unclear name", true, "[verdict]\nfail_on = \"minor\"\n", []string{"-verdict-from-findings"}, exitRejected, "failing on minor or worse"},
+		{"-fail-on implies the flag", "- [major] a.go:1 - leak", true, "", []string{"-fail-on", "major"}, exitRejected, "failing on major or worse"},
+		{"-fail-on overrides fail_on", "- [minor] a.go:1 - unclear name", false, "[verdict]\nfail_on = \"nit\"\n", []string{"-fail-on", "major"}, exitApproved, "failing on major or worse"},
+		{"unknown -fail-on", "Looks good.", true, "", []string{"-fail-on", "urgent"}, exitError, ""},
+		{"unknown fail_on", "Looks good.", true, "[verdict]\nfail_on = \"urgent\"\n", []string{"-verdict-from-findings"}, exitError, ""},
+		{"alias of another tool", "- [minor] a.go:1 - unclear name", true, "", []string{"-fail-on", "warning"}, exitRejected, "failing on minor or worse"},
+		{"suppressed nit keeps the model verdict", "- [nit] gen/a.go:1 - generated\n- [minor] a.go:2 - unclear name", false, suppressGen, nil, exitRejected, ""},
+		{"suppressed blocker gives way", "- [blocker] gen/a.go:1 - generated\n- [minor] a.go:2 - unclear name", false, suppressGen, nil, exitApproved, "derived from the remaining findings, failing on blocker or worse"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useConfig(t, "provider = \"mock\"\n"+tt.config)
+			t.Setenv("PRGPT_MOCK_TEXT", tt.text)
+			t.Setenv("PRGPT_MOCK_APPROVE", strconv.FormatBool(tt.approve))
+			output, code := runPrgpt(t, append([]string{"-diff", "testdata/replay/change.patch"}, tt.args...)...)
+			if code != tt.code {
+				t.Errorf("exit code = %d, want %d; output:\n%s", code, tt.code, output)
+			}
+			if tt.derivation != "" && !strings.Contains(output, tt.derivation) {
+				t.Errorf("output does not note %q:\n%s", tt.derivation, output)
+			}
+			if tt.derivation == "" && strings.Contains(output, "Verdict derived") {
+				t.Errorf("output notes a derived verdict:\n%s", output)
+			}
+		})
+	}
+}
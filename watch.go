@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+)
+
+const defaultWatchInterval = 5 * time.Minute
+
+// runWatch implements "prgpt watch -pr URL": it polls the head of the PR
+// and reviews the commits pushed since the last review whenever it moves,
+// in its own prgpt process given -since-last-review and the flags after --,
+// until interrupted. The first review covers the whole PR unless it was
+// reviewed before.
+func runWatch(args []string) int {
+	fs := newCommandFlagSet("watch")
+	prURL := fs.String("pr", "", "URL of the PR to watch")
+	profile := fs.String("profile", "", "Use the settings of [profile.NAME] in config.toml over the others; PRGPT_PROFILE sets the default")
+	interval := fs.Duration("interval", defaultWatchInterval, "How often to check the PR for new commits")
+	if err := fs.Parse(args); err != nil {
+		return parseExit(err)
+	}
+	if *prURL == "" {
+		fmt.Println("prgpt watch needs -pr")
+		return exitError
+	}
+	if *interval <= 0 {
+		fmt.Println("-interval must be positive")
+		return exitError
+	}
+	reviewArgs := fs.Args()
+
+	cfg, err := loadCommandConfig(*profile, "", "", "")
+	if err != nil {
+		fmt.Println(err)
+		return exitError
+	}
+	*prURL = normalizePRURL(*prURL, cfg.GitHub.Host)
+	client, err := newHTTPClient(cfg)
+	if err != nil {
+		fmt.Println("Error configuring HTTP client:", err)
+		return exitError
+	}
+	fg := forgeFor(*prURL, cfg, client)
+
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Println("Error locating prgpt executable:", err)
+		return exitError
+	}
+	// The reviews use the watch's profile unless the flags after -- pick
+	// another.
+	args = nil
+	if *profile != "" {
+		args = append(args, "-profile="+*profile)
+	}
+	args = append(append(args, reviewArgs...), "-since-last-review", "-pr="+*prURL)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	fmt.Fprintf(os.Stderr, "Watching %s every %s; Ctrl-C to stop\n", *prURL, *interval)
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	reviewed := ""
+	for {
+		// A failed check or review is tried again at the next tick.
+		if head, err := fg.HeadSHA(ctx, *prURL); err != nil {
+			if ctx.Err() == nil {
+				fmt.Fprintln(os.Stderr, "Warning: could not fetch the PR head:", err)
+			}
+		} else if head != reviewed {
+			fmt.Fprintf(os.Stderr, "Reviewing %s at %.7s\n", *prURL, head)
+			res := runReviewProcess(ctx, exe, *prURL, args, "")
+			if ctx.Err() != nil {
+				return exitApproved
+			}
+			if s := strings.TrimSpace(res.stderr); s != "" {
+				fmt.Fprintln(os.Stderr, s)
+			}
+			if res.code == exitError {
+				fmt.Fprintf(os.Stderr, "Review of %.7s failed: %s\n", head, strings.TrimSpace(res.output))
+			} else {
+				reviewed = head
+				fmt.Printf("## %s at %.7s (%s)\n\n%s\n\n", *prURL, head, time.Now().Format(time.Kitchen), strings.TrimSpace(res.output))
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return exitApproved
+		}
+	}
+}